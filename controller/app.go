@@ -8,6 +8,7 @@ import (
 	"FGateWay/public"
 	"github.com/gin-gonic/gin"
 	"github.com/pkg/errors"
+	"math"
 	"time"
 )
 
@@ -19,7 +20,10 @@ func APPRegister(router *gin.RouterGroup) {
 	router.GET("/app_list", admin.APPList)
 	router.GET("/app_detail", admin.APPDetail)
 	router.GET("/app_stat", admin.AppStatistics)
+	router.GET("/app_stat_range", admin.AppStatRange)
+	router.GET("/app_quota", admin.AppQuota)
 	router.GET("/app_delete", admin.APPDelete)
+	router.GET("/app_token_revoke", admin.APPTokenRevoke)
 	router.POST("/app_add", admin.AppAdd)
 	router.POST("/app_update", admin.AppUpdate)
 }
@@ -59,15 +63,16 @@ func (a *APPController) APPList(c *gin.Context) {
 			return
 		}
 		outputList = append(outputList, dto.APPListItemOutput{
-			ID:       item.ID,
-			AppID:    item.AppID,
-			Name:     item.Name,
-			Secret:   item.Secret,
-			WhiteIPS: item.WhiteIPS,
-			Qpd:      item.Qpd,
-			Qps:      item.Qps,
-			RealQpd:  appCounter.TotalCount,
-			RealQps:  appCounter.QPS,
+			ID:         item.ID,
+			AppID:      item.AppID,
+			Name:       item.Name,
+			Secret:     item.Secret,
+			WhiteIPS:   item.WhiteIPS,
+			Qpd:        item.Qpd,
+			Qps:        item.Qps,
+			MonthQuota: item.MonthQuota,
+			RealQpd:    appCounter.TotalCount,
+			RealQps:    appCounter.QPS,
 		})
 	}
 	output := dto.APPListOutput{
@@ -135,6 +140,48 @@ func (admin *APPController) APPDelete(c *gin.Context) {
 		middleware.ResponseError(c, 2003, err)
 		return
 	}
+	//已签发的token立即失效，且不再签发新token
+	if err := public.RevokeAppTokens(info.AppID, math.MaxInt64, 0); err != nil {
+		middleware.ResponseError(c, 2004, err)
+		return
+	}
+	middleware.ResponseSuccess(c, "")
+	return
+}
+
+// APPTokenRevoke godoc
+// @Summary 吊销租户全部token
+// @Description 吊销租户全部token
+// @Tags 租户管理
+// @ID /app/app_token_revoke
+// @Accept  json
+// @Produce  json
+// @Param id query string true "租户ID"
+// @Success 200 {object} middleware.Response{data=string} "success"
+// @Router /app/app_token_revoke [get]
+func (admin *APPController) APPTokenRevoke(c *gin.Context) {
+	params := &dto.APPDetailInput{}
+	if err := params.GetValidParams(c); err != nil {
+		middleware.ResponseError(c, 2001, err)
+		return
+	}
+	search := &dao.App{
+		ID: params.ID,
+	}
+	info, err := search.Find(c, lib.GORMDefaultPool, search)
+	if err != nil {
+		middleware.ResponseError(c, 2002, err)
+		return
+	}
+	if info.IsDelete == 1 {
+		middleware.ResponseError(c, 2003, errors.New("租户已删除"))
+		return
+	}
+	//token最长有效期为JwtExpires，之后吊销记录可以过期
+	if err := public.RevokeAppTokens(info.AppID, time.Now().Unix(), public.JwtExpires+60); err != nil {
+		middleware.ResponseError(c, 2004, err)
+		return
+	}
 	middleware.ResponseSuccess(c, "")
 	return
 }
@@ -169,17 +216,23 @@ func (admin *APPController) AppAdd(c *gin.Context) {
 	}
 	tx := lib.GORMDefaultPool
 	info := &dao.App{
-		AppID:    params.AppID,
-		Name:     params.Name,
-		Secret:   params.Secret,
-		WhiteIPS: params.WhiteIPS,
-		Qps:      params.Qps,
-		Qpd:      params.Qpd,
+		AppID:      params.AppID,
+		Name:       params.Name,
+		Secret:     params.Secret,
+		WhiteIPS:   params.WhiteIPS,
+		Qps:        params.Qps,
+		Qpd:        params.Qpd,
+		MonthQuota: params.MonthQuota,
 	}
 	if err := info.Save(c, tx); err != nil {
 		middleware.ResponseError(c, 2003, err)
 		return
 	}
+	//清除同名租户删除时留下的吊销记录，否则新租户的token全部被拒绝
+	if err := public.ClearAppTokenRevoke(info.AppID); err != nil {
+		middleware.ResponseError(c, 2004, err)
+		return
+	}
 	middleware.ResponseSuccess(c, "")
 	return
 }
@@ -216,6 +269,7 @@ func (admin *APPController) AppUpdate(c *gin.Context) {
 	info.WhiteIPS = params.WhiteIPS
 	info.Qps = params.Qps
 	info.Qpd = params.Qpd
+	info.MonthQuota = params.MonthQuota
 	if err := info.Save(c, lib.GORMDefaultPool); err != nil {
 		middleware.ResponseError(c, 2003, err)
 		return
@@ -280,3 +334,78 @@ func (admin *APPController) AppStatistics(c *gin.Context) {
 	middleware.ResponseSuccess(c, stat)
 	return
 }
+
+// AppQuota godoc
+// @Summary 租户配额
+// @Description 租户日/月配额使用情况
+// @Tags 租户管理
+// @ID /app/app_quota
+// @Accept  json
+// @Produce  json
+// @Param id query string true "租户ID"
+// @Success 200 {object} middleware.Response{data=dto.APPQuotaOutput} "success"
+// @Router /app/app_quota [get]
+func (admin *APPController) AppQuota(c *gin.Context) {
+	params := &dto.APPDetailInput{}
+	if err := params.GetValidParams(c); err != nil {
+		middleware.ResponseError(c, 2001, err)
+		return
+	}
+	search := &dao.App{
+		ID: params.ID,
+	}
+	detail, err := search.Find(c, lib.GORMDefaultPool, search)
+	if err != nil {
+		middleware.ResponseError(c, 2002, err)
+		return
+	}
+	day, month, err := public.GetAppQuota(detail.AppID, detail.Qpd, detail.MonthQuota)
+	if err != nil {
+		middleware.ResponseError(c, 2003, err)
+		return
+	}
+	middleware.ResponseSuccess(c, dto.APPQuotaOutput{
+		AppID: detail.AppID,
+		Day:   day,
+		Month: month,
+	})
+	return
+}
+
+// AppStatRange godoc
+// @Summary 租户分时段统计
+// @Description 最近1h/24h/7d的状态码、下游错误与耗时分位数
+// @Tags 租户管理
+// @ID /app/app_stat_range
+// @Accept  json
+// @Produce  json
+// @Param id query string true "租户ID"
+// @Param range query string true "时间范围 1h/24h/7d"
+// @Success 200 {object} middleware.Response{data=public.FlowStatRange} "success"
+// @Router /app/app_stat_range [get]
+func (admin *APPController) AppStatRange(c *gin.Context) {
+	params := &dto.APPStatRangeInput{}
+	if err := params.GetValidParams(c); err != nil {
+		middleware.ResponseError(c, 2001, err)
+		return
+	}
+	search := &dao.App{
+		ID: params.ID,
+	}
+	detail, err := search.Find(c, lib.GORMDefaultPool, search)
+	if err != nil {
+		middleware.ResponseError(c, 2002, err)
+		return
+	}
+	counter, err := public.FlowCounterHandler.GetCounter(public.FlowAppPrefix + detail.AppID)
+	if err != nil {
+		middleware.ResponseError(c, 2003, err)
+		return
+	}
+	out, err := counter.GetStatRange(params.Range, time.Now())
+	if err != nil {
+		middleware.ResponseError(c, 2004, err)
+		return
+	}
+	middleware.ResponseSuccess(c, out)
+}
@@ -0,0 +1,190 @@
+package controller
+
+import (
+	"FGateWay/dao"
+	"FGateWay/dto"
+	"FGateWay/golang_common/lib"
+	"FGateWay/middleware"
+	"FGateWay/public"
+	"github.com/gin-gonic/gin"
+	"strings"
+	"time"
+)
+
+type CertController struct {
+}
+
+func CertRegister(router *gin.RouterGroup) {
+	cert := CertController{}
+	router.GET("/cert_list", cert.CertList)
+	router.GET("/cert_delete", cert.CertDelete)
+	router.POST("/cert_add", cert.CertAdd)
+	router.POST("/cert_update", cert.CertUpdate)
+}
+
+// CertList godoc
+// @Summary 证书列表
+// @Description 证书列表
+// @Tags 证书管理
+// @ID /cert/cert_list
+// @Accept  json
+// @Produce  json
+// @Param info query string false "关键词"
+// @Param page_size query string true "每页多少条"
+// @Param page_no query string true "页码"
+// @Success 200 {object} middleware.Response{data=dto.CertListOutput} "success"
+// @Router /cert/cert_list [get]
+func (cert *CertController) CertList(c *gin.Context) {
+	params := &dto.CertListInput{}
+	if err := params.GetValidParams(c); err != nil {
+		middleware.ResponseError(c, 2001, err)
+		return
+	}
+
+	info := &dao.Certificate{}
+	list, total, err := info.CertList(c, lib.GORMDefaultPool, params)
+	if err != nil {
+		middleware.ResponseError(c, 2002, err)
+		return
+	}
+
+	outputList := []dto.CertListItemOutput{}
+	for _, item := range list {
+		outputList = append(outputList, dto.CertListItemOutput{
+			ID:         item.ID,
+			Name:       item.Name,
+			Domains:    item.Domains,
+			IsDefault:  item.IsDefault,
+			Source:     item.Source,
+			NotBefore:  item.NotBefore,
+			NotAfter:   item.NotAfter,
+			ExpireDays: int(time.Until(item.NotAfter).Hours() / 24),
+			UpdatedAt:  item.UpdatedAt,
+		})
+	}
+	middleware.ResponseSuccess(c, dto.CertListOutput{
+		List:  outputList,
+		Total: total,
+	})
+}
+
+// CertAdd godoc
+// @Summary 上传证书
+// @Description 上传证书，私钥加密后入库
+// @Tags 证书管理
+// @ID /cert/cert_add
+// @Accept  json
+// @Produce  json
+// @Param body body dto.CertAddInput true "body"
+// @Success 200 {object} middleware.Response{data=string} "success"
+// @Router /cert/cert_add [post]
+func (cert *CertController) CertAdd(c *gin.Context) {
+	params := &dto.CertAddInput{}
+	if err := params.GetValidParams(c); err != nil {
+		middleware.ResponseError(c, 2001, err)
+		return
+	}
+	info := &dao.Certificate{Name: params.Name, IsDefault: params.IsDefault, Source: public.CertSourceUpload}
+	if err := fillCertificate(info, params.CertPem, params.KeyPem); err != nil {
+		middleware.ResponseError(c, 2002, err)
+		return
+	}
+	saveCertificate(c, info)
+}
+
+// CertUpdate godoc
+// @Summary 更新证书
+// @Description 更新证书，代理在下一次检查时热加载
+// @Tags 证书管理
+// @ID /cert/cert_update
+// @Accept  json
+// @Produce  json
+// @Param body body dto.CertUpdateInput true "body"
+// @Success 200 {object} middleware.Response{data=string} "success"
+// @Router /cert/cert_update [post]
+func (cert *CertController) CertUpdate(c *gin.Context) {
+	params := &dto.CertUpdateInput{}
+	if err := params.GetValidParams(c); err != nil {
+		middleware.ResponseError(c, 2001, err)
+		return
+	}
+	search := &dao.Certificate{ID: params.ID}
+	info, err := search.Find(c, lib.GORMDefaultPool, search)
+	if err != nil {
+		middleware.ResponseError(c, 2002, err)
+		return
+	}
+	info.Name = params.Name
+	info.IsDefault = params.IsDefault
+	if err := fillCertificate(info, params.CertPem, params.KeyPem); err != nil {
+		middleware.ResponseError(c, 2003, err)
+		return
+	}
+	saveCertificate(c, info)
+}
+
+// CertDelete godoc
+// @Summary 删除证书
+// @Description 删除证书
+// @Tags 证书管理
+// @ID /cert/cert_delete
+// @Accept  json
+// @Produce  json
+// @Param id query string true "证书ID"
+// @Success 200 {object} middleware.Response{data=string} "success"
+// @Router /cert/cert_delete [get]
+func (cert *CertController) CertDelete(c *gin.Context) {
+	params := &dto.CertDeleteInput{}
+	if err := params.GetValidParams(c); err != nil {
+		middleware.ResponseError(c, 2001, err)
+		return
+	}
+	search := &dao.Certificate{ID: params.ID}
+	info, err := search.Find(c, lib.GORMDefaultPool, search)
+	if err != nil {
+		middleware.ResponseError(c, 2002, err)
+		return
+	}
+	info.IsDelete = 1
+	if err := info.Save(c, lib.GORMDefaultPool); err != nil {
+		middleware.ResponseError(c, 2003, err)
+		return
+	}
+	middleware.ResponseSuccess(c, "")
+}
+
+// 校验证书与私钥并填充域名、有效期，私钥加密保存
+func fillCertificate(info *dao.Certificate, certPem, keyPem string) error {
+	_, certInfo, err := public.ParseCertPair(certPem, keyPem)
+	if err != nil {
+		return err
+	}
+	encryptedKey, err := public.EncryptCertKey(keyPem)
+	if err != nil {
+		return err
+	}
+	info.CertPem = certPem
+	info.KeyPem = encryptedKey
+	info.Domains = strings.Join(certInfo.Domains, ",")
+	info.NotBefore = certInfo.NotBefore
+	info.NotAfter = certInfo.NotAfter
+	return nil
+}
+
+func saveCertificate(c *gin.Context, info *dao.Certificate) {
+	tx := lib.GORMDefaultPool.Begin()
+	if err := info.Save(c, tx); err != nil {
+		tx.Rollback()
+		middleware.ResponseError(c, 2004, err)
+		return
+	}
+	if info.IsDefault == 1 {
+		if err := info.ClearDefault(c, tx); err != nil {
+			tx.Rollback()
+			middleware.ResponseError(c, 2005, err)
+			return
+		}
+	}
+	tx.Commit()
+	middleware.ResponseSuccess(c, "")
+}
@@ -17,6 +17,8 @@ func DashboardRegister(group *gin.RouterGroup) {
 	service := &DashboardController{}
 	group.GET("/panel_group_data", service.PanelGroupData)
 	group.GET("/flow_stat", service.FlowStat)
+	group.GET("/flow_stat_range", service.FlowStatRange)
+	group.GET("/instances", service.Instances)
 	group.GET("/service_stat", service.ServiceStat)
 }
 
@@ -134,3 +136,50 @@ func (service *DashboardController) FlowStat(c *gin.Context) {
 		Yesterday: yesterdayList,
 	})
 }
+
+// FlowStatRange godoc
+// @Summary 全站分时段统计
+// @Description 最近1h/24h/7d的状态码、下游错误与耗时分位数
+// @Tags 首页大盘
+// @ID /dashboard/flow_stat_range
+// @Accept  json
+// @Produce  json
+// @Param range query string true "时间范围 1h/24h/7d"
+// @Success 200 {object} middleware.Response{data=public.FlowStatRange} "success"
+// @Router /dashboard/flow_stat_range [get]
+func (service *DashboardController) FlowStatRange(c *gin.Context) {
+	params := &dto.FlowStatRangeInput{}
+	if err := params.BindValidParam(c); err != nil {
+		middleware.ResponseError(c, 2000, err)
+		return
+	}
+	counter, err := public.FlowCounterHandler.GetCounter(public.FlowTotal)
+	if err != nil {
+		middleware.ResponseError(c, 2001, err)
+		return
+	}
+	out, err := counter.GetStatRange(params.Range, time.Now())
+	if err != nil {
+		middleware.ResponseError(c, 2002, err)
+		return
+	}
+	middleware.ResponseSuccess(c, out)
+}
+
+// Instances godoc
+// @Summary 代理实例列表
+// @Description 通过心跳注册的代理实例，stale=true表示心跳超时
+// @Tags 首页大盘
+// @ID /dashboard/instances
+// @Accept  json
+// @Produce  json
+// @Success 200 {object} middleware.Response{data=[]public.InstanceInfo} "success"
+// @Router /dashboard/instances [get]
+func (service *DashboardController) Instances(c *gin.Context) {
+	list, err := public.GetInstanceList()
+	if err != nil {
+		middleware.ResponseError(c, 2001, err)
+		return
+	}
+	middleware.ResponseSuccess(c, list)
+}
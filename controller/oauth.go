@@ -7,11 +7,13 @@ import (
 	"FGateWay/middleware"
 	"FGateWay/public"
 	"encoding/base64"
+	"fmt"
 	"github.com/dgrijalva/jwt-go"
 
 	"github.com/gin-gonic/contrib/sessions"
 	"github.com/gin-gonic/gin"
 	"github.com/pkg/errors"
+	"math/rand"
 	"strings"
 	"time"
 )
@@ -21,6 +23,7 @@ type OAuthController struct{}
 func OAuthRegister(group *gin.RouterGroup) {
 	oauth := &OAuthController{}
 	group.POST("/tokens", oauth.Tokens)
+	group.POST("/revoke", oauth.Revoke)
 }
 
 // Tokens godoc
@@ -67,9 +70,22 @@ func (oauth *OAuthController) Tokens(c *gin.Context) {
 	appList := dao.AppManagerHandler.GetAppList()
 	for _, appInfo := range appList {
 		if appInfo.AppID == parts[0] && appInfo.Secret == parts[1] {
+			//租户已删除或刚吊销全部token时不再签发，查询失败时也不签发，与网关的吊销检查一致
+			notBefore, err := public.GetAppTokenNotBefore(appInfo.AppID)
+			if err != nil {
+				middleware.ResponseError(c, 2007, err)
+				return
+			}
+			if notBefore >= time.Now().Unix() {
+				middleware.ResponseError(c, 2006, errors.New("租户已被禁用"))
+				return
+			}
+			now := time.Now()
 			claims := jwt.StandardClaims{
+				Id:        public.MD5(fmt.Sprintf("%s_%d_%d", appInfo.AppID, now.UnixNano(), rand.Int63())),
 				Issuer:    appInfo.AppID,
-				ExpiresAt: time.Now().Add(public.JwtExpires * time.Second).In(lib.TimeLocation).Unix(),
+				IssuedAt:  now.Unix(),
+				ExpiresAt: now.Add(public.JwtExpires * time.Second).In(lib.TimeLocation).Unix(),
 			}
 			token, err := public.JwtEncode(claims)
 			if err != nil {
@@ -89,6 +105,61 @@ func (oauth *OAuthController) Tokens(c *gin.Context) {
 	middleware.ResponseError(c, 2005, errors.New("未匹配正确APP信息"))
 }
 
+// Revoke godoc
+// @Summary 吊销TOKEN
+// @Description 租户吊销自己签发的TOKEN
+// @Tags OAUTH
+// @ID /oauth/revoke
+// @Accept  json
+// @Produce  json
+// @Param body body dto.TokenRevokeInput true "body"
+// @Success 200 {object} middleware.Response{data=string} "success"
+// @Router /oauth/revoke [post]
+func (oauth *OAuthController) Revoke(c *gin.Context) {
+	params := &dto.TokenRevokeInput{}
+	if err := params.BindValidParam(c); err != nil {
+		middleware.ResponseError(c, 2000, err)
+		return
+	}
+
+	splits := strings.Split(c.GetHeader("Authorization"), " ")
+	if len(splits) != 2 {
+		middleware.ResponseError(c, 2001, errors.New("用户名或密码格式错误"))
+		return
+	}
+	appSecret, err := base64.StdEncoding.DecodeString(splits[1])
+	if err != nil {
+		middleware.ResponseError(c, 2002, err)
+		return
+	}
+	parts := strings.Split(string(appSecret), ":")
+	if len(parts) != 2 {
+		middleware.ResponseError(c, 2003, errors.New("用户名或密码格式错误"))
+		return
+	}
+
+	claims, err := public.JwtDecode(params.Token)
+	if err != nil {
+		middleware.ResponseError(c, 2004, err)
+		return
+	}
+	for _, appInfo := range dao.AppManagerHandler.GetAppList() {
+		if appInfo.AppID == parts[0] && appInfo.Secret == parts[1] {
+			if claims.Issuer != appInfo.AppID || claims.Id == "" {
+				middleware.ResponseError(c, 2005, errors.New("token不属于该租户"))
+				return
+			}
+			if err := public.RevokeTokenJti(claims.Id, claims.ExpiresAt); err != nil {
+				middleware.ResponseError(c, 2006, err)
+				return
+			}
+			middleware.ResponseSuccess(c, "")
+			return
+		}
+	}
+	middleware.ResponseError(c, 2007, errors.New("未匹配正确APP信息"))
+}
+
 // AdminLogin godoc
 // @Summary 管理员退出
 // @Description 管理员退出
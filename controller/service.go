@@ -21,8 +21,10 @@ func ServiceRegister(group *gin.RouterGroup) {
 	group.GET("/Service_delete", ServController.Servicedelete)
 	group.GET("/Service_detail", ServController.ServiceDetail)
 	group.GET("/Service_stat", ServController.Servicestat)
+	group.GET("/Service_stat_range", ServController.ServiceStatRange)
 	group.POST("/Service_add_http", ServController.ServiceAddHttp)
 	group.POST("/Service_update_http", ServController.ServiceUpdateHttp)
+	group.POST("/Service_cache_purge", ServController.ServiceCachePurge)
 }
 
 // ServiceList godoc
@@ -84,16 +86,40 @@ func (service *ServiceController) ServiceList(c *gin.Context) {
 		if serviceDetail.Info.LoadType == public.LoadTypeHTTP && serviceDetail.HTTPRule.RuleType == public.HTTPRuleTypeDomain {
 			serviceAddr = serviceDetail.HTTPRule.Rule
 		}
+		if serviceDetail.Info.LoadType == public.LoadTypeTCP {
+			serviceAddr = fmt.Sprintf("%s:%d", clusterIp, serviceDetail.TCPRule.Port)
+		}
+		if serviceDetail.Info.LoadType == public.LoadTypeGRPC {
+			serviceAddr = fmt.Sprintf("%s:%d", clusterIp, serviceDetail.GRPCRule.Port)
+		}
 
+		counter, err := public.FlowCounterHandler.GetCounter(public.FlowServicePrefix + listItem.ServiceName)
+		if err != nil {
+			middleware.ResponseError(c, 2004, err)
+			return
+		}
+
+		//节点探活结果由代理进程上报，读取失败不影响列表展示
 		iplist := serviceDetail.LoadBalance.GetIPListByModel()
+		healthyNode := -1
+		if health, err := public.GetUpstreamHealth(listItem.ServiceName); err == nil && len(health) > 0 {
+			healthyNode = 0
+			for _, ip := range iplist {
+				if health[ip] {
+					healthyNode++
+				}
+			}
+		}
 		outItem := dto.ServiceListItemOutput{
 			ID:          listItem.ID,
 			ServiceName: listItem.ServiceName,
 			ServiceDesc: listItem.ServiceDesc,
+			LoadType:    listItem.LoadType,
 			ServiceAddr: serviceAddr,
-			Qps:         0,
-			Qpd:         0,
+			Qps:         counter.QPS,
+			Qpd:         counter.TotalCount,
 			TotalNode:   len(iplist),
+			HealthyNode: healthyNode,
 		}
 		outList = append(outList, outItem)
 	}
@@ -145,6 +171,48 @@ func (service *ServiceController) Servicedelete(c *gin.Context) {
 	middleware.ResponseSuccess(c, "")
 }
 
+// ServiceCachePurge godoc
+// @Summary 清除服务响应缓存
+// @Description 清除服务响应缓存，可按path前缀清除
+// @Tags 服务管理
+// @ID /Service/Service_cache_purge
+// @Accept  json
+// @Produce  json
+// @Param body body dto.ServiceCachePurgeInput true "body"
+// @Success 200 {object} middleware.Response{data=dto.ServiceCachePurgeOutput} "success"
+// @Router /Service/Service_cache_purge [post]
+func (service *ServiceController) ServiceCachePurge(c *gin.Context) {
+	params := &dto.ServiceCachePurgeInput{}
+	if err := params.BindValidParam(c); err != nil {
+		middleware.ResponseError(c, 2000, err)
+		return
+	}
+
+	tx, err := lib.GetGormPool("default")
+	if err != nil {
+		middleware.ResponseError(c, 2001, err)
+		return
+	}
+	serviceInfo := &dao.ServiceInfo{ID: params.ID}
+	serviceInfo, err = serviceInfo.Find(c, tx, serviceInfo)
+	if err != nil {
+		middleware.ResponseError(c, 2002, err)
+		return
+	}
+
+	//redis缓存直接清除，内存缓存通知各代理实例清除
+	count, err := public.GetCacheStore(public.CacheBackendRedis).Purge(serviceInfo.ServiceName, params.PathPrefix)
+	if err != nil {
+		middleware.ResponseError(c, 2003, err)
+		return
+	}
+	if err := public.PublishCachePurge(serviceInfo.ServiceName, params.PathPrefix); err != nil {
+		middleware.ResponseError(c, 2004, err)
+		return
+	}
+	middleware.ResponseSuccess(c, &dto.ServiceCachePurgeOutput{Count: count})
+}
+
 // ServiceAddHttp godoc
 // @Summary 添加http服务
 // @Description 添加http服务
@@ -162,6 +230,45 @@ func (administer *ServiceController) ServiceAddHttp(c *gin.Context) {
 		return
 	}
 
+	if params.NeedClientCert == 1 && params.NeedHttps != 1 {
+		middleware.ResponseError(c, 2000, errors.New("要求客户端证书需要开启https"))
+		return
+	}
+	if params.OpenOidc == 1 && params.OidcJwksUrl == "" && params.OidcStaticKeys == "" {
+		middleware.ResponseError(c, 2000, errors.New("开启OIDC需要配置JWKS地址或静态公钥"))
+		return
+	}
+	if params.OpenForwardAuth == 1 && params.ForwardAuthUrl == "" {
+		middleware.ResponseError(c, 2000, errors.New("开启外部鉴权需要配置鉴权地址"))
+		return
+	}
+	if params.OpenCors == 1 && params.CorsAllowOrigins == "" {
+		middleware.ResponseError(c, 2000, errors.New("开启跨域需要配置允许的来源"))
+		return
+	}
+	if err := public.ValidCorsOrigins(params.CorsAllowOrigins, params.CorsAllowCredentials == 1); err != nil {
+		middleware.ResponseError(c, 2000, err)
+		return
+	}
+	if err := public.ValidCompressEncodings(params.CompressEncodings); err != nil {
+		middleware.ResponseError(c, 2000, err)
+		return
+	}
+	if params.UpstreamHttps == 1 {
+		//证书文件由代理在本机加载，后台只校验配置格式，文件无法加载时代理转发报错
+		if err := (&public.UpstreamTLSConf{
+			CaFile:     params.UpstreamTlsCaFile,
+			CertFile:   params.UpstreamTlsCertFile,
+			KeyFile:    params.UpstreamTlsKeyFile,
+			ServerName: params.UpstreamTlsServerName,
+			MinVersion: params.UpstreamTlsMinVersion,
+			Insecure:   params.UpstreamTlsInsecure == 1,
+		}).Validate(); err != nil {
+			middleware.ResponseError(c, 2000, err)
+			return
+		}
+	}
+
 	tx, err := lib.GetGormPool("default")
 	if err != nil {
 		middleware.ResponseError(c, 2001, err)
@@ -200,8 +307,54 @@ func (administer *ServiceController) ServiceAddHttp(c *gin.Context) {
 		NeedHttps:      params.NeedHttps,
 		NeedStripUri:   params.NeedStripUri,
 		NeedWebsocket:  params.NeedWebsocket,
+		NeedStream:     params.NeedStream,
 		UrlRewrite:     params.UrlRewrite,
 		HeaderTransfor: params.HeaderTransfor,
+		LogBody:        params.LogBody,
+		LogBodyMaxSize: params.LogBodyMaxSize,
+
+		MaxRequestBodySize:  params.MaxRequestBodySize,
+		MaxResponseBodySize: params.MaxResponseBodySize,
+		MaxHeaderCount:      params.MaxHeaderCount,
+		MaxHeaderSize:       params.MaxHeaderSize,
+
+		WebsocketMaxConn:      params.WebsocketMaxConn,
+		WebsocketIdleTimeout:  params.WebsocketIdleTimeout,
+		WebsocketPingInterval: params.WebsocketPingInterval,
+
+		UpstreamHttps:         params.UpstreamHttps,
+		HstsMaxAge:            params.HstsMaxAge,
+		HstsIncludeSubdomains: params.HstsIncludeSubdomains,
+
+		ResponseHeaderTransfor: params.ResponseHeaderTransfor,
+		OpenCors:               params.OpenCors,
+		CorsAllowOrigins:       params.CorsAllowOrigins,
+		CorsAllowMethods:       params.CorsAllowMethods,
+		CorsAllowHeaders:       params.CorsAllowHeaders,
+		CorsExposeHeaders:      params.CorsExposeHeaders,
+		CorsAllowCredentials:   params.CorsAllowCredentials,
+		CorsMaxAge:             params.CorsMaxAge,
+
+		OpenCache:           params.OpenCache,
+		CacheBackend:        params.CacheBackend,
+		CacheTtl:            params.CacheTtl,
+		CacheForceTtl:       params.CacheForceTtl,
+		CacheStaleTtl:       params.CacheStaleTtl,
+		CacheKeyIgnoreQuery: params.CacheKeyIgnoreQuery,
+		CacheKeyHeaders:     params.CacheKeyHeaders,
+		CacheKeyApp:         params.CacheKeyApp,
+
+		OpenCompress:         params.OpenCompress,
+		CompressEncodings:    params.CompressEncodings,
+		CompressMinSize:      params.CompressMinSize,
+		CompressContentTypes: params.CompressContentTypes,
+		DecompressRequest:    params.DecompressRequest,
+	}
+	//旧格式规则统一转为JSON保存
+	if _, err := httpRule.NormalizeRules(); err != nil {
+		tx.Rollback()
+		middleware.ResponseError(c, 2006, err)
+		return
 	}
 	if err := httpRule.Save(c, tx); err != nil {
 		tx.Rollback()
@@ -216,6 +369,8 @@ func (administer *ServiceController) ServiceAddHttp(c *gin.Context) {
 		WhiteList:         params.WhiteList,
 		ClientIPFlowLimit: params.ClientipFlowLimit,
 		ServiceFlowLimit:  params.ServiceFlowLimit,
+		NeedClientCert:    params.NeedClientCert,
+		ClientCertAppKey:  params.ClientCertAppKey,
 	}
 	if err := accessControl.Save(c, tx); err != nil {
 		tx.Rollback()
@@ -232,12 +387,55 @@ func (administer *ServiceController) ServiceAddHttp(c *gin.Context) {
 		UpstreamHeaderTimeout:  params.UpstreamHeaderTimeout,
 		UpstreamIdleTimeout:    params.UpstreamIdleTimeout,
 		UpstreamMaxIdle:        params.UpstreamMaxIdle,
+
+		UpstreamRequestTimeout:    params.UpstreamRequestTimeout,
+		UpstreamStreamIdleTimeout: params.UpstreamStreamIdleTimeout,
+
+		UpstreamTlsCaFile:     params.UpstreamTlsCaFile,
+		UpstreamTlsCertFile:   params.UpstreamTlsCertFile,
+		UpstreamTlsKeyFile:    params.UpstreamTlsKeyFile,
+		UpstreamTlsServerName: params.UpstreamTlsServerName,
+		UpstreamTlsMinVersion: params.UpstreamTlsMinVersion,
+		UpstreamTlsInsecure:   params.UpstreamTlsInsecure,
 	}
 	if err := loadbalance.Save(c, tx); err != nil {
 		tx.Rollback()
 		middleware.ResponseError(c, 2008, err)
 		return
 	}
+
+	oidcAuth := &dao.OidcAuth{
+		ServiceID:      servicemodel.ID,
+		OpenOidc:       params.OpenOidc,
+		Issuer:         params.OidcIssuer,
+		Audience:       params.OidcAudience,
+		JwksUrl:        params.OidcJwksUrl,
+		StaticKeys:     params.OidcStaticKeys,
+		JwksRefresh:    params.OidcJwksRefresh,
+		RequiredClaims: params.OidcRequiredClaims,
+		AppClaim:       params.OidcAppClaim,
+		ClaimHeaders:   params.OidcClaimHeaders,
+	}
+	if err := oidcAuth.Save(c, tx); err != nil {
+		tx.Rollback()
+		middleware.ResponseError(c, 2009, err)
+		return
+	}
+
+	forwardAuth := &dao.ForwardAuth{
+		ServiceID:       servicemodel.ID,
+		OpenForwardAuth: params.OpenForwardAuth,
+		AuthUrl:         params.ForwardAuthUrl,
+		RequestHeaders:  params.ForwardAuthRequestHeaders,
+		ResponseHeaders: params.ForwardAuthResponseHeaders,
+		CacheTtl:        params.ForwardAuthCacheTtl,
+		Timeout:         params.ForwardAuthTimeout,
+	}
+	if err := forwardAuth.Save(c, tx); err != nil {
+		tx.Rollback()
+		middleware.ResponseError(c, 2010, err)
+		return
+	}
 	tx.Commit()
 	middleware.ResponseSuccess(c, "")
 
@@ -260,6 +458,45 @@ func (administer *ServiceController) ServiceUpdateHttp(c *gin.Context) {
 		return
 	}
 
+	if params.NeedClientCert == 1 && params.NeedHttps != 1 {
+		middleware.ResponseError(c, 2000, errors.New("要求客户端证书需要开启https"))
+		return
+	}
+	if params.OpenOidc == 1 && params.OidcJwksUrl == "" && params.OidcStaticKeys == "" {
+		middleware.ResponseError(c, 2000, errors.New("开启OIDC需要配置JWKS地址或静态公钥"))
+		return
+	}
+	if params.OpenForwardAuth == 1 && params.ForwardAuthUrl == "" {
+		middleware.ResponseError(c, 2000, errors.New("开启外部鉴权需要配置鉴权地址"))
+		return
+	}
+	if params.OpenCors == 1 && params.CorsAllowOrigins == "" {
+		middleware.ResponseError(c, 2000, errors.New("开启跨域需要配置允许的来源"))
+		return
+	}
+	if err := public.ValidCorsOrigins(params.CorsAllowOrigins, params.CorsAllowCredentials == 1); err != nil {
+		middleware.ResponseError(c, 2000, err)
+		return
+	}
+	if err := public.ValidCompressEncodings(params.CompressEncodings); err != nil {
+		middleware.ResponseError(c, 2000, err)
+		return
+	}
+	if params.UpstreamHttps == 1 {
+		//证书文件由代理在本机加载，后台只校验配置格式，文件无法加载时代理转发报错
+		if err := (&public.UpstreamTLSConf{
+			CaFile:     params.UpstreamTlsCaFile,
+			CertFile:   params.UpstreamTlsCertFile,
+			KeyFile:    params.UpstreamTlsKeyFile,
+			ServerName: params.UpstreamTlsServerName,
+			MinVersion: params.UpstreamTlsMinVersion,
+			Insecure:   params.UpstreamTlsInsecure == 1,
+		}).Validate(); err != nil {
+			middleware.ResponseError(c, 2000, err)
+			return
+		}
+	}
+
 	tx, err := lib.GetGormPool("default")
 	if err != nil {
 		middleware.ResponseError(c, 2001, err)
@@ -277,8 +514,48 @@ func (administer *ServiceController) ServiceUpdateHttp(c *gin.Context) {
 	httpRule.NeedHttps = params.NeedHttps
 	httpRule.NeedStripUri = params.NeedStripUri
 	httpRule.NeedWebsocket = params.NeedWebsocket
+	httpRule.NeedStream = params.NeedStream
 	httpRule.UrlRewrite = params.UrlRewrite
 	httpRule.HeaderTransfor = params.HeaderTransfor
+	httpRule.LogBody = params.LogBody
+	httpRule.LogBodyMaxSize = params.LogBodyMaxSize
+	httpRule.MaxRequestBodySize = params.MaxRequestBodySize
+	httpRule.MaxResponseBodySize = params.MaxResponseBodySize
+	httpRule.MaxHeaderCount = params.MaxHeaderCount
+	httpRule.MaxHeaderSize = params.MaxHeaderSize
+	httpRule.WebsocketMaxConn = params.WebsocketMaxConn
+	httpRule.WebsocketIdleTimeout = params.WebsocketIdleTimeout
+	httpRule.WebsocketPingInterval = params.WebsocketPingInterval
+	httpRule.UpstreamHttps = params.UpstreamHttps
+	httpRule.HstsMaxAge = params.HstsMaxAge
+	httpRule.HstsIncludeSubdomains = params.HstsIncludeSubdomains
+	httpRule.ResponseHeaderTransfor = params.ResponseHeaderTransfor
+	httpRule.OpenCors = params.OpenCors
+	httpRule.CorsAllowOrigins = params.CorsAllowOrigins
+	httpRule.CorsAllowMethods = params.CorsAllowMethods
+	httpRule.CorsAllowHeaders = params.CorsAllowHeaders
+	httpRule.CorsExposeHeaders = params.CorsExposeHeaders
+	httpRule.CorsAllowCredentials = params.CorsAllowCredentials
+	httpRule.CorsMaxAge = params.CorsMaxAge
+	httpRule.OpenCache = params.OpenCache
+	httpRule.CacheBackend = params.CacheBackend
+	httpRule.CacheTtl = params.CacheTtl
+	httpRule.CacheForceTtl = params.CacheForceTtl
+	httpRule.CacheStaleTtl = params.CacheStaleTtl
+	httpRule.CacheKeyIgnoreQuery = params.CacheKeyIgnoreQuery
+	httpRule.CacheKeyHeaders = params.CacheKeyHeaders
+	httpRule.CacheKeyApp = params.CacheKeyApp
+	httpRule.OpenCompress = params.OpenCompress
+	httpRule.CompressEncodings = params.CompressEncodings
+	httpRule.CompressMinSize = params.CompressMinSize
+	httpRule.CompressContentTypes = params.CompressContentTypes
+	httpRule.DecompressRequest = params.DecompressRequest
+	//旧格式规则统一转为JSON保存
+	if _, err := httpRule.NormalizeRules(); err != nil {
+		tx.Rollback()
+		middleware.ResponseError(c, 2006, err)
+		return
+	}
 	if err := httpRule.Save(c, tx); err != nil {
 		tx.Rollback()
 		middleware.ResponseError(c, 2006, err)
@@ -291,6 +568,8 @@ func (administer *ServiceController) ServiceUpdateHttp(c *gin.Context) {
 	accessControl.WhiteList = params.WhiteList
 	accessControl.ClientIPFlowLimit = params.ClientipFlowLimit
 	accessControl.ServiceFlowLimit = params.ServiceFlowLimit
+	accessControl.NeedClientCert = params.NeedClientCert
+	accessControl.ClientCertAppKey = params.ClientCertAppKey
 	if err := accessControl.Save(c, tx); err != nil {
 		tx.Rollback()
 		middleware.ResponseError(c, 2007, err)
@@ -305,11 +584,50 @@ func (administer *ServiceController) ServiceUpdateHttp(c *gin.Context) {
 	loadbalance.UpstreamHeaderTimeout = params.UpstreamHeaderTimeout
 	loadbalance.UpstreamIdleTimeout = params.UpstreamIdleTimeout
 	loadbalance.UpstreamMaxIdle = params.UpstreamMaxIdle
+	loadbalance.UpstreamRequestTimeout = params.UpstreamRequestTimeout
+	loadbalance.UpstreamStreamIdleTimeout = params.UpstreamStreamIdleTimeout
+	loadbalance.UpstreamTlsCaFile = params.UpstreamTlsCaFile
+	loadbalance.UpstreamTlsCertFile = params.UpstreamTlsCertFile
+	loadbalance.UpstreamTlsKeyFile = params.UpstreamTlsKeyFile
+	loadbalance.UpstreamTlsServerName = params.UpstreamTlsServerName
+	loadbalance.UpstreamTlsMinVersion = params.UpstreamTlsMinVersion
+	loadbalance.UpstreamTlsInsecure = params.UpstreamTlsInsecure
 	if err := loadbalance.Save(c, tx); err != nil {
 		tx.Rollback()
 		middleware.ResponseError(c, 2008, err)
 		return
 	}
+
+	oidcAuth := serviceDetial.OidcAuth
+	oidcAuth.ServiceID = serviceDetial.Info.ID
+	oidcAuth.OpenOidc = params.OpenOidc
+	oidcAuth.Issuer = params.OidcIssuer
+	oidcAuth.Audience = params.OidcAudience
+	oidcAuth.JwksUrl = params.OidcJwksUrl
+	oidcAuth.StaticKeys = params.OidcStaticKeys
+	oidcAuth.JwksRefresh = params.OidcJwksRefresh
+	oidcAuth.RequiredClaims = params.OidcRequiredClaims
+	oidcAuth.AppClaim = params.OidcAppClaim
+	oidcAuth.ClaimHeaders = params.OidcClaimHeaders
+	if err := oidcAuth.Save(c, tx); err != nil {
+		tx.Rollback()
+		middleware.ResponseError(c, 2009, err)
+		return
+	}
+
+	forwardAuth := serviceDetial.ForwardAuth
+	forwardAuth.ServiceID = serviceDetial.Info.ID
+	forwardAuth.OpenForwardAuth = params.OpenForwardAuth
+	forwardAuth.AuthUrl = params.ForwardAuthUrl
+	forwardAuth.RequestHeaders = params.ForwardAuthRequestHeaders
+	forwardAuth.ResponseHeaders = params.ForwardAuthResponseHeaders
+	forwardAuth.CacheTtl = params.ForwardAuthCacheTtl
+	forwardAuth.Timeout = params.ForwardAuthTimeout
+	if err := forwardAuth.Save(c, tx); err != nil {
+		tx.Rollback()
+		middleware.ResponseError(c, 2010, err)
+		return
+	}
 	tx.Commit()
 	middleware.ResponseSuccess(c, "")
 
@@ -412,6 +730,47 @@ func (administer *ServiceController) Servicestat(c *gin.Context) {
 	})
 }
 
+// ServiceStatRange godoc
+// @Summary 服务分时段统计
+// @Description 最近1h/24h/7d的状态码、下游错误与耗时分位数
+// @ID /Service/Service_stat_range
+// @Tags 服务管理
+// @Accept json
+// @Produce json
+// @Param id query string true "服务ID"
+// @Param range query string true "时间范围 1h/24h/7d"
+// @Success 200 {object} middleware.Response{data=public.FlowStatRange}  "success"
+// @Router /Service/Service_stat_range [get]
+func (administer *ServiceController) ServiceStatRange(c *gin.Context) {
+	params := &dto.ServiceStatRangeInput{}
+	if err := params.BindValidParam(c); err != nil {
+		middleware.ResponseError(c, 2000, err)
+		return
+	}
+	tx, err := lib.GetGormPool("default")
+	if err != nil {
+		middleware.ResponseError(c, 2001, err)
+		return
+	}
+	serviceInfo := &dao.ServiceInfo{ID: params.ID}
+	serviceInfo, err = serviceInfo.Find(c, tx, serviceInfo)
+	if err != nil {
+		middleware.ResponseError(c, 2002, err)
+		return
+	}
+	counter, err := public.FlowCounterHandler.GetCounter(public.FlowServicePrefix + serviceInfo.ServiceName)
+	if err != nil {
+		middleware.ResponseError(c, 2003, err)
+		return
+	}
+	out, err := counter.GetStatRange(params.Range, time.Now())
+	if err != nil {
+		middleware.ResponseError(c, 2004, err)
+		return
+	}
+	middleware.ResponseSuccess(c, out)
+}
+
 // ServiceAddHttp godoc
 // @Summary tcp服务添加
 // @Description tcp服务添加
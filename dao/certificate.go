@@ -0,0 +1,207 @@
+package dao
+
+import (
+	"FGateWay/dto"
+	"FGateWay/golang_common/lib"
+	"FGateWay/public"
+	"crypto/tls"
+	"fmt"
+	"github.com/e421083458/gorm"
+	"github.com/gin-gonic/gin"
+	"log"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+type Certificate struct {
+	ID        int64     `json:"id" gorm:"primary_key"`
+	Name      string    `json:"name" gorm:"column:name" description:"证书名称"`
+	Domains   string    `json:"domains" gorm:"column:domains" description:"证书域名，逗号间隔"`
+	CertPem   string    `json:"cert_pem" gorm:"column:cert_pem" description:"PEM证书链"`
+	KeyPem    string    `json:"-" gorm:"column:key_pem" description:"加密后的PEM私钥"`
+	IsDefault int       `json:"is_default" gorm:"column:is_default" description:"是否默认证书 1=是"`
+	Source    string    `json:"source" gorm:"column:source" description:"证书来源 upload=上传 acme=自动签发"`
+	NotBefore time.Time `json:"not_before" gorm:"column:not_before" description:"生效时间"`
+	NotAfter  time.Time `json:"not_after" gorm:"column:not_after" description:"过期时间"`
+	CreatedAt time.Time `json:"create_at" gorm:"column:create_at" description:"添加时间"`
+	UpdatedAt time.Time `json:"update_at" gorm:"column:update_at" description:"更新时间"`
+	IsDelete  int8      `json:"is_delete" gorm:"column:is_delete" description:"是否已删除；0：否；1：是"`
+}
+
+func (t *Certificate) TableName() string {
+	return "gateway_certificate"
+}
+
+func (t *Certificate) Find(c *gin.Context, tx *gorm.DB, search *Certificate) (*Certificate, error) {
+	model := &Certificate{}
+	err := tx.SetCtx(public.GetGinTraceContext(c)).Where(search).Where("is_delete=0").Find(model).Error
+	return model, err
+}
+
+func (t *Certificate) Save(c *gin.Context, tx *gorm.DB) error {
+	if err := tx.SetCtx(public.GetGinTraceContext(c)).Save(t).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+// ClearDefault 取消其他证书的默认标记
+func (t *Certificate) ClearDefault(c *gin.Context, tx *gorm.DB) error {
+	return tx.SetCtx(public.GetGinTraceContext(c)).Table(t.TableName()).
+		Where("is_default=1 and id<>?", t.ID).Update("is_default", 0).Error
+}
+
+func (t *Certificate) CertList(c *gin.Context, tx *gorm.DB, params *dto.CertListInput) ([]Certificate, int64, error) {
+	var list []Certificate
+	var count int64
+	offset := (params.PageNo - 1) * params.PageSize
+	query := tx.SetCtx(public.GetGinTraceContext(c))
+	query = query.Table(t.TableName()).Select("*")
+	query = query.Where("is_delete=?", 0)
+	if params.Info != "" {
+		query = query.Where(" (name like ? or domains like ?)", "%"+params.Info+"%", "%"+params.Info+"%")
+	}
+	err := query.Limit(params.PageSize).Offset(offset).Order("id desc").Find(&list).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return nil, 0, err
+	}
+	if err := query.Count(&count).Error; err != nil {
+		return nil, 0, err
+	}
+	return list, count, nil
+}
+
+var CertManagerHandler *CertManager
+
+func init() {
+	CertManagerHandler = NewCertManager()
+}
+
+// CertManager 代理https按SNI选择证书，定时从数据库热加载
+type CertManager struct {
+	CertMap     map[string]*tls.Certificate
+	UploadMap   map[string]bool //由上传证书覆盖的域名
+	DefaultCert *tls.Certificate
+	Locker      sync.RWMutex
+	Revision    string
+}
+
+func NewCertManager() *CertManager {
+	return &CertManager{
+		CertMap:   map[string]*tls.Certificate{},
+		UploadMap: map[string]bool{},
+		Locker:    sync.RWMutex{},
+	}
+}
+
+// Reload 读取全部证书，内容未变化时跳过；单个证书无法解析时跳过该证书
+func (s *CertManager) Reload() error {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	tx, err := lib.GetGormPool("default")
+	if err != nil {
+		return err
+	}
+	info := &Certificate{}
+	list, _, err := info.CertList(c, tx, &dto.CertListInput{PageNo: 1, PageSize: 99999})
+	if err != nil {
+		return err
+	}
+	s.load(list)
+	return nil
+}
+
+// load 按证书列表重建SNI映射
+func (s *CertManager) load(list []Certificate) {
+	versions := []string{}
+	for _, item := range list {
+		versions = append(versions, fmt.Sprintf("%d-%d-%d", item.ID, item.UpdatedAt.UnixNano(), item.IsDefault))
+	}
+	revision := GetRevision(versions)
+	s.Locker.RLock()
+	unchanged := revision == s.Revision
+	s.Locker.RUnlock()
+	if unchanged {
+		return
+	}
+
+	certMap := map[string]*tls.Certificate{}
+	uploadMap := map[string]bool{}
+	var defaultCert *tls.Certificate
+	//上传的证书优先于自动签发的证书，同来源按id倒序，同一域名以最新的证书为准
+	sort.SliceStable(list, func(i, j int) bool {
+		return list[i].Source != public.CertSourceAcme && list[j].Source == public.CertSourceAcme
+	})
+	for _, item := range list {
+		keyPem, err := public.DecryptCertKey(item.KeyPem)
+		if err != nil {
+			log.Printf(" [ERROR] load certificate %s err:%v\n", item.Name, err)
+			continue
+		}
+		cert, certInfo, err := public.ParseCertPair(item.CertPem, keyPem)
+		if err != nil {
+			log.Printf(" [ERROR] load certificate %s err:%v\n", item.Name, err)
+			continue
+		}
+		for _, domain := range certInfo.Domains {
+			if _, ok := certMap[domain]; !ok {
+				certMap[domain] = cert
+				uploadMap[domain] = item.Source != public.CertSourceAcme
+			}
+		}
+		if item.IsDefault == 1 && defaultCert == nil {
+			defaultCert = cert
+		}
+	}
+
+	s.Locker.Lock()
+	defer s.Locker.Unlock()
+	s.CertMap = certMap
+	s.UploadMap = uploadMap
+	s.DefaultCert = defaultCert
+	s.Revision = revision
+}
+
+// WatchReload 定时检查证书变更
+func (s *CertManager) WatchReload(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		if err := s.Reload(); err != nil {
+			log.Printf(" [ERROR] reload certificate err:%v\n", err)
+		}
+	}
+}
+
+// HasUploadCert SNI是否有上传的证书，有则不再自动签发
+func (s *CertManager) HasUploadCert(serverName string) bool {
+	serverName = strings.ToLower(strings.TrimSuffix(serverName, "."))
+	s.Locker.RLock()
+	defer s.Locker.RUnlock()
+	if s.UploadMap[serverName] {
+		return true
+	}
+	idx := strings.Index(serverName, ".")
+	return idx > 0 && s.UploadMap["*"+serverName[idx:]]
+}
+
+// GetCertificate 用于tls.Config，先精确匹配SNI，再匹配通配符证书，最后使用默认证书。
+// 返回nil时由tls使用配置文件中的证书兜底
+func (s *CertManager) GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	serverName := strings.ToLower(strings.TrimSuffix(hello.ServerName, "."))
+	s.Locker.RLock()
+	defer s.Locker.RUnlock()
+	if serverName != "" {
+		if cert, ok := s.CertMap[serverName]; ok {
+			return cert, nil
+		}
+		if idx := strings.Index(serverName, "."); idx > 0 {
+			if cert, ok := s.CertMap["*"+serverName[idx:]]; ok {
+				return cert, nil
+			}
+		}
+	}
+	return s.DefaultCert, nil
+}
@@ -0,0 +1,298 @@
+package dao
+
+import (
+	"FGateWay/golang_common/lib"
+	"FGateWay/public"
+	"context"
+	"fmt"
+	"github.com/e421083458/gorm"
+	"github.com/garyburd/redigo/redis"
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/acme/autocert"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	// AcmeLockExpire 签发锁的过期时间，与autocert单次续期的超时一致，持有者异常退出后其他实例接手
+	AcmeLockExpire = 600
+	// acmeRenewJitter autocert在到期前RenewBefore再随机提前至多1小时续期
+	acmeRenewJitter = time.Hour
+)
+
+// acmeLockPoll 等待其他实例签发时重新读取证书的间隔
+var acmeLockPoll = time.Second
+
+// AcmeCache 实现autocert.Cache，所有代理实例共用：
+// 签发的证书写入证书库(source=acme)，账户密钥与http-01 token存redis。
+// 证书不存在或进入续期时间时，只有拿到redis锁的实例向ACME服务下单，
+// 其他实例等待其写入后直接使用，避免每个实例各自续期触发CA的频率限制
+type AcmeCache struct {
+	RenewBefore time.Duration
+	kv          acmeKV
+	certs       acmeCertStore
+	lockValue   string
+}
+
+// acmeKV 账户密钥、http-01 token与签发锁的存储
+type acmeKV interface {
+	Get(key string) ([]byte, error) //不存在时返回nil
+	Set(key string, data []byte, expire int) error
+	Del(key string) error
+	Lock(key, value string, expire int) (bool, error)
+	Unlock(key, value string) error
+}
+
+// acmeCertStore 自动签发证书的存储，不存在时返回gorm.ErrRecordNotFound
+type acmeCertStore interface {
+	Find(name string) (*Certificate, error)
+	Save(cert *Certificate) error
+}
+
+func NewAcmeCache(renewBefore time.Duration) *AcmeCache {
+	hostname, _ := os.Hostname()
+	return &AcmeCache{
+		RenewBefore: renewBefore,
+		kv:          &redisAcmeKV{},
+		certs:       &dbAcmeCertStore{},
+		lockValue:   fmt.Sprintf("%s_%d_%d", hostname, os.Getpid(), time.Now().UnixNano()),
+	}
+}
+
+// 账户密钥与http-01 token以外的key均为证书，形如 www.test.com 或 www.test.com+rsa
+func isAcmeCertKey(key string) bool {
+	return !strings.HasPrefix(key, "acme_account") && !strings.HasSuffix(key, "+http-01")
+}
+
+func acmeRedisKey(key string) string {
+	return public.RedisAcmeCacheKey + "_" + key
+}
+
+func acmeLockKey(key string) string {
+	return public.RedisAcmeLockKey + "_" + key
+}
+
+func (a *AcmeCache) Get(ctx context.Context, key string) ([]byte, error) {
+	if !isAcmeCertKey(key) {
+		data, err := a.kv.Get(acmeRedisKey(key))
+		if err == nil && data == nil {
+			return nil, autocert.ErrCacheMiss
+		}
+		return data, err
+	}
+	for {
+		data, due, err := a.getCert(key)
+		if err != nil || !due {
+			return data, err
+		}
+		//redis不可用时不做协调，由本实例下单
+		locked, err := a.kv.Lock(acmeLockKey(key), a.lockValue, AcmeLockExpire)
+		if err != nil || locked {
+			if data == nil {
+				return nil, autocert.ErrCacheMiss
+			}
+			return data, nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(acmeLockPoll):
+		}
+	}
+}
+
+// getCert 读取证书，due表示证书不存在或已进入续期时间，autocert会为其下单
+func (a *AcmeCache) getCert(key string) ([]byte, bool, error) {
+	cert, err := a.certs.Find(key)
+	if err == gorm.ErrRecordNotFound {
+		return nil, true, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	keyPem, err := public.DecryptCertKey(cert.KeyPem)
+	if err != nil {
+		return nil, false, err
+	}
+	due := time.Until(cert.NotAfter) <= a.RenewBefore+acmeRenewJitter
+	//autocert要求私钥在前、证书链在后
+	return []byte(keyPem + cert.CertPem), due, nil
+}
+
+func (a *AcmeCache) Put(ctx context.Context, key string, data []byte) error {
+	if !isAcmeCertKey(key) {
+		expire := 0
+		if strings.HasSuffix(key, "+http-01") {
+			expire = 3600
+		}
+		return a.kv.Set(acmeRedisKey(key), data, expire)
+	}
+	certPem, keyPem, err := public.SplitCertKeyPem(data)
+	if err != nil {
+		return err
+	}
+	_, certInfo, err := public.ParseCertPair(certPem, keyPem)
+	if err != nil {
+		return err
+	}
+	encryptedKey, err := public.EncryptCertKey(keyPem)
+	if err != nil {
+		return err
+	}
+	cert, err := a.certs.Find(key)
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return err
+	}
+	if err == gorm.ErrRecordNotFound {
+		cert = &Certificate{Name: key, Source: public.CertSourceAcme}
+	}
+	cert.CertPem = certPem
+	cert.KeyPem = encryptedKey
+	cert.Domains = strings.Join(certInfo.Domains, ",")
+	cert.NotBefore = certInfo.NotBefore
+	cert.NotAfter = certInfo.NotAfter
+	if err := a.certs.Save(cert); err != nil {
+		return err
+	}
+	//写入后释放签发锁，等待的实例读到新证书
+	return a.kv.Unlock(acmeLockKey(key), a.lockValue)
+}
+
+func (a *AcmeCache) Delete(ctx context.Context, key string) error {
+	if !isAcmeCertKey(key) {
+		return a.kv.Del(acmeRedisKey(key))
+	}
+	cert, err := a.certs.Find(key)
+	if err == gorm.ErrRecordNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	cert.IsDelete = 1
+	return a.certs.Save(cert)
+}
+
+// redisAcmeKV acmeKV的redis实现
+type redisAcmeKV struct{}
+
+func (r *redisAcmeKV) Get(key string) ([]byte, error) {
+	data, err := redis.Bytes(public.RedisConfDo("GET", key))
+	if err == redis.ErrNil {
+		return nil, nil
+	}
+	return data, err
+}
+
+func (r *redisAcmeKV) Set(key string, data []byte, expire int) error {
+	args := []interface{}{key, data}
+	if expire > 0 {
+		args = append(args, "EX", expire)
+	}
+	_, err := public.RedisConfDo("SET", args...)
+	return err
+}
+
+func (r *redisAcmeKV) Del(key string) error {
+	_, err := public.RedisConfDo("DEL", key)
+	return err
+}
+
+// 加锁或已由本实例持有时返回true
+var acmeLockScript = redis.NewScript(1, `
+if redis.call('SET', KEYS[1], ARGV[1], 'NX', 'EX', ARGV[2]) then
+	return 1
+end
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	redis.call('EXPIRE', KEYS[1], ARGV[2])
+	return 1
+end
+return 0
+`)
+
+// 只释放本实例持有的锁
+var acmeUnlockScript = redis.NewScript(1, `
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0
+`)
+
+func (r *redisAcmeKV) Lock(key, value string, expire int) (bool, error) {
+	c, err := lib.RedisConnFactory("default")
+	if err != nil {
+		return false, err
+	}
+	defer c.Close()
+	return redis.Bool(acmeLockScript.Do(c, key, value, expire))
+}
+
+func (r *redisAcmeKV) Unlock(key, value string) error {
+	c, err := lib.RedisConnFactory("default")
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	_, err = acmeUnlockScript.Do(c, key, value)
+	return err
+}
+
+// dbAcmeCertStore acmeCertStore的证书库实现
+type dbAcmeCertStore struct{}
+
+func (d *dbAcmeCertStore) Find(name string) (*Certificate, error) {
+	c, tx, err := acmeDB()
+	if err != nil {
+		return nil, err
+	}
+	return findAcmeCert(c, tx, name)
+}
+
+func (d *dbAcmeCertStore) Save(cert *Certificate) error {
+	c, tx, err := acmeDB()
+	if err != nil {
+		return err
+	}
+	return cert.Save(c, tx)
+}
+
+func acmeDB() (*gin.Context, *gorm.DB, error) {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	tx, err := lib.GetGormPool("default")
+	return c, tx, err
+}
+
+func findAcmeCert(c *gin.Context, tx *gorm.DB, name string) (*Certificate, error) {
+	model := &Certificate{}
+	err := tx.SetCtx(public.GetGinTraceContext(c)).
+		Where("name=? and source=? and is_delete=0", name, public.CertSourceAcme).
+		First(model).Error
+	return model, err
+}
+
+// AcmeHostAllowed 只为开启https的域名接入服务自动签发证书
+func AcmeHostAllowed(host string) bool {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	for _, serviceItem := range ServiceManagerHandler.GetServiceList() {
+		if serviceItem.Info.LoadType != public.LoadTypeHTTP {
+			continue
+		}
+		if serviceItem.HTTPRule.RuleType == public.HTTPRuleTypeDomain &&
+			serviceItem.HTTPRule.NeedHttps == 1 &&
+			strings.ToLower(serviceItem.HTTPRule.Rule) == host {
+			return true
+		}
+	}
+	return false
+}
+
+// AcmeHostPolicy 用于autocert.Manager.HostPolicy
+func AcmeHostPolicy(ctx context.Context, host string) error {
+	if !AcmeHostAllowed(host) {
+		return errors.Errorf("acme: host %s not allowed", host)
+	}
+	return nil
+}
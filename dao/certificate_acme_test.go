@@ -0,0 +1,263 @@
+package dao
+
+import (
+	"FGateWay/golang_common/lib"
+	"FGateWay/public"
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"github.com/e421083458/gorm"
+	"github.com/spf13/viper"
+	"golang.org/x/crypto/acme/autocert"
+	"math/big"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testAcmeKV 内存实现的acmeKV，记录写入时的过期时间
+type testAcmeKV struct {
+	locker sync.Mutex
+	data   map[string][]byte
+	expire map[string]int
+}
+
+func newTestAcmeKV() *testAcmeKV {
+	return &testAcmeKV{data: map[string][]byte{}, expire: map[string]int{}}
+}
+
+func (m *testAcmeKV) Get(key string) ([]byte, error) {
+	m.locker.Lock()
+	defer m.locker.Unlock()
+	return m.data[key], nil
+}
+
+func (m *testAcmeKV) Set(key string, data []byte, expire int) error {
+	m.locker.Lock()
+	defer m.locker.Unlock()
+	m.data[key] = data
+	m.expire[key] = expire
+	return nil
+}
+
+func (m *testAcmeKV) Del(key string) error {
+	m.locker.Lock()
+	defer m.locker.Unlock()
+	delete(m.data, key)
+	return nil
+}
+
+func (m *testAcmeKV) Lock(key, value string, expire int) (bool, error) {
+	m.locker.Lock()
+	defer m.locker.Unlock()
+	if holder, ok := m.data[key]; ok && string(holder) != value {
+		return false, nil
+	}
+	m.data[key] = []byte(value)
+	m.expire[key] = expire
+	return true, nil
+}
+
+func (m *testAcmeKV) Unlock(key, value string) error {
+	m.locker.Lock()
+	defer m.locker.Unlock()
+	if string(m.data[key]) == value {
+		delete(m.data, key)
+	}
+	return nil
+}
+
+// testAcmeCertStore 内存实现的acmeCertStore
+type testAcmeCertStore struct {
+	locker sync.Mutex
+	certs  map[string]Certificate
+}
+
+func (m *testAcmeCertStore) Find(name string) (*Certificate, error) {
+	m.locker.Lock()
+	defer m.locker.Unlock()
+	cert, ok := m.certs[name]
+	if !ok || cert.IsDelete == 1 {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &cert, nil
+}
+
+func (m *testAcmeCertStore) Save(cert *Certificate) error {
+	m.locker.Lock()
+	defer m.locker.Unlock()
+	m.certs[cert.Name] = *cert
+	return nil
+}
+
+// testAcmePem 生成autocert写入缓存的格式：私钥在前、证书在后
+func testAcmePem(t *testing.T, domain string, notAfter time.Time) []byte {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: domain},
+		DNSNames:     []string{domain},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     notAfter,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := &bytes.Buffer{}
+	pem.Encode(buf, &pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer})
+	pem.Encode(buf, &pem.Block{Type: "CERTIFICATE", Bytes: der})
+	return buf.Bytes()
+}
+
+func newTestAcmeCache(kv *testAcmeKV, certs *testAcmeCertStore, lockValue string) *AcmeCache {
+	return &AcmeCache{RenewBefore: 30 * 24 * time.Hour, kv: kv, certs: certs, lockValue: lockValue}
+}
+
+func TestAcmeCacheKeyRouting(t *testing.T) {
+	conf := viper.New()
+	conf.Set("cert.secret_key", "test_secret")
+	lib.ViperConfMap = map[string]*viper.Viper{"base": conf}
+	defer func() { lib.ViperConfMap = nil }()
+
+	ctx := context.Background()
+	kv := newTestAcmeKV()
+	certs := &testAcmeCertStore{certs: map[string]Certificate{}}
+	cache := newTestAcmeCache(kv, certs, "instance_a")
+
+	//账户密钥长期保存，http-01 token一小时过期，都存redis
+	if err := cache.Put(ctx, "acme_account+key", []byte("account")); err != nil {
+		t.Fatal(err)
+	}
+	if err := cache.Put(ctx, "token_a+http-01", []byte("token")); err != nil {
+		t.Fatal(err)
+	}
+	if kv.expire[acmeRedisKey("acme_account+key")] != 0 || kv.expire[acmeRedisKey("token_a+http-01")] != 3600 {
+		t.Fatalf("unexpected expire %v", kv.expire)
+	}
+	if data, err := cache.Get(ctx, "token_a+http-01"); err != nil || string(data) != "token" {
+		t.Fatalf("get token %s %v", data, err)
+	}
+	if err := cache.Delete(ctx, "token_a+http-01"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cache.Get(ctx, "token_a+http-01"); err != autocert.ErrCacheMiss {
+		t.Fatalf("expect cache miss, got %v", err)
+	}
+	if len(certs.certs) != 0 {
+		t.Fatal("non cert keys should not be written to certificate store")
+	}
+
+	//证书写入证书库，私钥加密保存
+	data := testAcmePem(t, "a.example.com", time.Now().Add(90*24*time.Hour))
+	if err := cache.Put(ctx, "a.example.com+rsa", data); err != nil {
+		t.Fatal(err)
+	}
+	cert, err := certs.Find("a.example.com+rsa")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cert.Source != public.CertSourceAcme || cert.Domains != "a.example.com" || !strings.HasPrefix(cert.CertPem, "-----BEGIN CERTIFICATE") {
+		t.Fatalf("unexpected certificate %+v", cert)
+	}
+	if strings.Contains(cert.KeyPem, "PRIVATE KEY") {
+		t.Fatal("private key should be encrypted")
+	}
+	if _, ok := kv.data[acmeRedisKey("a.example.com+rsa")]; ok {
+		t.Fatal("certificate should not be written to redis")
+	}
+	if got, err := cache.Get(ctx, "a.example.com+rsa"); err != nil || !bytes.Equal(got, data) {
+		t.Fatalf("get certificate %v", err)
+	}
+	if err := cache.Delete(ctx, "a.example.com+rsa"); err != nil {
+		t.Fatal(err)
+	}
+	if certs.certs["a.example.com+rsa"].IsDelete != 1 {
+		t.Fatal("certificate should be soft deleted")
+	}
+	if _, err := cache.Get(ctx, "a.example.com+rsa"); err != autocert.ErrCacheMiss {
+		t.Fatalf("expect cache miss, got %v", err)
+	}
+}
+
+func TestAcmeCacheRenewLock(t *testing.T) {
+	conf := viper.New()
+	conf.Set("cert.secret_key", "test_secret")
+	lib.ViperConfMap = map[string]*viper.Viper{"base": conf}
+	defer func() { lib.ViperConfMap = nil }()
+	poll := acmeLockPoll
+	acmeLockPoll = 10 * time.Millisecond
+	defer func() { acmeLockPoll = poll }()
+
+	ctx := context.Background()
+	kv := newTestAcmeKV()
+	certs := &testAcmeCertStore{certs: map[string]Certificate{}}
+	a := newTestAcmeCache(kv, certs, "instance_a")
+	b := newTestAcmeCache(kv, certs, "instance_b")
+
+	//首次签发：a拿到锁后由autocert下单，b等待a写入
+	if _, err := a.Get(ctx, "b.example.com"); err != autocert.ErrCacheMiss {
+		t.Fatalf("expect cache miss, got %v", err)
+	}
+	if _, err := a.Get(ctx, "b.example.com"); err != autocert.ErrCacheMiss {
+		t.Fatal("lock holder should not wait for itself")
+	}
+	issued := testAcmePem(t, "b.example.com", time.Now().Add(90*24*time.Hour))
+	result := make(chan []byte)
+	go func() {
+		data, err := b.Get(ctx, "b.example.com")
+		if err != nil {
+			t.Error(err)
+		}
+		result <- data
+	}()
+	time.Sleep(50 * time.Millisecond)
+	if err := a.Put(ctx, "b.example.com", issued); err != nil {
+		t.Fatal(err)
+	}
+	if data := <-result; !bytes.Equal(data, issued) {
+		t.Fatal("waiting instance should use the certificate issued by lock holder")
+	}
+
+	//未到续期时间时直接返回，不加锁
+	if _, err := b.Get(ctx, "b.example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := kv.data[acmeLockKey("b.example.com")]; ok {
+		t.Fatal("fresh certificate should not be locked")
+	}
+
+	//进入续期时间：b拿到锁续期，a等待超时
+	due := testAcmePem(t, "b.example.com", time.Now().Add(10*24*time.Hour))
+	if err := b.Put(ctx, "b.example.com", due); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := b.Get(ctx, "b.example.com"); err != nil || !bytes.Equal(data, due) {
+		t.Fatalf("renewing instance should get the current certificate, err:%v", err)
+	}
+	timeout, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	if _, err := a.Get(timeout, "b.example.com"); err != context.DeadlineExceeded {
+		t.Fatalf("expect deadline exceeded, got %v", err)
+	}
+	renewed := testAcmePem(t, "b.example.com", time.Now().Add(90*24*time.Hour))
+	if err := b.Put(ctx, "b.example.com", renewed); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := a.Get(ctx, "b.example.com"); err != nil || !bytes.Equal(data, renewed) {
+		t.Fatalf("expect renewed certificate, err:%v", err)
+	}
+}
@@ -0,0 +1,116 @@
+package dao
+
+import (
+	"FGateWay/golang_common/lib"
+	"FGateWay/public"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"github.com/spf13/viper"
+	"math/big"
+	"testing"
+	"time"
+)
+
+func testCertificate(t *testing.T, id int64, source string, isDefault int, dnsNames ...string) Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(id),
+		Subject:      pkix.Name{CommonName: dnsNames[0]},
+		DNSNames:     dnsNames,
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(24 * time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyPem, err := public.EncryptCertKey(string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer})))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return Certificate{
+		ID:        id,
+		CertPem:   string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})),
+		KeyPem:    keyPem,
+		IsDefault: isDefault,
+		Source:    source,
+		UpdatedAt: time.Unix(id, 0),
+	}
+}
+
+func TestCertManagerGetCertificate(t *testing.T) {
+	conf := viper.New()
+	conf.Set("cert.secret_key", "test_secret")
+	lib.ViperConfMap = map[string]*viper.Viper{"base": conf}
+	defer func() { lib.ViperConfMap = nil }()
+
+	//列表按id倒序，与CertList一致
+	acme := testCertificate(t, 5, public.CertSourceAcme, 0, "a.example.com")
+	exact := testCertificate(t, 4, public.CertSourceUpload, 0, "a.example.com")
+	wildcard := testCertificate(t, 3, public.CertSourceUpload, 0, "*.example.com")
+	def := testCertificate(t, 2, public.CertSourceUpload, 1, "default.local")
+	broken := testCertificate(t, 1, public.CertSourceUpload, 0, "broken.example.com")
+	broken.KeyPem = "invalid"
+
+	manager := NewCertManager()
+	manager.load([]Certificate{acme, exact, wildcard, def, broken})
+	leaf := func(cert *tls.Certificate) string {
+		if cert == nil {
+			return ""
+		}
+		parsed, err := x509.ParseCertificate(cert.Certificate[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		return parsed.SerialNumber.String()
+	}
+	cases := []struct {
+		serverName string
+		serial     string
+	}{
+		{"a.example.com", "4"}, //上传的证书优先于自动签发
+		{"A.Example.com.", "4"},
+		{"b.example.com", "3"},
+		{"x.b.example.com", "2"}, //通配符只匹配一级
+		{"broken.example.com", "3"},
+		{"", "2"},
+	}
+	for _, tc := range cases {
+		cert, err := manager.GetCertificate(&tls.ClientHelloInfo{ServerName: tc.serverName})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if serial := leaf(cert); serial != tc.serial {
+			t.Fatalf("%q: expect cert %v, got %v", tc.serverName, tc.serial, serial)
+		}
+	}
+	if !manager.HasUploadCert("a.example.com") || !manager.HasUploadCert("c.example.com") || manager.HasUploadCert("example.org") {
+		t.Fatal("unexpected upload cert match")
+	}
+
+	//版本未变化时跳过，变化后重建
+	revision := manager.Revision
+	manager.load([]Certificate{acme, exact, wildcard, def, broken})
+	if manager.Revision != revision {
+		t.Fatal("revision should not change")
+	}
+	manager.load([]Certificate{acme})
+	if cert, _ := manager.GetCertificate(&tls.ClientHelloInfo{ServerName: "a.example.com"}); leaf(cert) != "5" {
+		t.Fatal("reload should replace certificates")
+	}
+	if cert, _ := manager.GetCertificate(&tls.ClientHelloInfo{ServerName: "b.example.com"}); cert != nil {
+		t.Fatal("no default certificate after reload")
+	}
+}
@@ -4,9 +4,11 @@ import (
 	"FGateWay/dto"
 	"FGateWay/golang_common/lib"
 	"FGateWay/public"
+	"encoding/json"
 	"github.com/e421083458/gorm"
 	"github.com/gin-gonic/gin"
 	"github.com/pkg/errors"
+	"log"
 	"net/http/httptest"
 	"strings"
 	"sync"
@@ -15,8 +17,27 @@ import (
 type ServiceDetail struct {
 	Info          *ServiceInfo   `json:"info" description:"基本信息"`
 	HTTPRule      *HttpRule      `json:"http_rule" description:"http_rule"`
+	TCPRule       *TcpRule       `json:"tcp_rule" description:"tcp_rule"`
+	GRPCRule      *GrpcRule      `json:"grpc_rule" description:"grpc_rule"`
 	LoadBalance   *LoadBalance   `json:"load_balance" description:"load_balance"`
 	AccessControl *AccessControl `json:"access_control" description:"access_control"`
+	OidcAuth      *OidcAuth      `json:"oidc_auth" description:"oidc_auth"`
+	ForwardAuth   *ForwardAuth   `json:"forward_auth" description:"forward_auth"`
+
+	transRevisionOnce sync.Once
+	transRevision     string
+}
+
+// TransportRevision 上游连接相关配置(负载均衡与上游https)的版本，服务加载时计算一次，配置变化后重建transport
+func (s *ServiceDetail) TransportRevision() string {
+	s.transRevisionOnce.Do(func() {
+		upstreamHttps := 0
+		if s.HTTPRule != nil {
+			upstreamHttps = s.HTTPRule.UpstreamHttps
+		}
+		s.transRevision = GetRevision([]interface{}{s.LoadBalance, upstreamHttps})
+	})
+	return s.transRevision
 }
 
 var ServiceManagerHandler *ServiceManager
@@ -31,6 +52,7 @@ type ServiceManager struct {
 	Locker       sync.RWMutex
 	init         sync.Once
 	err          error
+	Revision     string
 }
 
 func NewServiceManager() *ServiceManager {
@@ -49,6 +71,20 @@ func (t *ServiceInfo) GroupByLoadType(c *gin.Context, tx *gorm.DB) ([]dto.DashSe
 	}
 	return list, nil
 }
+
+// GetRevision 根据加载的配置内容生成版本号，配置变化时版本号随之变化
+func GetRevision(v interface{}) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return ""
+	}
+	return public.MD5(string(data))
+}
+
+func (s *ServiceManager) GetServiceList() []*ServiceDetail {
+	return s.ServiceSlice
+}
+
 func (s *ServiceManager) GetTcpServiceList() []*ServiceDetail {
 	list := []*ServiceDetail{}
 	for _, serverItem := range s.ServiceSlice {
@@ -143,12 +179,21 @@ func (s *ServiceManager) LoadOnce() error {
 				return
 			}
 
+			// 旧格式的url重写与header规则由后台启动时统一转为JSON(MigrateLegacyRules)，迁移前仍按旧格式解析。
+			if serviceDetail.HTTPRule != nil && serviceDetail.HTTPRule.ID > 0 {
+				if err := serviceDetail.HTTPRule.CompileRules(); err != nil {
+					log.Printf(" [ERROR] compile http rule of %s err:%v\n", listItem.ServiceName, err)
+				}
+			}
+			serviceDetail.TransportRevision()
+
 			// 保存服务的详细信息到 ServiceManager 的 ServiceMap 中，使用服务名称作为键。
 			s.ServiceMap[listItem.ServiceName] = serviceDetail
 
 			// 同时将服务的详细信息添加到 ServiceSlice 切片中。
 			s.ServiceSlice = append(s.ServiceSlice, serviceDetail)
 		}
+		s.Revision = GetRevision(s.ServiceSlice)
 	})
 
 	// 返ServiceManager 的 err 字段，如果有错误的话。
@@ -15,6 +15,8 @@ type AccessControl struct {
 	WhiteHostName     string `json:"white_host_name" gorm:"column:white_host_name" description:"白名单主机	"`
 	ClientIPFlowLimit int    `json:"clientip_flow_limit" gorm:"column:clientip_flow_limit" description:"客户端ip限流	"`
 	ServiceFlowLimit  int    `json:"service_flow_limit" gorm:"column:service_flow_limit" description:"服务端限流	"`
+	NeedClientCert    int    `json:"need_client_cert" gorm:"column:need_client_cert" description:"是否要求客户端证书 1=要求"`
+	ClientCertAppKey  string `json:"client_cert_app_key" gorm:"column:client_cert_app_key" description:"映射租户app_id的证书字段 cn=subject CN san=SAN"`
 }
 
 func (t *AccessControl) TableName() string {
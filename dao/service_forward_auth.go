@@ -0,0 +1,59 @@
+package dao
+
+import (
+	"FGateWay/public"
+	"github.com/e421083458/gorm"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strings"
+)
+
+type ForwardAuth struct {
+	ID              int64  `json:"id" gorm:"primary_key"`
+	ServiceID       int64  `json:"service_id" gorm:"column:service_id" description:"服务id"`
+	OpenForwardAuth int    `json:"open_forward_auth" gorm:"column:open_forward_auth" description:"是否开启外部鉴权 1=开启"`
+	AuthUrl         string `json:"auth_url" gorm:"column:auth_url" description:"外部鉴权服务地址"`
+	RequestHeaders  string `json:"request_headers" gorm:"column:request_headers" description:"透传给鉴权服务的header, 多个逗号间隔"`
+	ResponseHeaders string `json:"response_headers" gorm:"column:response_headers" description:"鉴权通过后复制到下游的header, 多个逗号间隔"`
+	CacheTtl        int    `json:"cache_ttl" gorm:"column:cache_ttl" description:"鉴权结果缓存时间, 单位s, 0=不缓存"`
+	Timeout         int    `json:"timeout" gorm:"column:timeout" description:"鉴权请求超时, 单位ms"`
+}
+
+func (t *ForwardAuth) TableName() string {
+	return "gateway_service_forward_auth"
+}
+
+func (t *ForwardAuth) Find(c *gin.Context, tx *gorm.DB, search *ForwardAuth) (*ForwardAuth, error) {
+	model := &ForwardAuth{}
+	err := tx.SetCtx(public.GetGinTraceContext(c)).Where(search).Find(model).Error
+	return model, err
+}
+
+func (t *ForwardAuth) Save(c *gin.Context, tx *gorm.DB) error {
+	if err := tx.SetCtx(public.GetGinTraceContext(c)).Save(t).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+func (t *ForwardAuth) GetRequestHeaders() []string {
+	if t.RequestHeaders == "" {
+		return []string{"Authorization", "Cookie"}
+	}
+	return splitHeaderList(t.RequestHeaders)
+}
+
+func (t *ForwardAuth) GetResponseHeaders() []string {
+	return splitHeaderList(t.ResponseHeaders)
+}
+
+func splitHeaderList(str string) []string {
+	list := []string{}
+	for _, item := range strings.Split(str, ",") {
+		item = strings.TrimSpace(item)
+		if item != "" {
+			list = append(list, http.CanonicalHeaderKey(item))
+		}
+	}
+	return list
+}
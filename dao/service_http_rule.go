@@ -1,9 +1,16 @@
 package dao
 
 import (
+	"FGateWay/golang_common/lib"
 	"FGateWay/public"
 	"github.com/e421083458/gorm"
 	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	"log"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
 )
 
 type HttpRule struct {
@@ -11,11 +18,206 @@ type HttpRule struct {
 	ServiceID      int64  `json:"service_id" gorm:"column:service_id" description:"服务id"`
 	RuleType       int    `json:"rule_type" gorm:"column:rule_type" description:"匹配类型 domain=域名, url_prefix=url前缀"`
 	Rule           string `json:"rule" gorm:"column:rule" description:"type=domain表示域名，type=url_prefix时表示url前缀"`
-	NeedHttps      int    `json:"need_https" gorm:"column:need_https" description:"type=支持https 1=支持"`
+	NeedHttps      int    `json:"need_https" gorm:"column:need_https" description:"客户端必须使用https 1=是，http请求跳转到https"`
 	NeedWebsocket  int    `json:"need_websocket" gorm:"column:need_websocket" description:"启用websocket 1=启用"`
+	NeedStream     int    `json:"need_stream" gorm:"column:need_stream" description:"流式/长轮询服务 1=不受全局写超时限制"`
 	NeedStripUri   int    `json:"need_strip_uri" gorm:"column:need_strip_uri" description:"启用strip_uri 1=启用"`
-	UrlRewrite     string `json:"url_rewrite" gorm:"column:url_rewrite" description:"url重写功能，每行一个	"`
-	HeaderTransfor string `json:"header_transfor" gorm:"column:header_transfor" description:"header转换支持增加(add)、删除(del)、修改(edit) 格式: add headname headvalue	"`
+	UrlRewrite     string `json:"url_rewrite" gorm:"column:url_rewrite" description:"url重写 JSON: [{\"match\":\"^/abc/(.*)\",\"replace\":\"/$1\"}]，兼容旧格式"`
+	HeaderTransfor string `json:"header_transfor" gorm:"column:header_transfor" description:"header转换 JSON: [{\"op\":\"set\",\"name\":\"X-Tenant\",\"value\":\"${app.id}\"}]，op为set/append/del，兼容旧格式"`
+	LogBody        int    `json:"log_body" gorm:"column:log_body" description:"记录请求体 1=开启"`
+	LogBodyMaxSize int    `json:"log_body_max_size" gorm:"column:log_body_max_size" description:"请求体最大记录字节 0=使用全局配置"`
+
+	MaxRequestBodySize  int `json:"max_request_body_size" gorm:"column:max_request_body_size" description:"请求体最大字节 0=不限制"`
+	MaxResponseBodySize int `json:"max_response_body_size" gorm:"column:max_response_body_size" description:"响应体最大字节 0=不限制"`
+	MaxHeaderCount      int `json:"max_header_count" gorm:"column:max_header_count" description:"请求header最大个数 0=不限制"`
+	MaxHeaderSize       int `json:"max_header_size" gorm:"column:max_header_size" description:"请求header最大字节 0=不限制"`
+
+	WebsocketMaxConn      int `json:"websocket_max_conn" gorm:"column:websocket_max_conn" description:"websocket最大连接数 0=不限制"`
+	WebsocketIdleTimeout  int `json:"websocket_idle_timeout" gorm:"column:websocket_idle_timeout" description:"websocket空闲超时, 单位s 0=不限制"`
+	WebsocketPingInterval int `json:"websocket_ping_interval" gorm:"column:websocket_ping_interval" description:"websocket ping间隔, 单位s 0=不发送"`
+
+	UpstreamHttps         int `json:"upstream_https" gorm:"column:upstream_https" description:"上游使用https 1=是"`
+	HstsMaxAge            int `json:"hsts_max_age" gorm:"column:hsts_max_age" description:"HSTS有效期, 单位s 0=不下发"`
+	HstsIncludeSubdomains int `json:"hsts_include_subdomains" gorm:"column:hsts_include_subdomains" description:"HSTS包含子域名 1=是"`
+
+	ResponseHeaderTransfor string `json:"response_header_transfor" gorm:"column:response_header_transfor" description:"响应header转换 格式同header_transfor"`
+	OpenCors               int    `json:"open_cors" gorm:"column:open_cors" description:"开启跨域 1=开启，预检请求由网关应答"`
+	CorsAllowOrigins       string `json:"cors_allow_origins" gorm:"column:cors_allow_origins" description:"允许的来源 支持*与通配子域名"`
+	CorsAllowMethods       string `json:"cors_allow_methods" gorm:"column:cors_allow_methods" description:"允许的方法 为空=常用方法"`
+	CorsAllowHeaders       string `json:"cors_allow_headers" gorm:"column:cors_allow_headers" description:"允许的请求header 为空=回显预检请求"`
+	CorsExposeHeaders      string `json:"cors_expose_headers" gorm:"column:cors_expose_headers" description:"暴露给浏览器的响应header"`
+	CorsAllowCredentials   int    `json:"cors_allow_credentials" gorm:"column:cors_allow_credentials" description:"允许携带凭证 1=允许"`
+	CorsMaxAge             int    `json:"cors_max_age" gorm:"column:cors_max_age" description:"预检结果缓存时间, 单位s"`
+
+	OpenCache           int    `json:"open_cache" gorm:"column:open_cache" description:"开启响应缓存 1=开启，只缓存GET/HEAD"`
+	CacheBackend        string `json:"cache_backend" gorm:"column:cache_backend" description:"缓存存储 memory=内存 redis=共享"`
+	CacheTtl            int    `json:"cache_ttl" gorm:"column:cache_ttl" description:"默认缓存时间, 单位s 上游未指定时使用"`
+	CacheForceTtl       int    `json:"cache_force_ttl" gorm:"column:cache_force_ttl" description:"忽略上游max-age 1=始终使用cache_ttl"`
+	CacheStaleTtl       int    `json:"cache_stale_ttl" gorm:"column:cache_stale_ttl" description:"过期后返回旧数据并后台刷新的时间, 单位s"`
+	CacheKeyIgnoreQuery int    `json:"cache_key_ignore_query" gorm:"column:cache_key_ignore_query" description:"缓存key忽略query 1=忽略"`
+	CacheKeyHeaders     string `json:"cache_key_headers" gorm:"column:cache_key_headers" description:"参与缓存key的请求header 多个逗号间隔"`
+	CacheKeyApp         int    `json:"cache_key_app" gorm:"column:cache_key_app" description:"缓存key区分租户 1=区分"`
+
+	OpenCompress         int    `json:"open_compress" gorm:"column:open_compress" description:"开启响应压缩 1=开启，按Accept-Encoding协商"`
+	CompressEncodings    string `json:"compress_encodings" gorm:"column:compress_encodings" description:"压缩编码 br,gzip按优先级排列 为空=br,gzip"`
+	CompressMinSize      int    `json:"compress_min_size" gorm:"column:compress_min_size" description:"最小压缩字节 0=1024"`
+	CompressContentTypes string `json:"compress_content_types" gorm:"column:compress_content_types" description:"允许压缩的content-type 多个逗号间隔 为空=常见文本类型"`
+	DecompressRequest    int    `json:"decompress_request" gorm:"column:decompress_request" description:"解压gzip/br请求体后转发 1=开启"`
+
+	//服务加载时编译，请求间共用；编译失败的规则集按空规则处理，不在请求中重复编译
+	compileOnce         sync.Once
+	compileErr          error
+	rewriteRules        []*public.RewriteRule
+	headerRules         []public.HeaderRule
+	responseHeaderRules []public.HeaderRule
+}
+
+func (t *HttpRule) GetCorsPolicy() *public.CorsPolicy {
+	return &public.CorsPolicy{
+		AllowOrigins:     t.CorsAllowOrigins,
+		AllowMethods:     t.CorsAllowMethods,
+		AllowHeaders:     t.CorsAllowHeaders,
+		ExposeHeaders:    t.CorsExposeHeaders,
+		AllowCredentials: t.CorsAllowCredentials == 1,
+		MaxAge:           t.CorsMaxAge,
+	}
+}
+
+func (t *HttpRule) GetCachePolicy() *public.CachePolicy {
+	return &public.CachePolicy{
+		Backend:        t.CacheBackend,
+		Ttl:            time.Duration(t.CacheTtl) * time.Second,
+		ForceTtl:       t.CacheForceTtl == 1,
+		StaleTtl:       time.Duration(t.CacheStaleTtl) * time.Second,
+		KeyIgnoreQuery: t.CacheKeyIgnoreQuery == 1,
+		KeyHeaders:     public.SplitHeaderNames(t.CacheKeyHeaders),
+		KeyApp:         t.CacheKeyApp == 1,
+	}
+}
+
+func (t *HttpRule) GetCompressPolicy() *public.CompressPolicy {
+	policy := &public.CompressPolicy{
+		Encodings:    public.DefaultCompressEncodings,
+		MinSize:      t.CompressMinSize,
+		ContentTypes: public.DefaultCompressContentTypes,
+	}
+	if encodings := strings.Split(strings.ToLower(t.CompressEncodings), ","); strings.TrimSpace(t.CompressEncodings) != "" {
+		policy.Encodings = []string{}
+		for _, encoding := range encodings {
+			if encoding = strings.TrimSpace(encoding); encoding != "" {
+				policy.Encodings = append(policy.Encodings, encoding)
+			}
+		}
+	}
+	if policy.MinSize <= 0 {
+		policy.MinSize = public.CompressDefaultMinSize
+	}
+	if strings.TrimSpace(t.CompressContentTypes) != "" {
+		policy.ContentTypes = strings.Split(t.CompressContentTypes, ",")
+	}
+	return policy
+}
+
+// NormalizeRules url重写与header规则的旧格式转为JSON，返回是否有变化
+func (t *HttpRule) NormalizeRules() (bool, error) {
+	urlRewrite, err := public.NormalizeUrlRewriteRules(t.UrlRewrite)
+	if err != nil {
+		return false, err
+	}
+	headerTransfor, err := public.NormalizeHeaderRules(t.HeaderTransfor)
+	if err != nil {
+		return false, err
+	}
+	responseHeaderTransfor, err := public.NormalizeHeaderRules(t.ResponseHeaderTransfor)
+	if err != nil {
+		return false, err
+	}
+	changed := urlRewrite != t.UrlRewrite || headerTransfor != t.HeaderTransfor || responseHeaderTransfor != t.ResponseHeaderTransfor
+	t.UrlRewrite = urlRewrite
+	t.HeaderTransfor = headerTransfor
+	t.ResponseHeaderTransfor = responseHeaderTransfor
+	return changed, nil
+}
+
+// MigrateLegacyRules 后台启动时把旧格式的url重写与header规则转为JSON写回数据库，已是JSON的不变，可重复执行
+func MigrateLegacyRules() (int, error) {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	tx, err := lib.GetGormPool("default")
+	if err != nil {
+		return 0, err
+	}
+	var list []HttpRule
+	if err := tx.SetCtx(public.GetGinTraceContext(c)).Find(&list).Error; err != nil && err != gorm.ErrRecordNotFound {
+		return 0, err
+	}
+	return migrateLegacyRules(list, func(rule *HttpRule) error {
+		return rule.Save(c, tx)
+	})
+}
+
+// migrateLegacyRules 逐条转换并保存有变化的规则，无法转换的规则记录日志后保留原值
+func migrateLegacyRules(list []HttpRule, save func(rule *HttpRule) error) (int, error) {
+	count := 0
+	for i := range list {
+		rule := &list[i]
+		changed, err := rule.NormalizeRules()
+		if err != nil {
+			log.Printf(" [ERROR] migrate http rule %d err:%v\n", rule.ID, err)
+			continue
+		}
+		if !changed {
+			continue
+		}
+		if err := save(rule); err != nil {
+			return count, errors.Wrapf(err, "save http rule %d", rule.ID)
+		}
+		count++
+	}
+	return count, nil
+}
+
+// CompileRules 编译url重写正则并解析header规则，服务加载时调用；各规则集独立编译，返回出错的规则集
+func (t *HttpRule) CompileRules() error {
+	t.compileOnce.Do(t.compile)
+	return t.compileErr
+}
+
+func (t *HttpRule) compile() {
+	errs := []string{}
+	rewriteRules, err := public.CompileUrlRewriteRules(t.UrlRewrite)
+	if err != nil {
+		errs = append(errs, "url_rewrite: "+err.Error())
+	}
+	headerRules, err := public.ParseHeaderRules(t.HeaderTransfor)
+	if err != nil {
+		errs = append(errs, "header_transfor: "+err.Error())
+	}
+	responseHeaderRules, err := public.ParseHeaderRules(t.ResponseHeaderTransfor)
+	if err != nil {
+		errs = append(errs, "response_header_transfor: "+err.Error())
+	}
+	t.rewriteRules = rewriteRules
+	t.headerRules = headerRules
+	t.responseHeaderRules = responseHeaderRules
+	if len(errs) > 0 {
+		t.compileErr = errors.New(strings.Join(errs, "; "))
+	}
+}
+
+// GetUrlRewriteRules 未在加载时编译的首次使用时编译，无效的规则被跳过
+func (t *HttpRule) GetUrlRewriteRules() []*public.RewriteRule {
+	t.CompileRules()
+	return t.rewriteRules
+}
+
+func (t *HttpRule) GetHeaderRules() []public.HeaderRule {
+	t.CompileRules()
+	return t.headerRules
+}
+
+func (t *HttpRule) GetResponseHeaderRules() []public.HeaderRule {
+	t.CompileRules()
+	return t.responseHeaderRules
 }
 
 func (t *HttpRule) TableName() string {
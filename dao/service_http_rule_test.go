@@ -0,0 +1,64 @@
+package dao
+
+import (
+	"testing"
+)
+
+func TestHttpRuleCompileRules(t *testing.T) {
+	rule := &HttpRule{
+		UrlRewrite:             `[{"match":"^/abc/(.*)","replace":"/$1"}]`,
+		HeaderTransfor:         `[{"op":"move","name":"X-A"}]`,
+		ResponseHeaderTransfor: "add X-Gateway fgateway",
+	}
+	//请求header规则无效不影响其他规则集
+	if err := rule.CompileRules(); err == nil {
+		t.Fatal("expect error for invalid header rules")
+	}
+	if len(rule.GetUrlRewriteRules()) != 1 || len(rule.GetHeaderRules()) != 0 || len(rule.GetResponseHeaderRules()) != 1 {
+		t.Fatalf("unexpected rules %v %v %v", rule.GetUrlRewriteRules(), rule.GetHeaderRules(), rule.GetResponseHeaderRules())
+	}
+
+	//失败结果也被缓存，不在请求中重复编译
+	rule.HeaderTransfor = "add X-A 1"
+	if err := rule.CompileRules(); err == nil || len(rule.GetHeaderRules()) != 0 {
+		t.Fatal("compile result should be cached")
+	}
+
+	//未在加载时编译的首次使用时编译
+	lazy := &HttpRule{HeaderTransfor: "add X-A 1"}
+	if len(lazy.GetHeaderRules()) != 1 {
+		t.Fatal("rules should be compiled on first use")
+	}
+}
+
+func TestMigrateLegacyRules(t *testing.T) {
+	list := []HttpRule{
+		{ID: 1, UrlRewrite: "^/abc(.*) $1", HeaderTransfor: "add X-A 1,bad"},
+		{ID: 2, UrlRewrite: `[{"match":"^/abc/(.*)","replace":"/$1"}]`, ResponseHeaderTransfor: `[{"op":"del","name":"Server"}]`},
+		{ID: 3, UrlRewrite: "^/abc(.*)"},
+		{ID: 4},
+	}
+	saved := map[int64]*HttpRule{}
+	count, err := migrateLegacyRules(list, func(rule *HttpRule) error {
+		saved[rule.ID] = rule
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	//只保存旧格式的规则，旧格式中无效的单条规则与运行时一样被跳过
+	if count != 2 || len(saved) != 2 || saved[3].UrlRewrite != "[]" {
+		t.Fatalf("unexpected migrated %d %v", count, saved)
+	}
+	if saved[1].UrlRewrite != `[{"match":"^/abc(.*)","replace":"$1"}]` || saved[1].HeaderTransfor != `[{"op":"set","name":"X-A","value":"1"}]` {
+		t.Fatalf("unexpected migrated rule %+v", saved[1])
+	}
+
+	//再次执行没有变化
+	if count, err := migrateLegacyRules(list, func(rule *HttpRule) error {
+		t.Fatal("json rules should not be saved again")
+		return nil
+	}); err != nil || count != 0 {
+		t.Fatalf("unexpected result %d %v", count, err)
+	}
+}
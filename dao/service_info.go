@@ -55,12 +55,26 @@ func (t *ServiceInfo) ServiceDetail(c *gin.Context, tx *gorm.DB, search *Service
 	if err != nil && err != gorm.ErrRecordNotFound {
 		return nil, err
 	}
+	oidcAuth := &OidcAuth{ServiceID: search.ID}
+	oidcAuth, err = oidcAuth.Find(c, tx, oidcAuth)
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return nil, err
+	}
+	forwardAuth := &ForwardAuth{ServiceID: search.ID}
+	forwardAuth, err = forwardAuth.Find(c, tx, forwardAuth)
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return nil, err
+	}
 
 	detail := &ServiceDetail{
 		Info:          search,
 		HTTPRule:      httpRule,
+		TCPRule:       tcpRule,
+		GRPCRule:      grpcRule,
 		LoadBalance:   loadBalance,
 		AccessControl: accessControl,
+		OidcAuth:      oidcAuth,
+		ForwardAuth:   forwardAuth,
 	}
 	return detail, nil
 }
@@ -2,6 +2,7 @@ package dao
 
 import (
 	"fmt"
+	"log"
 	"net"
 	"net/http"
 	"strings"
@@ -29,6 +30,16 @@ type LoadBalance struct {
 	UpstreamHeaderTimeout  int `json:"upstream_header_timeout" gorm:"column:upstream_header_timeout" description:"下游获取header超时, 单位s	"`
 	UpstreamIdleTimeout    int `json:"upstream_idle_timeout" gorm:"column:upstream_idle_timeout" description:"下游链接最大空闲时间, 单位s	"`
 	UpstreamMaxIdle        int `json:"upstream_max_idle" gorm:"column:upstream_max_idle" description:"下游最大空闲链接数"`
+
+	UpstreamRequestTimeout    int `json:"upstream_request_timeout" gorm:"column:upstream_request_timeout" description:"请求总超时, 单位s 0=不限制"`
+	UpstreamStreamIdleTimeout int `json:"upstream_stream_idle_timeout" gorm:"column:upstream_stream_idle_timeout" description:"响应流空闲超时, 单位s 0=不限制"`
+
+	UpstreamTlsCaFile     string `json:"upstream_tls_ca_file" gorm:"column:upstream_tls_ca_file" description:"校验上游证书的CA文件 为空=系统根证书"`
+	UpstreamTlsCertFile   string `json:"upstream_tls_cert_file" gorm:"column:upstream_tls_cert_file" description:"双向认证客户端证书文件"`
+	UpstreamTlsKeyFile    string `json:"upstream_tls_key_file" gorm:"column:upstream_tls_key_file" description:"双向认证客户端私钥文件"`
+	UpstreamTlsServerName string `json:"upstream_tls_server_name" gorm:"column:upstream_tls_server_name" description:"覆盖上游SNI"`
+	UpstreamTlsMinVersion string `json:"upstream_tls_min_version" gorm:"column:upstream_tls_min_version" description:"最低TLS版本 1.0/1.1/1.2/1.3"`
+	UpstreamTlsInsecure   int    `json:"upstream_tls_insecure" gorm:"column:upstream_tls_insecure" description:"跳过上游证书校验 1=是，仅用于测试"`
 }
 
 func (t *LoadBalance) TableName() string {
@@ -48,6 +59,17 @@ func (t *LoadBalance) Save(c *gin.Context, tx *gorm.DB) error {
 	return nil
 }
 
+func (t *LoadBalance) GetUpstreamTLSConf() *public.UpstreamTLSConf {
+	return &public.UpstreamTLSConf{
+		CaFile:     t.UpstreamTlsCaFile,
+		CertFile:   t.UpstreamTlsCertFile,
+		KeyFile:    t.UpstreamTlsKeyFile,
+		ServerName: t.UpstreamTlsServerName,
+		MinVersion: t.UpstreamTlsMinVersion,
+		Insecure:   t.UpstreamTlsInsecure == 1,
+	}
+}
+
 func (t *LoadBalance) GetIPListByModel() []string {
 	return strings.Split(t.IpList, ",")
 }
@@ -66,6 +88,7 @@ type LoadBalancer struct {
 
 type LoadBalancerItem struct {
 	LoadBanlance load_balance.LoadBalance
+	CheckConf    *load_balance.LoadBalanceCheckConf
 	ServiceName  string
 }
 
@@ -88,7 +111,7 @@ func (lbr *LoadBalancer) GetLoadBalancer(service *ServiceDetail) (load_balance.L
 		}
 	}
 	schema := "http://"
-	if service.HTTPRule.NeedHttps == 1 {
+	if service.HTTPRule.UpstreamHttps == 1 {
 		schema = "https://"
 	}
 	if service.Info.LoadType == public.LoadTypeTCP || service.Info.LoadType == public.LoadTypeGRPC {
@@ -110,6 +133,7 @@ func (lbr *LoadBalancer) GetLoadBalancer(service *ServiceDetail) (load_balance.L
 	//save to map and slice
 	lbItem := &LoadBalancerItem{
 		LoadBanlance: lb,
+		CheckConf:    mConf,
 		ServiceName:  service.Info.ServiceName,
 	}
 	lbr.LoadBanlanceSlice = append(lbr.LoadBanlanceSlice, lbItem)
@@ -120,17 +144,68 @@ func (lbr *LoadBalancer) GetLoadBalancer(service *ServiceDetail) (load_balance.L
 	return lb, nil
 }
 
+// GetUpstreamHealth 返回 服务名 => 节点 => 是否存活
+func (lbr *LoadBalancer) GetUpstreamHealth() map[string]map[string]bool {
+	lbr.Locker.RLock()
+	defer lbr.Locker.RUnlock()
+	health := map[string]map[string]bool{}
+	for serviceName, lbItem := range lbr.LoadBanlanceMap {
+		if lbItem.CheckConf == nil {
+			continue
+		}
+		health[serviceName] = lbItem.CheckConf.GetHealthState()
+	}
+	return health
+}
+
+// LoadOnce 代理启动时为已加载的服务创建负载均衡器，使节点探活立即生效
+func (lbr *LoadBalancer) LoadOnce() error {
+	for _, serviceDetail := range ServiceManagerHandler.GetServiceList() {
+		if _, err := lbr.GetLoadBalancer(serviceDetail); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReportHealth 定时上报节点探活结果
+func (lbr *LoadBalancer) ReportHealth() {
+	ticker := time.NewTicker(public.UpstreamHealthReportInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		if err := public.ReportUpstreamHealth(lbr.GetUpstreamHealth()); err != nil {
+			log.Printf(" [ERROR] report upstream health err:%v\n", err)
+		}
+	}
+}
+
 var TransportorHandler *Transportor
 
+const (
+	// 上游证书文件加载失败后的重试间隔，逐次翻倍
+	TransportRetryMin = time.Second
+	TransportRetryMax = time.Minute
+)
+
 type Transportor struct {
 	TransportMap   map[string]*TransportItem
 	TransportSlice []*TransportItem
 	Locker         sync.RWMutex
+	failMap        map[string]*transportFailure
 }
 
 type TransportItem struct {
 	Trans       *http.Transport
 	ServiceName string
+	Revision    string //创建时的配置版本
+}
+
+// transportFailure 创建失败的配置版本，重试时间前直接返回错误，避免每个请求都读取证书文件
+type transportFailure struct {
+	revision string
+	err      error
+	backoff  time.Duration
+	retryAt  time.Time
 }
 
 func NewTransportor() *Transportor {
@@ -138,6 +213,7 @@ func NewTransportor() *Transportor {
 		TransportMap:   map[string]*TransportItem{},
 		TransportSlice: []*TransportItem{},
 		Locker:         sync.RWMutex{},
+		failMap:        map[string]*transportFailure{},
 	}
 }
 
@@ -147,15 +223,10 @@ func init() {
 
 // GetTrans 方法根据给定的 ServiceDetail 获取相应的 http.Transport 对象。
 func (t *Transportor) GetTrans(service *ServiceDetail) (*http.Transport, error) {
-	// 遍历 TransportSlice，查找是否已存在对应的 http.Transport 对象。
-	for _, transItem := range t.TransportSlice {
-		if transItem.ServiceName == service.Info.ServiceName {
-			// 如果找到匹配的服务名称，返回对应的 http.Transport 对象。
-			return transItem.Trans, nil
-		}
-	}
+	// 配置版本在设置默认值之前计算，每个服务只计算一次。
+	revision := service.TransportRevision()
 
-	// 如果没有在 TransportSlice 中找到匹配的服务，设置默认的负载均衡配置。
+	// 设置默认的负载均衡配置。
 	if service.LoadBalance.UpstreamConnectTimeout == 0 {
 		service.LoadBalance.UpstreamConnectTimeout = 30
 	}
@@ -169,6 +240,18 @@ func (t *Transportor) GetTrans(service *ServiceDetail) (*http.Transport, error)
 		service.LoadBalance.UpstreamHeaderTimeout = 30
 	}
 
+	// 按服务名查找已创建的 http.Transport，负载均衡或上游https配置变化后重新创建。
+	t.Locker.RLock()
+	oldItem, ok := t.TransportMap[service.Info.ServiceName]
+	failure := t.failMap[service.Info.ServiceName]
+	t.Locker.RUnlock()
+	if ok && oldItem.Revision == revision {
+		return oldItem.Trans, nil
+	}
+	if failure != nil && failure.revision == revision && time.Now().Before(failure.retryAt) {
+		return nil, failure.err
+	}
+
 	// 根据给定的服务详细信息创建一个新的 http.Transport 对象。
 	trans := &http.Transport{
 		Proxy: http.ProxyFromEnvironment,
@@ -183,18 +266,60 @@ func (t *Transportor) GetTrans(service *ServiceDetail) (*http.Transport, error)
 		ResponseHeaderTimeout: time.Duration(service.LoadBalance.UpstreamHeaderTimeout) * time.Second,
 	}
 
-	// 创建一个新的 TransportItem，并将其添加到 TransportSlice 中。
+	// 上游使用https时按服务配置CA、客户端证书、SNI等，证书文件在代理本机加载；加载失败时记录失败，按退避时间重试。
+	if tlsConf := service.LoadBalance.GetUpstreamTLSConf(); service.HTTPRule.UpstreamHttps == 1 && !tlsConf.IsEmpty() {
+		tlsClientConf, err := public.NewUpstreamTLSConfig(tlsConf)
+		if err != nil {
+			t.setFailure(service.Info.ServiceName, revision, err)
+			return nil, err
+		}
+		trans.TLSClientConfig = tlsClientConf
+	}
+
+	// 创建一个新的 TransportItem，替换旧配置的 TransportItem 并关闭其空闲连接。
 	transItem := &TransportItem{
 		Trans:       trans,
 		ServiceName: service.Info.ServiceName,
+		Revision:    revision,
 	}
-	t.TransportSlice = append(t.TransportSlice, transItem)
-
-	// 加锁以确保线程安全，并将新创建的 TransportItem 添加到 TransportMap 中。
 	t.Locker.Lock()
 	defer t.Locker.Unlock()
+	delete(t.failMap, service.Info.ServiceName)
+	if oldItem, ok := t.TransportMap[service.Info.ServiceName]; ok {
+		if oldItem.Revision == revision {
+			trans.CloseIdleConnections()
+			return oldItem.Trans, nil
+		}
+		oldItem.Trans.CloseIdleConnections()
+		for i, item := range t.TransportSlice {
+			if item == oldItem {
+				t.TransportSlice = append(t.TransportSlice[:i:i], t.TransportSlice[i+1:]...)
+				break
+			}
+		}
+	}
+	t.TransportSlice = append(t.TransportSlice, transItem)
 	t.TransportMap[service.Info.ServiceName] = transItem
 
 	// 返回新创建的 http.Transport 对象。
 	return trans, nil
 }
+
+// setFailure 记录创建失败，同一配置版本连续失败时重试间隔翻倍
+func (t *Transportor) setFailure(serviceName, revision string, err error) {
+	t.Locker.Lock()
+	defer t.Locker.Unlock()
+	backoff := TransportRetryMin
+	if failure, ok := t.failMap[serviceName]; ok && failure.revision == revision {
+		backoff = failure.backoff * 2
+		if backoff > TransportRetryMax {
+			backoff = TransportRetryMax
+		}
+	}
+	t.failMap[serviceName] = &transportFailure{
+		revision: revision,
+		err:      err,
+		backoff:  backoff,
+		retryAt:  time.Now().Add(backoff),
+	}
+}
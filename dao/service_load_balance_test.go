@@ -0,0 +1,89 @@
+package dao
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTransportorGetTrans(t *testing.T) {
+	transportor := NewTransportor()
+	service := &ServiceDetail{
+		Info:        &ServiceInfo{ServiceName: "test_http_service"},
+		HTTPRule:    &HttpRule{},
+		LoadBalance: &LoadBalance{},
+	}
+	trans, err := transportor.GetTrans(service)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if same, _ := transportor.GetTrans(service); same != trans {
+		t.Fatal("transport should be reused")
+	}
+
+	//配置变化后重新创建并替换旧的transport
+	updated := &ServiceDetail{
+		Info:        service.Info,
+		HTTPRule:    service.HTTPRule,
+		LoadBalance: &LoadBalance{UpstreamHeaderTimeout: 5},
+	}
+	newTrans, err := transportor.GetTrans(updated)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if newTrans == trans || newTrans.ResponseHeaderTimeout.Seconds() != 5 {
+		t.Fatal("transport should be rebuilt after config change")
+	}
+	if len(transportor.TransportSlice) != 1 || transportor.TransportMap["test_http_service"].Trans != newTrans {
+		t.Fatal("old transport should be replaced")
+	}
+
+	//上游https配置的证书文件无法加载时不替换已有transport，失败按退避时间缓存
+	failed := &ServiceDetail{
+		Info:        service.Info,
+		HTTPRule:    &HttpRule{UpstreamHttps: 1},
+		LoadBalance: &LoadBalance{UpstreamTlsCaFile: "not_exist.crt"},
+	}
+	_, err = transportor.GetTrans(failed)
+	if err == nil {
+		t.Fatal("expect error for missing ca file")
+	}
+	if transportor.TransportMap["test_http_service"].Trans != newTrans {
+		t.Fatal("failed transport should not be cached")
+	}
+	failure := transportor.failMap["test_http_service"]
+	if failure == nil || failure.backoff != TransportRetryMin {
+		t.Fatalf("unexpected failure %+v", failure)
+	}
+	if _, cached := transportor.GetTrans(failed); cached != err {
+		t.Fatal("failure should be returned before retry time")
+	}
+	//到重试时间后重新加载，再次失败时间隔翻倍
+	failure.retryAt = time.Now()
+	if _, err := transportor.GetTrans(failed); err == nil || transportor.failMap["test_http_service"].backoff != 2*TransportRetryMin {
+		t.Fatal("retry failure should double backoff")
+	}
+
+	//成功创建后清除失败记录
+	fixed := &ServiceDetail{Info: service.Info, HTTPRule: &HttpRule{}, LoadBalance: &LoadBalance{}}
+	if _, err := transportor.GetTrans(fixed); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := transportor.failMap["test_http_service"]; ok {
+		t.Fatal("failure should be cleared")
+	}
+}
+
+func TestServiceDetailTransportRevision(t *testing.T) {
+	service := &ServiceDetail{HTTPRule: &HttpRule{}, LoadBalance: &LoadBalance{}}
+	revision := service.TransportRevision()
+	//同一服务只计算一次，请求中设置的默认值不影响版本
+	service.LoadBalance.UpstreamConnectTimeout = 30
+	if service.TransportRevision() != revision {
+		t.Fatal("revision should be computed once")
+	}
+	same := &ServiceDetail{HTTPRule: &HttpRule{}, LoadBalance: &LoadBalance{}}
+	https := &ServiceDetail{HTTPRule: &HttpRule{UpstreamHttps: 1}, LoadBalance: &LoadBalance{}}
+	if same.TransportRevision() != revision || https.TransportRevision() == revision {
+		t.Fatal("revision should follow upstream config")
+	}
+}
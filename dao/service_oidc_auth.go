@@ -0,0 +1,77 @@
+package dao
+
+import (
+	"FGateWay/public"
+	"github.com/e421083458/gorm"
+	"github.com/gin-gonic/gin"
+	"strings"
+	"time"
+)
+
+type OidcAuth struct {
+	ID             int64  `json:"id" gorm:"primary_key"`
+	ServiceID      int64  `json:"service_id" gorm:"column:service_id" description:"服务id"`
+	OpenOidc       int    `json:"open_oidc" gorm:"column:open_oidc" description:"是否开启外部OIDC校验 1=开启"`
+	Issuer         string `json:"issuer" gorm:"column:issuer" description:"token签发方iss"`
+	Audience       string `json:"audience" gorm:"column:audience" description:"token受众aud, 多个逗号间隔"`
+	JwksUrl        string `json:"jwks_url" gorm:"column:jwks_url" description:"JWKS地址"`
+	StaticKeys     string `json:"static_keys" gorm:"column:static_keys" description:"静态PEM公钥, 未配置JWKS地址时使用"`
+	JwksRefresh    int    `json:"jwks_refresh" gorm:"column:jwks_refresh" description:"JWKS刷新间隔, 单位s"`
+	RequiredClaims string `json:"required_claims" gorm:"column:required_claims" description:"必须的claim 格式: claim value, 多个逗号间隔"`
+	AppClaim       string `json:"app_claim" gorm:"column:app_claim" description:"映射到租户app_id的claim"`
+	ClaimHeaders   string `json:"claim_headers" gorm:"column:claim_headers" description:"透传到下游的claim 格式: claim headname, 多个逗号间隔"`
+}
+
+func (t *OidcAuth) TableName() string {
+	return "gateway_service_oidc_auth"
+}
+
+func (t *OidcAuth) Find(c *gin.Context, tx *gorm.DB, search *OidcAuth) (*OidcAuth, error) {
+	model := &OidcAuth{}
+	err := tx.SetCtx(public.GetGinTraceContext(c)).Where(search).Find(model).Error
+	return model, err
+}
+
+func (t *OidcAuth) Save(c *gin.Context, tx *gorm.DB) error {
+	if err := tx.SetCtx(public.GetGinTraceContext(c)).Save(t).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+// GetOidcConf 转换为public层的校验配置
+func (t *OidcAuth) GetOidcConf() *public.OidcConf {
+	conf := &public.OidcConf{
+		Issuer:          t.Issuer,
+		JwksURL:         t.JwksUrl,
+		StaticKeys:      t.StaticKeys,
+		RefreshInterval: time.Duration(t.JwksRefresh) * time.Second,
+		RequiredClaims:  map[string]string{},
+	}
+	for _, aud := range strings.Split(t.Audience, ",") {
+		if aud = strings.TrimSpace(aud); aud != "" {
+			conf.Audience = append(conf.Audience, aud)
+		}
+	}
+	for _, item := range strings.Split(t.RequiredClaims, ",") {
+		items := strings.Split(item, " ")
+		if len(items) != 2 {
+			continue
+		}
+		conf.RequiredClaims[items[0]] = items[1]
+	}
+	return conf
+}
+
+// GetClaimHeaders 返回 claim => header 的映射
+func (t *OidcAuth) GetClaimHeaders() map[string]string {
+	headers := map[string]string{}
+	for _, item := range strings.Split(t.ClaimHeaders, ",") {
+		items := strings.Split(item, " ")
+		if len(items) != 2 {
+			continue
+		}
+		headers[items[0]] = items[1]
+	}
+	return headers
+}
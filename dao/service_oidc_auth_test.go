@@ -0,0 +1,19 @@
+package dao
+
+import (
+	"testing"
+)
+
+func TestOidcAuthGetOidcConf(t *testing.T) {
+	auth := &OidcAuth{Audience: "a, b,,", RequiredClaims: "tenant app_a"}
+	conf := auth.GetOidcConf()
+	if len(conf.Audience) != 2 || conf.Audience[0] != "a" || conf.Audience[1] != "b" {
+		t.Fatalf("unexpected audience %q", conf.Audience)
+	}
+	if conf.RequiredClaims["tenant"] != "app_a" {
+		t.Fatalf("unexpected required claims %v", conf.RequiredClaims)
+	}
+	if len((&OidcAuth{}).GetOidcConf().Audience) != 0 {
+		t.Fatal("empty audience expected")
+	}
+}
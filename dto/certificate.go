@@ -0,0 +1,65 @@
+package dto
+
+import (
+	"FGateWay/public"
+	"github.com/gin-gonic/gin"
+	"time"
+)
+
+type CertListInput struct {
+	Info     string `json:"info" form:"info" comment:"查找信息" validate:""`
+	PageSize int    `json:"page_size" form:"page_size" comment:"页数" validate:"required,min=1,max=999"`
+	PageNo   int    `json:"page_no" form:"page_no" comment:"页码" validate:"required,min=1,max=999"`
+}
+
+func (params *CertListInput) GetValidParams(c *gin.Context) error {
+	return public.DefaultGetValidParams(c, params)
+}
+
+type CertListOutput struct {
+	List  []CertListItemOutput `json:"list" form:"list" comment:"证书列表"`
+	Total int64                `json:"total" form:"total" comment:"证书总数"`
+}
+
+type CertListItemOutput struct {
+	ID         int64     `json:"id"`
+	Name       string    `json:"name" description:"证书名称"`
+	Domains    string    `json:"domains" description:"证书域名，逗号间隔"`
+	IsDefault  int       `json:"is_default" description:"是否默认证书"`
+	Source     string    `json:"source" description:"证书来源 upload/acme"`
+	NotBefore  time.Time `json:"not_before" description:"生效时间"`
+	NotAfter   time.Time `json:"not_after" description:"过期时间"`
+	ExpireDays int       `json:"expire_days" description:"剩余有效天数"`
+	UpdatedAt  time.Time `json:"update_at" description:"更新时间"`
+}
+
+type CertAddInput struct {
+	Name      string `json:"name" form:"name" comment:"证书名称" validate:"required,max=255"`
+	CertPem   string `json:"cert_pem" form:"cert_pem" comment:"PEM证书链" validate:"required"`
+	KeyPem    string `json:"key_pem" form:"key_pem" comment:"PEM私钥" validate:"required"`
+	IsDefault int    `json:"is_default" form:"is_default" comment:"是否默认证书" validate:"max=1,min=0"`
+}
+
+func (params *CertAddInput) GetValidParams(c *gin.Context) error {
+	return public.DefaultGetValidParams(c, params)
+}
+
+type CertUpdateInput struct {
+	ID        int64  `json:"id" form:"id" comment:"证书ID" validate:"required"`
+	Name      string `json:"name" form:"name" comment:"证书名称" validate:"required,max=255"`
+	CertPem   string `json:"cert_pem" form:"cert_pem" comment:"PEM证书链" validate:"required"`
+	KeyPem    string `json:"key_pem" form:"key_pem" comment:"PEM私钥" validate:"required"`
+	IsDefault int    `json:"is_default" form:"is_default" comment:"是否默认证书" validate:"max=1,min=0"`
+}
+
+func (params *CertUpdateInput) GetValidParams(c *gin.Context) error {
+	return public.DefaultGetValidParams(c, params)
+}
+
+type CertDeleteInput struct {
+	ID int64 `json:"id" form:"id" comment:"证书ID" validate:"required"`
+}
+
+func (params *CertDeleteInput) GetValidParams(c *gin.Context) error {
+	return public.DefaultGetValidParams(c, params)
+}
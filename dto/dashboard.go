@@ -1,5 +1,10 @@
 package dto
 
+import (
+	"FGateWay/public"
+	"github.com/gin-gonic/gin"
+)
+
 type PanelGroupDataOutput struct {
 	ServiceNum      int64 `json:"serviceNum"`
 	AppNum          int64 `json:"appNum"`
@@ -17,3 +22,11 @@ type DashServiceStatOutput struct {
 	Legend []string                    `json:"legend"`
 	Data   []DashServiceStatItemOutput `json:"data"`
 }
+
+type FlowStatRangeInput struct {
+	Range string `json:"range" form:"range" comment:"时间范围 1h/24h/7d" example:"1h" validate:"required,oneof=1h 24h 7d"`
+}
+
+func (param *FlowStatRangeInput) BindValidParam(c *gin.Context) error {
+	return public.DefaultGetValidParams(c, param)
+}
@@ -20,3 +20,11 @@ type TokensOutput struct {
 	TokenType   string `json:"token_type" form:"token_type"`     //token_type
 	Scope       string `json:"scope" form:"scope"`               //scope
 }
+
+type TokenRevokeInput struct {
+	Token string `json:"token" form:"token" comment:"待吊销的token" example:"" validate:"required"` //待吊销的token
+}
+
+func (param *TokenRevokeInput) BindValidParam(c *gin.Context) error {
+	return public.DefaultGetValidParams(c, param)
+}
@@ -14,14 +14,56 @@ type ServiceUpdateHttpInput struct {
 	NeedHttps      int    `json:"need_https" form:"need_https" comment:"支持https"  validate:"max=1,min=0"`                      //支持https
 	NeedStripUri   int    `json:"need_strip_uri" form:"need_strip_uri" comment:"启用strip_uri"  validate:"max=1,min=0"`          //启用strip_uri
 	NeedWebsocket  int    `json:"need_websocket" form:"need_websocket" comment:"是否支持websocket"  validate:"max=1,min=0"`        //是否支持websocket
+	NeedStream     int    `json:"need_stream" form:"need_stream" comment:"流式/长轮询服务"  validate:"max=1,min=0"`                   //流式/长轮询服务，不受全局写超时限制
 	UrlRewrite     string `json:"url_rewrite" form:"url_rewrite" comment:"url重写功能"  validate:"valid_url_rewrite"`              //url重写功能
 	HeaderTransfor string `json:"header_transfor" form:"header_transfor" comment:"header转换"  validate:"valid_header_transfor"` //header转换
+	LogBody        int    `json:"log_body" form:"log_body" comment:"记录请求体"  validate:"max=1,min=0"`                            //记录请求体
+	LogBodyMaxSize int    `json:"log_body_max_size" form:"log_body_max_size" comment:"请求体最大记录字节"  validate:"min=0"`            //请求体最大记录字节，0表示使用全局配置
 
-	OpenAuth          int    `json:"open_auth" form:"open_auth" comment:"是否开启权限"  validate:"max=1,min=0"`                 //关键词
-	BlackList         string `json:"black_list" form:"black_list" comment:"黑名单ip"  validate:""`                           //黑名单ip
-	WhiteList         string `json:"white_list" form:"white_list" comment:"白名单ip"  validate:""`                           //白名单ip
-	ClientipFlowLimit int    `json:"clientip_flow_limit" form:"clientip_flow_limit" comment:"客户端ip限流"  validate:"min=0"` //客户端ip限流
-	ServiceFlowLimit  int    `json:"service_flow_limit" form:"service_flow_limit" comment:"服务端限流"  validate:"min=0"`      //服务端限流
+	MaxRequestBodySize  int `json:"max_request_body_size" form:"max_request_body_size" comment:"请求体最大字节"  validate:"min=0"`   //请求体最大字节，0表示不限制
+	MaxResponseBodySize int `json:"max_response_body_size" form:"max_response_body_size" comment:"响应体最大字节"  validate:"min=0"` //响应体最大字节，0表示不限制
+	MaxHeaderCount      int `json:"max_header_count" form:"max_header_count" comment:"请求header最大个数"  validate:"min=0"`        //请求header最大个数，0表示不限制
+	MaxHeaderSize       int `json:"max_header_size" form:"max_header_size" comment:"请求header最大字节"  validate:"min=0"`          //请求header最大字节，0表示不限制
+
+	WebsocketMaxConn      int `json:"websocket_max_conn" form:"websocket_max_conn" comment:"websocket最大连接数"  validate:"min=0"`                  //websocket最大连接数，0表示不限制
+	WebsocketIdleTimeout  int `json:"websocket_idle_timeout" form:"websocket_idle_timeout" comment:"websocket空闲超时, 单位s"  validate:"min=0"`      //websocket空闲超时, 单位s，0表示不限制
+	WebsocketPingInterval int `json:"websocket_ping_interval" form:"websocket_ping_interval" comment:"websocket ping间隔, 单位s"  validate:"min=0"` //websocket ping间隔, 单位s，0表示不发送
+
+	UpstreamHttps         int `json:"upstream_https" form:"upstream_https" comment:"上游使用https"  validate:"max=1,min=0"`                   //上游使用https
+	HstsMaxAge            int `json:"hsts_max_age" form:"hsts_max_age" comment:"HSTS有效期, 单位s"  validate:"min=0"`                          //HSTS有效期, 单位s，0表示不下发
+	HstsIncludeSubdomains int `json:"hsts_include_subdomains" form:"hsts_include_subdomains" comment:"HSTS包含子域名"  validate:"max=1,min=0"` //HSTS包含子域名
+
+	ResponseHeaderTransfor string `json:"response_header_transfor" form:"response_header_transfor" comment:"响应header转换"  validate:"valid_header_transfor"` //响应header转换
+	OpenCors               int    `json:"open_cors" form:"open_cors" comment:"开启跨域"  validate:"max=1,min=0"`                                               //开启跨域
+	CorsAllowOrigins       string `json:"cors_allow_origins" form:"cors_allow_origins" comment:"允许的来源"  validate:""`                                       //允许的来源，多个逗号间隔，支持*与https://*.test.com
+	CorsAllowMethods       string `json:"cors_allow_methods" form:"cors_allow_methods" comment:"允许的方法"  validate:""`                                       //允许的方法，多个逗号间隔
+	CorsAllowHeaders       string `json:"cors_allow_headers" form:"cors_allow_headers" comment:"允许的请求header"  validate:""`                                 //允许的请求header，多个逗号间隔
+	CorsExposeHeaders      string `json:"cors_expose_headers" form:"cors_expose_headers" comment:"暴露的响应header"  validate:""`                               //暴露给浏览器的响应header，多个逗号间隔
+	CorsAllowCredentials   int    `json:"cors_allow_credentials" form:"cors_allow_credentials" comment:"允许携带凭证"  validate:"max=1,min=0"`                   //允许携带凭证
+	CorsMaxAge             int    `json:"cors_max_age" form:"cors_max_age" comment:"预检结果缓存时间, 单位s"  validate:"min=0"`                                      //预检结果缓存时间, 单位s
+
+	OpenCache           int    `json:"open_cache" form:"open_cache" comment:"开启响应缓存"  validate:"max=1,min=0"`                               //开启响应缓存，只缓存GET/HEAD
+	CacheBackend        string `json:"cache_backend" form:"cache_backend" comment:"缓存存储"  validate:"omitempty,oneof=memory redis"`          //缓存存储，memory或redis
+	CacheTtl            int    `json:"cache_ttl" form:"cache_ttl" comment:"默认缓存时间, 单位s"  validate:"min=0"`                                  //默认缓存时间, 单位s，上游未指定时使用
+	CacheForceTtl       int    `json:"cache_force_ttl" form:"cache_force_ttl" comment:"忽略上游max-age"  validate:"max=1,min=0"`                //忽略上游max-age
+	CacheStaleTtl       int    `json:"cache_stale_ttl" form:"cache_stale_ttl" comment:"过期后后台刷新时间, 单位s"  validate:"min=0"`                   //过期后返回旧数据并后台刷新的时间, 单位s
+	CacheKeyIgnoreQuery int    `json:"cache_key_ignore_query" form:"cache_key_ignore_query" comment:"缓存key忽略query"  validate:"max=1,min=0"` //缓存key忽略query
+	CacheKeyHeaders     string `json:"cache_key_headers" form:"cache_key_headers" comment:"参与缓存key的请求header"  validate:""`                  //参与缓存key的请求header，多个逗号间隔
+	CacheKeyApp         int    `json:"cache_key_app" form:"cache_key_app" comment:"缓存key区分租户"  validate:"max=1,min=0"`                      //缓存key区分租户
+
+	OpenCompress         int    `json:"open_compress" form:"open_compress" comment:"开启响应压缩"  validate:"max=1,min=0"`                   //开启响应压缩，按Accept-Encoding协商
+	CompressEncodings    string `json:"compress_encodings" form:"compress_encodings" comment:"压缩编码"  validate:""`                      //压缩编码，br,gzip按优先级排列，为空时为br,gzip
+	CompressMinSize      int    `json:"compress_min_size" form:"compress_min_size" comment:"最小压缩字节"  validate:"min=0"`                 //小于该字节数的响应不压缩，0表示1024
+	CompressContentTypes string `json:"compress_content_types" form:"compress_content_types" comment:"允许压缩的content-type"  validate:""` //允许压缩的content-type，多个逗号间隔，为空时为常见文本类型
+	DecompressRequest    int    `json:"decompress_request" form:"decompress_request" comment:"解压请求体"  validate:"max=1,min=0"`          //解压gzip/br请求体后转发上游
+
+	OpenAuth          int    `json:"open_auth" form:"open_auth" comment:"是否开启权限"  validate:"max=1,min=0"`                                   //关键词
+	BlackList         string `json:"black_list" form:"black_list" comment:"黑名单ip"  validate:""`                                             //黑名单ip
+	WhiteList         string `json:"white_list" form:"white_list" comment:"白名单ip"  validate:""`                                             //白名单ip
+	ClientipFlowLimit int    `json:"clientip_flow_limit" form:"clientip_flow_limit" comment:"客户端ip限流"  validate:"min=0"`                   //客户端ip限流
+	ServiceFlowLimit  int    `json:"service_flow_limit" form:"service_flow_limit" comment:"服务端限流"  validate:"min=0"`                        //服务端限流
+	NeedClientCert    int    `json:"need_client_cert" form:"need_client_cert" comment:"要求客户端证书"  validate:"max=1,min=0"`                    //要求客户端证书，需开启https
+	ClientCertAppKey  string `json:"client_cert_app_key" form:"client_cert_app_key" comment:"映射租户的证书字段"  validate:"omitempty,oneof=cn san"` //映射租户app_id的证书字段，cn或san，为空时不映射
 
 	RoundType              int    `json:"round_type" form:"round_type" comment:"轮询方式"  validate:"max=3,min=0"`                                //轮询方式
 	IpList                 string `json:"ip_list" form:"ip_list" comment:"ip列表"  validate:"required,valid_ipportlist"`                        //ip列表
@@ -30,6 +72,33 @@ type ServiceUpdateHttpInput struct {
 	UpstreamHeaderTimeout  int    `json:"upstream_header_timeout" form:"upstream_header_timeout" comment:"获取header超时, 单位s"  validate:"min=0"` //获取header超时, 单位s
 	UpstreamIdleTimeout    int    `json:"upstream_idle_timeout" form:"upstream_idle_timeout" comment:"链接最大空闲时间, 单位s"  validate:"min=0"`       //链接最大空闲时间, 单位s
 	UpstreamMaxIdle        int    `json:"upstream_max_idle" form:"upstream_max_idle" comment:"最大空闲链接数"  validate:"min=0"`                     //最大空闲链接数
+
+	UpstreamRequestTimeout    int `json:"upstream_request_timeout" form:"upstream_request_timeout" comment:"请求总超时, 单位s"  validate:"min=0"`           //请求总超时, 单位s，0表示不限制
+	UpstreamStreamIdleTimeout int `json:"upstream_stream_idle_timeout" form:"upstream_stream_idle_timeout" comment:"响应流空闲超时, 单位s"  validate:"min=0"` //响应流空闲超时, 单位s，0表示不限制
+
+	UpstreamTlsCaFile     string `json:"upstream_tls_ca_file" form:"upstream_tls_ca_file" comment:"上游CA文件"  validate:""`                                           //校验上游证书的CA文件，为空使用系统根证书
+	UpstreamTlsCertFile   string `json:"upstream_tls_cert_file" form:"upstream_tls_cert_file" comment:"上游客户端证书文件"  validate:""`                                    //双向认证客户端证书文件
+	UpstreamTlsKeyFile    string `json:"upstream_tls_key_file" form:"upstream_tls_key_file" comment:"上游客户端私钥文件"  validate:""`                                      //双向认证客户端私钥文件
+	UpstreamTlsServerName string `json:"upstream_tls_server_name" form:"upstream_tls_server_name" comment:"上游SNI"  validate:""`                                    //覆盖上游SNI
+	UpstreamTlsMinVersion string `json:"upstream_tls_min_version" form:"upstream_tls_min_version" comment:"上游最低TLS版本"  validate:"omitempty,oneof=1.0 1.1 1.2 1.3"` //最低TLS版本
+	UpstreamTlsInsecure   int    `json:"upstream_tls_insecure" form:"upstream_tls_insecure" comment:"跳过上游证书校验"  validate:"max=1,min=0"`                            //跳过上游证书校验，仅用于测试
+
+	OpenOidc           int    `json:"open_oidc" form:"open_oidc" comment:"是否开启外部OIDC校验"  validate:"max=1,min=0"`          //是否开启外部OIDC校验
+	OidcIssuer         string `json:"oidc_issuer" form:"oidc_issuer" comment:"token签发方"  validate:""`                     //token签发方
+	OidcAudience       string `json:"oidc_audience" form:"oidc_audience" comment:"token受众"  validate:""`                  //token受众，多个逗号间隔
+	OidcJwksUrl        string `json:"oidc_jwks_url" form:"oidc_jwks_url" comment:"JWKS地址"  validate:""`                   //JWKS地址
+	OidcStaticKeys     string `json:"oidc_static_keys" form:"oidc_static_keys" comment:"静态PEM公钥"  validate:""`            //静态PEM公钥
+	OidcJwksRefresh    int    `json:"oidc_jwks_refresh" form:"oidc_jwks_refresh" comment:"JWKS刷新间隔"  validate:"min=0"`    //JWKS刷新间隔, 单位s
+	OidcRequiredClaims string `json:"oidc_required_claims" form:"oidc_required_claims" comment:"必须的claim"  validate:""`   //必须的claim
+	OidcAppClaim       string `json:"oidc_app_claim" form:"oidc_app_claim" comment:"映射租户的claim"  validate:""`             //映射租户的claim
+	OidcClaimHeaders   string `json:"oidc_claim_headers" form:"oidc_claim_headers" comment:"透传claim header"  validate:""` //透传claim header
+
+	OpenForwardAuth            int    `json:"open_forward_auth" form:"open_forward_auth" comment:"是否开启外部鉴权"  validate:"max=1,min=0"`                  //是否开启外部鉴权
+	ForwardAuthUrl             string `json:"forward_auth_url" form:"forward_auth_url" comment:"外部鉴权地址"  validate:""`                                 //外部鉴权地址
+	ForwardAuthRequestHeaders  string `json:"forward_auth_request_headers" form:"forward_auth_request_headers" comment:"透传给鉴权服务的header"  validate:""` //透传给鉴权服务的header
+	ForwardAuthResponseHeaders string `json:"forward_auth_response_headers" form:"forward_auth_response_headers" comment:"复制到下游的header"  validate:""` //复制到下游的header
+	ForwardAuthCacheTtl        int    `json:"forward_auth_cache_ttl" form:"forward_auth_cache_ttl" comment:"鉴权结果缓存时间"  validate:"min=0"`              //鉴权结果缓存时间, 单位s
+	ForwardAuthTimeout         int    `json:"forward_auth_timeout" form:"forward_auth_timeout" comment:"鉴权请求超时"  validate:"min=0"`                    //鉴权请求超时, 单位ms
 }
 
 func (param *ServiceUpdateHttpInput) BindValidParam(c *gin.Context) error {
@@ -46,14 +115,56 @@ type ServiceAddHttpInput struct {
 	NeedHttps      int    `json:"need_https" form:"need_https" comment:"支持https"  validate:"max=1,min=0"`                      //支持https
 	NeedStripUri   int    `json:"need_strip_uri" form:"need_strip_uri" comment:"启用strip_uri"  validate:"max=1,min=0"`          //启用strip_uri
 	NeedWebsocket  int    `json:"need_websocket" form:"need_websocket" comment:"是否支持websocket"  validate:"max=1,min=0"`        //是否支持websocket
+	NeedStream     int    `json:"need_stream" form:"need_stream" comment:"流式/长轮询服务"  validate:"max=1,min=0"`                   //流式/长轮询服务，不受全局写超时限制
 	UrlRewrite     string `json:"url_rewrite" form:"url_rewrite" comment:"url重写功能"  validate:"valid_url_rewrite"`              //url重写功能
 	HeaderTransfor string `json:"header_transfor" form:"header_transfor" comment:"header转换"  validate:"valid_header_transfor"` //header转换
+	LogBody        int    `json:"log_body" form:"log_body" comment:"记录请求体"  validate:"max=1,min=0"`                            //记录请求体
+	LogBodyMaxSize int    `json:"log_body_max_size" form:"log_body_max_size" comment:"请求体最大记录字节"  validate:"min=0"`            //请求体最大记录字节，0表示使用全局配置
+
+	MaxRequestBodySize  int `json:"max_request_body_size" form:"max_request_body_size" comment:"请求体最大字节"  validate:"min=0"`   //请求体最大字节，0表示不限制
+	MaxResponseBodySize int `json:"max_response_body_size" form:"max_response_body_size" comment:"响应体最大字节"  validate:"min=0"` //响应体最大字节，0表示不限制
+	MaxHeaderCount      int `json:"max_header_count" form:"max_header_count" comment:"请求header最大个数"  validate:"min=0"`        //请求header最大个数，0表示不限制
+	MaxHeaderSize       int `json:"max_header_size" form:"max_header_size" comment:"请求header最大字节"  validate:"min=0"`          //请求header最大字节，0表示不限制
+
+	WebsocketMaxConn      int `json:"websocket_max_conn" form:"websocket_max_conn" comment:"websocket最大连接数"  validate:"min=0"`                  //websocket最大连接数，0表示不限制
+	WebsocketIdleTimeout  int `json:"websocket_idle_timeout" form:"websocket_idle_timeout" comment:"websocket空闲超时, 单位s"  validate:"min=0"`      //websocket空闲超时, 单位s，0表示不限制
+	WebsocketPingInterval int `json:"websocket_ping_interval" form:"websocket_ping_interval" comment:"websocket ping间隔, 单位s"  validate:"min=0"` //websocket ping间隔, 单位s，0表示不发送
+
+	UpstreamHttps         int `json:"upstream_https" form:"upstream_https" comment:"上游使用https"  validate:"max=1,min=0"`                   //上游使用https
+	HstsMaxAge            int `json:"hsts_max_age" form:"hsts_max_age" comment:"HSTS有效期, 单位s"  validate:"min=0"`                          //HSTS有效期, 单位s，0表示不下发
+	HstsIncludeSubdomains int `json:"hsts_include_subdomains" form:"hsts_include_subdomains" comment:"HSTS包含子域名"  validate:"max=1,min=0"` //HSTS包含子域名
+
+	ResponseHeaderTransfor string `json:"response_header_transfor" form:"response_header_transfor" comment:"响应header转换"  validate:"valid_header_transfor"` //响应header转换
+	OpenCors               int    `json:"open_cors" form:"open_cors" comment:"开启跨域"  validate:"max=1,min=0"`                                               //开启跨域
+	CorsAllowOrigins       string `json:"cors_allow_origins" form:"cors_allow_origins" comment:"允许的来源"  validate:""`                                       //允许的来源，多个逗号间隔，支持*与https://*.test.com
+	CorsAllowMethods       string `json:"cors_allow_methods" form:"cors_allow_methods" comment:"允许的方法"  validate:""`                                       //允许的方法，多个逗号间隔
+	CorsAllowHeaders       string `json:"cors_allow_headers" form:"cors_allow_headers" comment:"允许的请求header"  validate:""`                                 //允许的请求header，多个逗号间隔
+	CorsExposeHeaders      string `json:"cors_expose_headers" form:"cors_expose_headers" comment:"暴露的响应header"  validate:""`                               //暴露给浏览器的响应header，多个逗号间隔
+	CorsAllowCredentials   int    `json:"cors_allow_credentials" form:"cors_allow_credentials" comment:"允许携带凭证"  validate:"max=1,min=0"`                   //允许携带凭证
+	CorsMaxAge             int    `json:"cors_max_age" form:"cors_max_age" comment:"预检结果缓存时间, 单位s"  validate:"min=0"`                                      //预检结果缓存时间, 单位s
+
+	OpenCache           int    `json:"open_cache" form:"open_cache" comment:"开启响应缓存"  validate:"max=1,min=0"`                               //开启响应缓存，只缓存GET/HEAD
+	CacheBackend        string `json:"cache_backend" form:"cache_backend" comment:"缓存存储"  validate:"omitempty,oneof=memory redis"`          //缓存存储，memory或redis
+	CacheTtl            int    `json:"cache_ttl" form:"cache_ttl" comment:"默认缓存时间, 单位s"  validate:"min=0"`                                  //默认缓存时间, 单位s，上游未指定时使用
+	CacheForceTtl       int    `json:"cache_force_ttl" form:"cache_force_ttl" comment:"忽略上游max-age"  validate:"max=1,min=0"`                //忽略上游max-age
+	CacheStaleTtl       int    `json:"cache_stale_ttl" form:"cache_stale_ttl" comment:"过期后后台刷新时间, 单位s"  validate:"min=0"`                   //过期后返回旧数据并后台刷新的时间, 单位s
+	CacheKeyIgnoreQuery int    `json:"cache_key_ignore_query" form:"cache_key_ignore_query" comment:"缓存key忽略query"  validate:"max=1,min=0"` //缓存key忽略query
+	CacheKeyHeaders     string `json:"cache_key_headers" form:"cache_key_headers" comment:"参与缓存key的请求header"  validate:""`                  //参与缓存key的请求header，多个逗号间隔
+	CacheKeyApp         int    `json:"cache_key_app" form:"cache_key_app" comment:"缓存key区分租户"  validate:"max=1,min=0"`                      //缓存key区分租户
+
+	OpenCompress         int    `json:"open_compress" form:"open_compress" comment:"开启响应压缩"  validate:"max=1,min=0"`                   //开启响应压缩，按Accept-Encoding协商
+	CompressEncodings    string `json:"compress_encodings" form:"compress_encodings" comment:"压缩编码"  validate:""`                      //压缩编码，br,gzip按优先级排列，为空时为br,gzip
+	CompressMinSize      int    `json:"compress_min_size" form:"compress_min_size" comment:"最小压缩字节"  validate:"min=0"`                 //小于该字节数的响应不压缩，0表示1024
+	CompressContentTypes string `json:"compress_content_types" form:"compress_content_types" comment:"允许压缩的content-type"  validate:""` //允许压缩的content-type，多个逗号间隔，为空时为常见文本类型
+	DecompressRequest    int    `json:"decompress_request" form:"decompress_request" comment:"解压请求体"  validate:"max=1,min=0"`          //解压gzip/br请求体后转发上游
 
-	OpenAuth          int    `json:"open_auth" form:"open_auth" comment:"是否开启权限"  validate:"max=1,min=0"`                 //关键词
-	BlackList         string `json:"black_list" form:"black_list" comment:"黑名单ip"  validate:""`                           //黑名单ip
-	WhiteList         string `json:"white_list" form:"white_list" comment:"白名单ip"  validate:""`                           //白名单ip
-	ClientipFlowLimit int    `json:"clientip_flow_limit" form:"clientip_flow_limit" comment:"客户端ip限流"  validate:"min=0"` //客户端ip限流
-	ServiceFlowLimit  int    `json:"service_flow_limit" form:"service_flow_limit" comment:"服务端限流"  validate:"min=0"`      //服务端限流
+	OpenAuth          int    `json:"open_auth" form:"open_auth" comment:"是否开启权限"  validate:"max=1,min=0"`                                   //关键词
+	BlackList         string `json:"black_list" form:"black_list" comment:"黑名单ip"  validate:""`                                             //黑名单ip
+	WhiteList         string `json:"white_list" form:"white_list" comment:"白名单ip"  validate:""`                                             //白名单ip
+	ClientipFlowLimit int    `json:"clientip_flow_limit" form:"clientip_flow_limit" comment:"客户端ip限流"  validate:"min=0"`                   //客户端ip限流
+	ServiceFlowLimit  int    `json:"service_flow_limit" form:"service_flow_limit" comment:"服务端限流"  validate:"min=0"`                        //服务端限流
+	NeedClientCert    int    `json:"need_client_cert" form:"need_client_cert" comment:"要求客户端证书"  validate:"max=1,min=0"`                    //要求客户端证书，需开启https
+	ClientCertAppKey  string `json:"client_cert_app_key" form:"client_cert_app_key" comment:"映射租户的证书字段"  validate:"omitempty,oneof=cn san"` //映射租户app_id的证书字段，cn或san，为空时不映射
 
 	RoundType              int    `json:"round_type" form:"round_type" comment:"轮询方式"  validate:"max=3,min=0"`                                //轮询方式
 	IpList                 string `json:"ip_list" form:"ip_list" comment:"ip列表"  validate:"required,valid_ipportlist"`                        //ip列表
@@ -62,6 +173,33 @@ type ServiceAddHttpInput struct {
 	UpstreamHeaderTimeout  int    `json:"upstream_header_timeout" form:"upstream_header_timeout" comment:"获取header超时, 单位s"  validate:"min=0"` //获取header超时, 单位s
 	UpstreamIdleTimeout    int    `json:"upstream_idle_timeout" form:"upstream_idle_timeout" comment:"链接最大空闲时间, 单位s"  validate:"min=0"`       //链接最大空闲时间, 单位s
 	UpstreamMaxIdle        int    `json:"upstream_max_idle" form:"upstream_max_idle" comment:"最大空闲链接数"  validate:"min=0"`                     //最大空闲链接数
+
+	UpstreamRequestTimeout    int `json:"upstream_request_timeout" form:"upstream_request_timeout" comment:"请求总超时, 单位s"  validate:"min=0"`           //请求总超时, 单位s，0表示不限制
+	UpstreamStreamIdleTimeout int `json:"upstream_stream_idle_timeout" form:"upstream_stream_idle_timeout" comment:"响应流空闲超时, 单位s"  validate:"min=0"` //响应流空闲超时, 单位s，0表示不限制
+
+	UpstreamTlsCaFile     string `json:"upstream_tls_ca_file" form:"upstream_tls_ca_file" comment:"上游CA文件"  validate:""`                                           //校验上游证书的CA文件，为空使用系统根证书
+	UpstreamTlsCertFile   string `json:"upstream_tls_cert_file" form:"upstream_tls_cert_file" comment:"上游客户端证书文件"  validate:""`                                    //双向认证客户端证书文件
+	UpstreamTlsKeyFile    string `json:"upstream_tls_key_file" form:"upstream_tls_key_file" comment:"上游客户端私钥文件"  validate:""`                                      //双向认证客户端私钥文件
+	UpstreamTlsServerName string `json:"upstream_tls_server_name" form:"upstream_tls_server_name" comment:"上游SNI"  validate:""`                                    //覆盖上游SNI
+	UpstreamTlsMinVersion string `json:"upstream_tls_min_version" form:"upstream_tls_min_version" comment:"上游最低TLS版本"  validate:"omitempty,oneof=1.0 1.1 1.2 1.3"` //最低TLS版本
+	UpstreamTlsInsecure   int    `json:"upstream_tls_insecure" form:"upstream_tls_insecure" comment:"跳过上游证书校验"  validate:"max=1,min=0"`                            //跳过上游证书校验，仅用于测试
+
+	OpenOidc           int    `json:"open_oidc" form:"open_oidc" comment:"是否开启外部OIDC校验"  validate:"max=1,min=0"`          //是否开启外部OIDC校验
+	OidcIssuer         string `json:"oidc_issuer" form:"oidc_issuer" comment:"token签发方"  validate:""`                     //token签发方
+	OidcAudience       string `json:"oidc_audience" form:"oidc_audience" comment:"token受众"  validate:""`                  //token受众，多个逗号间隔
+	OidcJwksUrl        string `json:"oidc_jwks_url" form:"oidc_jwks_url" comment:"JWKS地址"  validate:""`                   //JWKS地址
+	OidcStaticKeys     string `json:"oidc_static_keys" form:"oidc_static_keys" comment:"静态PEM公钥"  validate:""`            //静态PEM公钥
+	OidcJwksRefresh    int    `json:"oidc_jwks_refresh" form:"oidc_jwks_refresh" comment:"JWKS刷新间隔"  validate:"min=0"`    //JWKS刷新间隔, 单位s
+	OidcRequiredClaims string `json:"oidc_required_claims" form:"oidc_required_claims" comment:"必须的claim"  validate:""`   //必须的claim
+	OidcAppClaim       string `json:"oidc_app_claim" form:"oidc_app_claim" comment:"映射租户的claim"  validate:""`             //映射租户的claim
+	OidcClaimHeaders   string `json:"oidc_claim_headers" form:"oidc_claim_headers" comment:"透传claim header"  validate:""` //透传claim header
+
+	OpenForwardAuth            int    `json:"open_forward_auth" form:"open_forward_auth" comment:"是否开启外部鉴权"  validate:"max=1,min=0"`                  //是否开启外部鉴权
+	ForwardAuthUrl             string `json:"forward_auth_url" form:"forward_auth_url" comment:"外部鉴权地址"  validate:""`                                 //外部鉴权地址
+	ForwardAuthRequestHeaders  string `json:"forward_auth_request_headers" form:"forward_auth_request_headers" comment:"透传给鉴权服务的header"  validate:""` //透传给鉴权服务的header
+	ForwardAuthResponseHeaders string `json:"forward_auth_response_headers" form:"forward_auth_response_headers" comment:"复制到下游的header"  validate:""` //复制到下游的header
+	ForwardAuthCacheTtl        int    `json:"forward_auth_cache_ttl" form:"forward_auth_cache_ttl" comment:"鉴权结果缓存时间"  validate:"min=0"`              //鉴权结果缓存时间, 单位s
+	ForwardAuthTimeout         int    `json:"forward_auth_timeout" form:"forward_auth_timeout" comment:"鉴权请求超时"  validate:"min=0"`                    //鉴权请求超时, 单位ms
 }
 
 func (param *ServiceAddHttpInput) BindValidParam(c *gin.Context) error {
@@ -76,6 +214,28 @@ func (param *ServiceDeleteInput) BindValidParam(c *gin.Context) error {
 	return public.DefaultGetValidParams(c, param)
 }
 
+type ServiceCachePurgeInput struct {
+	ID         int64  `json:"id" form:"id" comment:"服务ID" example:"56" validate:"required"`                    //id
+	PathPrefix string `json:"path_prefix" form:"path_prefix" comment:"path前缀" example:"/api/user" validate:""` //客户端请求的path前缀，为空时清除服务全部缓存
+}
+
+func (param *ServiceCachePurgeInput) BindValidParam(c *gin.Context) error {
+	return public.DefaultGetValidParams(c, param)
+}
+
+type ServiceCachePurgeOutput struct {
+	Count int `json:"count" form:"count" comment:"清除的共享缓存条数"` //清除的redis缓存条数，内存缓存由各代理实例异步清除
+}
+
+type ServiceStatRangeInput struct {
+	ID    int64  `json:"id" form:"id" comment:"服务ID" example:"56" validate:"required"`
+	Range string `json:"range" form:"range" comment:"时间范围 1h/24h/7d" example:"1h" validate:"required,oneof=1h 24h 7d"`
+}
+
+func (param *ServiceStatRangeInput) BindValidParam(c *gin.Context) error {
+	return public.DefaultGetValidParams(c, param)
+}
+
 type ServiceListInput struct {
 	Info     string `json:"info" form:"info" comment:"关键词" example:"" validate:""`
 	PageNo   int    `json:"page_no" form:"page_no" comment:"页数" example:"1" validate:"required"`
@@ -95,6 +255,7 @@ type ServiceListItemOutput struct {
 	Qps         int64  `json:"qps" form:"qps"`                   //qps
 	Qpd         int64  `json:"qpd" form:"qpd"`                   //qpd
 	TotalNode   int    `json:"total_node" form:"total_node"`     //节点数
+	HealthyNode int    `json:"healthy_node" form:"healthy_node"` //存活节点数，-1表示没有代理上报
 }
 
 type ServiceListOutput struct {
@@ -52,6 +52,7 @@ CREATE TABLE `gateway_app` (
                                `white_ips` varchar(1000) NOT NULL DEFAULT '' COMMENT 'ip白名单，支持前缀匹配',
                                `qpd` bigint(20) NOT NULL DEFAULT '0' COMMENT '日请求量限制',
                                `qps` bigint(20) NOT NULL DEFAULT '0' COMMENT '每秒请求量限制',
+                               `month_quota` bigint(20) NOT NULL DEFAULT '0' COMMENT '月请求量限制',
                                `create_at` datetime NOT NULL COMMENT '添加时间',
                                `update_at` datetime NOT NULL COMMENT '更新时间',
                                `is_delete` tinyint(4) NOT NULL DEFAULT '0' COMMENT '是否删除 1=删除'
@@ -81,7 +82,9 @@ CREATE TABLE `gateway_service_access_control` (
                                                   `white_list` varchar(1000) NOT NULL DEFAULT '' COMMENT '白名单ip',
                                                   `white_host_name` varchar(1000) NOT NULL DEFAULT '' COMMENT '白名单主机',
                                                   `clientip_flow_limit` int(11) NOT NULL DEFAULT '0' COMMENT '客户端ip限流',
-                                                  `service_flow_limit` int(20) NOT NULL DEFAULT '0' COMMENT '服务端限流'
+                                                  `service_flow_limit` int(20) NOT NULL DEFAULT '0' COMMENT '服务端限流',
+                                                  `need_client_cert` tinyint(4) NOT NULL DEFAULT '0' COMMENT '是否要求客户端证书 1=要求',
+                                                  `client_cert_app_key` varchar(20) NOT NULL DEFAULT '' COMMENT '映射租户app_id的证书字段 cn=subject CN san=SAN'
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8 COMMENT='网关权限控制表';
 
 --
@@ -130,11 +133,45 @@ CREATE TABLE `gateway_service_http_rule` (
                                              `service_id` bigint(20) NOT NULL COMMENT '服务id',
                                              `rule_type` tinyint(4) NOT NULL DEFAULT '0' COMMENT '匹配类型 0=url前缀url_prefix 1=域名domain ',
                                              `rule` varchar(255) NOT NULL DEFAULT '' COMMENT 'type=domain表示域名，type=url_prefix时表示url前缀',
-                                             `need_https` tinyint(4) NOT NULL DEFAULT '0' COMMENT '支持https 1=支持',
+                                             `need_https` tinyint(4) NOT NULL DEFAULT '0' COMMENT '客户端必须使用https 1=是，http请求跳转到https',
                                              `need_strip_uri` tinyint(4) NOT NULL DEFAULT '0' COMMENT '启用strip_uri 1=启用',
                                              `need_websocket` tinyint(4) NOT NULL DEFAULT '0' COMMENT '是否支持websocket 1=支持',
-                                             `url_rewrite` varchar(5000) NOT NULL DEFAULT '' COMMENT 'url重写功能 格式：^/gatekeeper/test_service(.*) $1 多个逗号间隔',
-                                             `header_transfor` varchar(5000) NOT NULL DEFAULT '' COMMENT 'header转换支持增加(add)、删除(del)、修改(edit) 格式: add headname headvalue 多个逗号间隔'
+                                             `need_stream` tinyint(4) NOT NULL DEFAULT '0' COMMENT '流式/长轮询服务 1=不受全局写超时限制',
+                                             `url_rewrite` varchar(5000) NOT NULL DEFAULT '' COMMENT 'url重写 JSON: [{"match":"^/abc/(.*)","replace":"/$1"}]，replace支持${var}变量；兼容旧格式 ^/abc(.*) $1 多个逗号间隔',
+                                             `header_transfor` varchar(5000) NOT NULL DEFAULT '' COMMENT 'header转换 JSON: [{"op":"set","name":"X-Tenant","value":"${app.id}"}] op=set/append/del，value支持${var}变量；兼容旧格式 add headname headvalue 多个逗号间隔',
+                                             `log_body` tinyint(4) NOT NULL DEFAULT '0' COMMENT '记录请求体 1=开启',
+                                             `log_body_max_size` int(11) NOT NULL DEFAULT '0' COMMENT '请求体最大记录字节 0=使用全局配置',
+                                             `max_request_body_size` bigint(20) NOT NULL DEFAULT '0' COMMENT '请求体最大字节 0=不限制',
+                                             `max_response_body_size` bigint(20) NOT NULL DEFAULT '0' COMMENT '响应体最大字节 0=不限制',
+                                             `max_header_count` int(11) NOT NULL DEFAULT '0' COMMENT '请求header最大个数 0=不限制',
+                                             `max_header_size` int(11) NOT NULL DEFAULT '0' COMMENT '请求header最大字节 0=不限制',
+                                             `websocket_max_conn` int(11) NOT NULL DEFAULT '0' COMMENT 'websocket最大连接数 0=不限制',
+                                             `websocket_idle_timeout` int(11) NOT NULL DEFAULT '0' COMMENT 'websocket空闲超时, 单位s 0=不限制',
+                                             `websocket_ping_interval` int(11) NOT NULL DEFAULT '0' COMMENT 'websocket ping间隔, 单位s 0=不发送',
+                                             `upstream_https` tinyint(4) NOT NULL DEFAULT '0' COMMENT '上游使用https 1=是',
+                                             `hsts_max_age` int(11) NOT NULL DEFAULT '0' COMMENT 'HSTS有效期, 单位s 0=不下发',
+                                             `hsts_include_subdomains` tinyint(4) NOT NULL DEFAULT '0' COMMENT 'HSTS包含子域名 1=是',
+                                             `response_header_transfor` varchar(5000) NOT NULL DEFAULT '' COMMENT '响应header转换 格式同header_transfor',
+                                             `open_cors` tinyint(4) NOT NULL DEFAULT '0' COMMENT '开启跨域 1=开启，预检请求由网关应答',
+                                             `cors_allow_origins` varchar(2000) NOT NULL DEFAULT '' COMMENT '允许的来源 多个逗号间隔，支持*与通配子域名',
+                                             `cors_allow_methods` varchar(255) NOT NULL DEFAULT '' COMMENT '允许的方法 为空=常用方法',
+                                             `cors_allow_headers` varchar(1000) NOT NULL DEFAULT '' COMMENT '允许的请求header 为空=回显预检请求',
+                                             `cors_expose_headers` varchar(1000) NOT NULL DEFAULT '' COMMENT '暴露给浏览器的响应header',
+                                             `cors_allow_credentials` tinyint(4) NOT NULL DEFAULT '0' COMMENT '允许携带凭证 1=允许',
+                                             `cors_max_age` int(11) NOT NULL DEFAULT '0' COMMENT '预检结果缓存时间, 单位s',
+                                             `open_cache` tinyint(4) NOT NULL DEFAULT '0' COMMENT '开启响应缓存 1=开启，只缓存GET/HEAD',
+                                             `cache_backend` varchar(20) NOT NULL DEFAULT '' COMMENT '缓存存储 memory=内存 redis=共享',
+                                             `cache_ttl` int(11) NOT NULL DEFAULT '0' COMMENT '默认缓存时间, 单位s 上游未指定时使用',
+                                             `cache_force_ttl` tinyint(4) NOT NULL DEFAULT '0' COMMENT '忽略上游max-age 1=始终使用cache_ttl',
+                                             `cache_stale_ttl` int(11) NOT NULL DEFAULT '0' COMMENT '过期后返回旧数据并后台刷新的时间, 单位s',
+                                             `cache_key_ignore_query` tinyint(4) NOT NULL DEFAULT '0' COMMENT '缓存key忽略query 1=忽略',
+                                             `cache_key_headers` varchar(1000) NOT NULL DEFAULT '' COMMENT '参与缓存key的请求header 多个逗号间隔',
+                                             `cache_key_app` tinyint(4) NOT NULL DEFAULT '0' COMMENT '缓存key区分租户 1=区分',
+                                             `open_compress` tinyint(4) NOT NULL DEFAULT '0' COMMENT '开启响应压缩 1=开启，按Accept-Encoding协商',
+                                             `compress_encodings` varchar(50) NOT NULL DEFAULT '' COMMENT '压缩编码 br,gzip按优先级排列 为空=br,gzip',
+                                             `compress_min_size` int(11) NOT NULL DEFAULT '0' COMMENT '最小压缩字节 0=1024',
+                                             `compress_content_types` varchar(1000) NOT NULL DEFAULT '' COMMENT '允许压缩的content-type 多个逗号间隔 为空=常见文本类型',
+                                             `decompress_request` tinyint(4) NOT NULL DEFAULT '0' COMMENT '解压gzip/br请求体后转发 1=开启'
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8 COMMENT='网关路由匹配表';
 
 --
@@ -156,6 +193,18 @@ INSERT INTO `gateway_service_http_rule` (`id`, `service_id`, `rule_type`, `rule`
                                                                                                                                                                           (179, 60, 0, '/test_strip_uri', 0, 1, 0, '^/aaa/(.*) /bbb/$1', ''),
                                                                                                                                                                           (180, 61, 0, '/test_https_server', 1, 1, 0, '', '');
 
+--
+-- need_https拆分为客户端https与上游https，原need_https=1的服务保持上游使用https；
+-- 域名接入服务(自动签发证书)与要求客户端证书的服务本就经https访问，保留need_https，
+-- 其余服务清除need_https，避免升级后这些服务的http请求被跳转到https；需要客户端https的服务在后台重新开启
+-- 已有库升级：ALTER TABLE `gateway_service_http_rule` ADD `upstream_https` tinyint(4) NOT NULL DEFAULT '0' COMMENT '上游使用https 1=是', ADD `hsts_max_age` int(11) NOT NULL DEFAULT '0' COMMENT 'HSTS有效期, 单位s 0=不下发', ADD `hsts_include_subdomains` tinyint(4) NOT NULL DEFAULT '0' COMMENT 'HSTS包含子域名 1=是';
+--
+
+UPDATE `gateway_service_http_rule` SET `upstream_https` = `need_https`;
+UPDATE `gateway_service_http_rule` `r` LEFT JOIN `gateway_service_access_control` `a` ON `a`.`service_id` = `r`.`service_id`
+SET `r`.`need_https` = 0
+WHERE `r`.`rule_type` <> 1 AND IFNULL(`a`.`need_client_cert`, 0) <> 1;
+
 -- --------------------------------------------------------
 
 --
@@ -222,7 +271,15 @@ CREATE TABLE `gateway_service_load_balance` (
                                                 `upstream_connect_timeout` int(11) NOT NULL DEFAULT '0' COMMENT '建立连接超时, 单位s',
                                                 `upstream_header_timeout` int(11) NOT NULL DEFAULT '0' COMMENT '获取header超时, 单位s',
                                                 `upstream_idle_timeout` int(10) NOT NULL DEFAULT '0' COMMENT '链接最大空闲时间, 单位s',
-                                                `upstream_max_idle` int(11) NOT NULL DEFAULT '0' COMMENT '最大空闲链接数'
+                                                `upstream_max_idle` int(11) NOT NULL DEFAULT '0' COMMENT '最大空闲链接数',
+                                                `upstream_request_timeout` int(11) NOT NULL DEFAULT '0' COMMENT '请求总超时, 单位s 0=不限制',
+                                                `upstream_stream_idle_timeout` int(11) NOT NULL DEFAULT '0' COMMENT '响应流空闲超时, 单位s 0=不限制',
+                                                `upstream_tls_ca_file` varchar(255) NOT NULL DEFAULT '' COMMENT '校验上游证书的CA文件 为空=系统根证书',
+                                                `upstream_tls_cert_file` varchar(255) NOT NULL DEFAULT '' COMMENT '双向认证客户端证书文件',
+                                                `upstream_tls_key_file` varchar(255) NOT NULL DEFAULT '' COMMENT '双向认证客户端私钥文件',
+                                                `upstream_tls_server_name` varchar(255) NOT NULL DEFAULT '' COMMENT '覆盖上游SNI',
+                                                `upstream_tls_min_version` varchar(8) NOT NULL DEFAULT '' COMMENT '最低TLS版本 1.0/1.1/1.2/1.3',
+                                                `upstream_tls_insecure` tinyint(4) NOT NULL DEFAULT '0' COMMENT '跳过上游证书校验 1=是，仅用于测试'
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8 COMMENT='网关负载表';
 
 --
@@ -285,6 +342,69 @@ INSERT INTO `gateway_service_tcp_rule` (`id`, `service_id`, `port`) VALUES
                                                                         (180, 55, 8010),
                                                                         (181, 57, 8011);
 
+-- --------------------------------------------------------
+
+--
+-- 表的结构 `gateway_service_oidc_auth`
+--
+
+CREATE TABLE `gateway_service_oidc_auth` (
+                                             `id` bigint(20) NOT NULL AUTO_INCREMENT COMMENT '自增主键',
+                                             `service_id` bigint(20) NOT NULL DEFAULT '0' COMMENT '服务id',
+                                             `open_oidc` tinyint(4) NOT NULL DEFAULT '0' COMMENT '是否开启外部OIDC校验 1=开启',
+                                             `issuer` varchar(255) NOT NULL DEFAULT '' COMMENT 'token签发方iss',
+                                             `audience` varchar(1000) NOT NULL DEFAULT '' COMMENT 'token受众aud 多个逗号间隔',
+                                             `jwks_url` varchar(1000) NOT NULL DEFAULT '' COMMENT 'JWKS地址',
+                                             `static_keys` text NOT NULL COMMENT '静态PEM公钥',
+                                             `jwks_refresh` int(11) NOT NULL DEFAULT '0' COMMENT 'JWKS刷新间隔, 单位s',
+                                             `required_claims` varchar(1000) NOT NULL DEFAULT '' COMMENT '必须的claim 格式: claim value 多个逗号间隔',
+                                             `app_claim` varchar(255) NOT NULL DEFAULT '' COMMENT '映射到租户app_id的claim',
+                                             `claim_headers` varchar(1000) NOT NULL DEFAULT '' COMMENT '透传claim 格式: claim headname 多个逗号间隔',
+                                             PRIMARY KEY (`id`),
+                                             KEY `idx_service_id` (`service_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8 COMMENT='网关外部OIDC校验表';
+
+-- --------------------------------------------------------
+
+--
+-- 表的结构 `gateway_service_forward_auth`
+--
+
+CREATE TABLE `gateway_service_forward_auth` (
+                                                `id` bigint(20) NOT NULL AUTO_INCREMENT COMMENT '自增主键',
+                                                `service_id` bigint(20) NOT NULL DEFAULT '0' COMMENT '服务id',
+                                                `open_forward_auth` tinyint(4) NOT NULL DEFAULT '0' COMMENT '是否开启外部鉴权 1=开启',
+                                                `auth_url` varchar(1000) NOT NULL DEFAULT '' COMMENT '外部鉴权服务地址',
+                                                `request_headers` varchar(1000) NOT NULL DEFAULT '' COMMENT '透传给鉴权服务的header 多个逗号间隔',
+                                                `response_headers` varchar(1000) NOT NULL DEFAULT '' COMMENT '鉴权通过后复制到下游的header 多个逗号间隔',
+                                                `cache_ttl` int(11) NOT NULL DEFAULT '0' COMMENT '鉴权结果缓存时间, 单位s',
+                                                `timeout` int(11) NOT NULL DEFAULT '0' COMMENT '鉴权请求超时, 单位ms',
+                                                PRIMARY KEY (`id`),
+                                                KEY `idx_service_id` (`service_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8 COMMENT='网关外部鉴权表';
+
+-- --------------------------------------------------------
+
+--
+-- 表的结构 `gateway_certificate`
+--
+
+CREATE TABLE `gateway_certificate` (
+                                       `id` bigint(20) NOT NULL AUTO_INCREMENT COMMENT '自增主键',
+                                       `name` varchar(255) NOT NULL DEFAULT '' COMMENT '证书名称',
+                                       `domains` varchar(2000) NOT NULL DEFAULT '' COMMENT '证书域名 多个逗号间隔',
+                                       `cert_pem` text NOT NULL COMMENT 'PEM证书链',
+                                       `key_pem` text NOT NULL COMMENT 'AES-GCM加密后的PEM私钥',
+                                       `is_default` tinyint(4) NOT NULL DEFAULT '0' COMMENT '是否默认证书 1=是',
+                                       `source` varchar(32) NOT NULL DEFAULT 'upload' COMMENT '证书来源 upload=上传 acme=自动签发',
+                                       `not_before` datetime NOT NULL COMMENT '生效时间',
+                                       `not_after` datetime NOT NULL COMMENT '过期时间',
+                                       `create_at` datetime NOT NULL COMMENT '添加时间',
+                                       `update_at` datetime NOT NULL COMMENT '更新时间',
+                                       `is_delete` tinyint(4) NOT NULL DEFAULT '0' COMMENT '是否删除 1=删除',
+                                       PRIMARY KEY (`id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8 COMMENT='网关证书表';
+
 --
 -- Indexes for dumped tables
 --
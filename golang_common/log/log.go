@@ -30,9 +30,13 @@ type Record struct {
 	code  string
 	info  string
 	level int
+	plain bool
 }
 
 func (r *Record) String() string {
+	if r.plain {
+		return r.info + "\n"
+	}
 	return fmt.Sprintf("[%s][%s][%s] %s\n", LEVEL_FLAGS[r.level], r.time, r.code, r.info)
 }
 
@@ -59,6 +63,7 @@ type Logger struct {
 	c           chan bool
 	layout      string
 	recordPool  *sync.Pool
+	plain       bool
 }
 
 func NewLogger() *Logger {
@@ -66,6 +71,17 @@ func NewLogger() *Logger {
 		takeup = true	//默认启动标志
 		return logger_default
 	}
+	return newLogger()
+}
+
+// NewPlainLogger 创建独立的logger，只输出记录内容，不带级别、时间和代码位置前缀，用于访问日志
+func NewPlainLogger() *Logger {
+	l := newLogger()
+	l.plain = true
+	return l
+}
+
+func newLogger() *Logger {
 	l := new(Logger)
 	l.writers = []Writer{}
 	l.tunnel = make(chan *Record, tunnel_size_default)
@@ -161,6 +177,7 @@ func (l *Logger) deliverRecordToWriter(level int, format string, args ...interfa
 	r.code = code
 	r.time = l.lastTimeStr
 	r.level = level
+	r.plain = l.plain
 
 	l.tunnel <- r
 }
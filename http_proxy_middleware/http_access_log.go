@@ -0,0 +1,74 @@
+package http_proxy_middleware
+
+import (
+	"FGateWay/dao"
+	"FGateWay/public"
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/trace"
+	"io"
+	"time"
+)
+
+// 统计实际读取的请求体字节数，chunked请求没有ContentLength
+type countingReadCloser struct {
+	io.ReadCloser
+	n int64
+}
+
+func (r *countingReadCloser) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.n += int64(n)
+	return n, err
+}
+
+// 每个代理请求输出一行访问日志，未开启访问日志时直接跳过
+func HTTPAccessLogMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if public.AccessLogHandler == nil {
+			c.Next()
+			return
+		}
+		start := time.Now()
+		var body *countingReadCloser
+		if c.Request.Body != nil {
+			body = &countingReadCloser{ReadCloser: c.Request.Body}
+			c.Request.Body = body
+		}
+
+		c.Next()
+
+		entry := &public.AccessLogEntry{
+			Time:      start,
+			ClientIP:  c.ClientIP(),
+			Method:    c.Request.Method,
+			Host:      c.Request.Host,
+			URI:       c.Request.RequestURI,
+			Proto:     c.Request.Proto,
+			Status:    getResponseStatus(c),
+			Upstream:  c.GetString("upstream"),
+			BytesOut:  int64(c.Writer.Size()),
+			Latency:   float64(time.Since(start).Microseconds()) / 1000,
+			Referer:   c.Request.Referer(),
+			UserAgent: c.Request.UserAgent(),
+		}
+		if entry.BytesOut < 0 {
+			entry.BytesOut = 0
+		}
+		if body != nil {
+			entry.BytesIn = body.n
+		}
+		if spanContext := trace.SpanContextFromContext(c.Request.Context()); spanContext.HasTraceID() {
+			entry.TraceID = spanContext.TraceID().String()
+		}
+		if serviceInterface, ok := c.Get("service"); ok {
+			entry.Service = serviceInterface.(*dao.ServiceDetail).Info.ServiceName
+		}
+		if appInterface, ok := c.Get("app"); ok {
+			entry.App = appInterface.(*dao.App).AppID
+		}
+		if upstreamLatency, ok := c.Get("upstream_latency"); ok {
+			entry.UpstreamLatency = float64(upstreamLatency.(time.Duration).Microseconds()) / 1000
+		}
+		public.AccessLogHandler.Log(entry)
+	}
+}
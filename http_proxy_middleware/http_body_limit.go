@@ -0,0 +1,51 @@
+package http_proxy_middleware
+
+import (
+	"FGateWay/dao"
+	"FGateWay/middleware"
+	"FGateWay/public"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	"net/http"
+)
+
+// 按服务限制请求header与请求体大小，超限的请求不会转发到上游
+func HTTPBodyLimitMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		serverInterface, ok := c.Get("service")
+		if !ok {
+			middleware.ResponseError(c, 2001, errors.New("service not found"))
+			c.Abort()
+			return
+		}
+		httpRule := serverInterface.(*dao.ServiceDetail).HTTPRule
+
+		if httpRule.MaxHeaderCount > 0 || httpRule.MaxHeaderSize > 0 {
+			count, size := public.HeaderStat(c.Request.Header)
+			if httpRule.MaxHeaderCount > 0 && count > httpRule.MaxHeaderCount {
+				middleware.ResponseErrorStatus(c, http.StatusRequestHeaderFieldsTooLarge, 6002, errors.New(fmt.Sprintf("header count %v exceeds limit %v", count, httpRule.MaxHeaderCount)))
+				return
+			}
+			if httpRule.MaxHeaderSize > 0 && size > httpRule.MaxHeaderSize {
+				middleware.ResponseErrorStatus(c, http.StatusRequestHeaderFieldsTooLarge, 6002, errors.New(fmt.Sprintf("header size %v exceeds limit %v", size, httpRule.MaxHeaderSize)))
+				return
+			}
+		}
+
+		if httpRule.MaxRequestBodySize > 0 {
+			limit := int64(httpRule.MaxRequestBodySize)
+			if c.Request.ContentLength > limit {
+				middleware.ResponseErrorStatus(c, http.StatusRequestEntityTooLarge, 6001, errors.New(fmt.Sprintf("request body size %v exceeds limit %v", c.Request.ContentLength, limit)))
+				return
+			}
+			//chunked请求无法预知长度，转发时边读边计数，超限由反向代理返回413
+			if c.Request.Body != nil && c.Request.Body != http.NoBody {
+				body := public.NewLimitedBody(c.Request.Body, limit)
+				c.Request.Body = body
+				c.Set("body_limit", body)
+			}
+		}
+		c.Next()
+	}
+}
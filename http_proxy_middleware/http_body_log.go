@@ -0,0 +1,33 @@
+package http_proxy_middleware
+
+import (
+	"FGateWay/dao"
+	"FGateWay/middleware"
+	"FGateWay/public"
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+)
+
+// 按服务开启请求体记录，body在转发给上游时旁路采集
+func HTTPBodyLogMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		serverInterface, ok := c.Get("service")
+		if !ok {
+			middleware.ResponseError(c, 2001, errors.New("service not found"))
+			c.Abort()
+			return
+		}
+		serviceDetail := serverInterface.(*dao.ServiceDetail)
+
+		if captureInterface, ok := c.Get("body_capture"); ok {
+			capture := captureInterface.(*public.BodyCapture)
+			conf := public.GetBodyCaptureConf()
+			capture.Enabled = serviceDetail.HTTPRule.LogBody == 1 &&
+				public.BodyContentTypeAllowed(c.GetHeader("Content-Type"), conf.ContentTypes)
+			if serviceDetail.HTTPRule.LogBodyMaxSize > 0 {
+				capture.MaxSize = serviceDetail.HTTPRule.LogBodyMaxSize
+			}
+		}
+		c.Next()
+	}
+}
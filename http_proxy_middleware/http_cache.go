@@ -0,0 +1,414 @@
+package http_proxy_middleware
+
+import (
+	"FGateWay/dao"
+	"FGateWay/middleware"
+	"FGateWay/public"
+	"FGateWay/reverse_proxy"
+	"bytes"
+	"context"
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// 后台刷新上游的超时时间
+const cacheRevalidateTimeout = 30 * time.Second
+
+// 正在后台刷新的缓存key，同一key只发起一次
+var cacheRevalidating sync.Map
+
+// 响应缓存：只缓存GET/HEAD，新鲜时直接返回；过期但在stale时间内先返回旧数据再后台刷新；
+// 超过stale时间但有ETag/Last-Modified时向上游发条件请求，304则继续使用缓存
+func HTTPCacheMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		serverInterface, ok := c.Get("service")
+		if !ok {
+			middleware.ResponseError(c, 2001, errors.New("service not found"))
+			c.Abort()
+			return
+		}
+		serviceDetail := serverInterface.(*dao.ServiceDetail)
+		if serviceDetail.HTTPRule.OpenCache != 1 || c.GetBool("websocket") ||
+			(c.Request.Method != http.MethodGet && c.Request.Method != http.MethodHead) {
+			c.Next()
+			return
+		}
+		bypass, noStore := public.RequestBypass(c.Request)
+		if noStore {
+			c.Next()
+			return
+		}
+
+		policy := serviceDetail.HTTPRule.GetCachePolicy()
+		store := public.GetCacheStore(policy.Backend)
+		serviceName := serviceDetail.Info.ServiceName
+		appID := cacheAppID(c)
+		now := time.Now()
+
+		var entry *public.CacheEntry
+		if !bypass {
+			var err error
+			if entry, err = lookupCache(store, policy, serviceName, c.Request, appID); err != nil {
+				log.Printf(" [ERROR] HTTPCacheMiddleware lookup err:%v\n", err)
+			}
+		}
+		if entry != nil && entry.Fresh(now) {
+			serveCacheEntry(c, entry, public.CacheStatusHit, c.Writer.Header().Clone())
+			return
+		}
+		if entry != nil && entry.Stale(now) {
+			revalidateInBackground(c, store, policy, entry)
+			serveCacheEntry(c, entry, public.CacheStatusStale, c.Writer.Header().Clone())
+			return
+		}
+
+		c.Header(public.HeaderXCache, public.CacheStatusMiss)
+		snapshot := c.Writer.Header().Clone()
+		clientHeader := c.Request.Header.Clone()
+		//客户端的条件请求不透传，保证上游返回完整响应以便缓存
+		c.Request.Header.Del("If-None-Match")
+		c.Request.Header.Del("If-Modified-Since")
+		revalidate := entry != nil && entry.HasValidator()
+		if revalidate {
+			setValidators(c.Request.Header, entry.Header)
+		}
+		capture := newCacheCaptureWriter(c.Writer, snapshot, public.GetResponseCacheConf().MaxBodySize, revalidate)
+		c.Writer = capture
+		c.Next()
+		c.Writer = capture.ResponseWriter
+		c.Request.Header = clientHeader
+
+		if c.GetBool("upstream_error") {
+			return
+		}
+		if revalidate && capture.status == http.StatusNotModified {
+			entry = refreshCacheEntry(store, policy, c.Request, appID, entry, capture.header)
+			serveCacheEntry(c, entry, public.CacheStatusRevalidated, snapshot)
+			return
+		}
+		//HEAD响应没有body，不写入缓存
+		if c.Request.Method != http.MethodGet || capture.status != http.StatusOK || capture.overflow {
+			return
+		}
+		if err := storeCacheEntry(store, policy, serviceName, c.Request, appID, capture.header, capture.body.Bytes()); err != nil {
+			log.Printf(" [ERROR] HTTPCacheMiddleware store err:%v\n", err)
+		}
+	}
+}
+
+func cacheAppID(c *gin.Context) string {
+	if appInterface, ok := c.Get("app"); ok {
+		return appInterface.(*dao.App).AppID
+	}
+	return ""
+}
+
+// 客户端请求的原始path，strip_uri与url重写不影响按前缀清除
+func cachePath(r *http.Request) string {
+	if u, err := url.ParseRequestURI(r.RequestURI); err == nil {
+		return u.Path
+	}
+	return r.URL.Path
+}
+
+// 先按不含Vary的key查找，命中Vary标记时再按上游Vary的header取真正的响应
+func lookupCache(store public.CacheStore, policy *public.CachePolicy, service string, r *http.Request, appID string) (*public.CacheEntry, error) {
+	entry, err := store.Get(policy.Key(service, r, appID, nil))
+	if err != nil || entry == nil || !entry.VaryOnly {
+		return entry, err
+	}
+	return store.Get(policy.Key(service, r, appID, entry.Vary))
+}
+
+func storeCacheEntry(store public.CacheStore, policy *public.CachePolicy, service string, r *http.Request, appID string, header http.Header, body []byte) error {
+	now := time.Now()
+	ttl, stale, ok := policy.ResponseTTL(r.Header, header, now)
+	if !ok {
+		return nil
+	}
+	//上游未给出长度时可能是被中断的响应，长度不一致不缓存
+	if length := header.Get("Content-Length"); length != "" && length != strconv.Itoa(len(body)) {
+		return nil
+	}
+	vary := public.VaryHeaders(header)
+	entry := &public.CacheEntry{
+		Key:        policy.Key(service, r, appID, vary),
+		Service:    service,
+		Path:       cachePath(r),
+		StatusCode: http.StatusOK,
+		Header:     header,
+		Body:       body,
+		Vary:       vary,
+	}
+	entry.SetLifetime(now, ttl, stale)
+	return saveCacheEntry(store, policy, r, appID, entry)
+}
+
+// 上游有Vary时另存一条Vary标记，有效期与响应一致
+func saveCacheEntry(store public.CacheStore, policy *public.CachePolicy, r *http.Request, appID string, entry *public.CacheEntry) error {
+	if err := store.Set(entry); err != nil {
+		return err
+	}
+	if len(entry.Vary) == 0 {
+		return nil
+	}
+	marker := &public.CacheEntry{
+		Key:      policy.Key(entry.Service, r, appID, nil),
+		Service:  entry.Service,
+		Path:     entry.Path,
+		Header:   http.Header{},
+		Vary:     entry.Vary,
+		VaryOnly: true,
+	}
+	marker.StoredAt, marker.ExpireAt, marker.StaleUntil, marker.KeepUntil = entry.StoredAt, entry.ExpireAt, entry.StaleUntil, entry.KeepUntil
+	return store.Set(marker)
+}
+
+// 304响应中的header更新到缓存并重新计算有效期，缓存条目可能被并发读取，更新时复制一份
+func refreshCacheEntry(store public.CacheStore, policy *public.CachePolicy, r *http.Request, appID string, entry *public.CacheEntry, header http.Header) *public.CacheEntry {
+	refreshed := *entry
+	refreshed.Header = entry.Header.Clone()
+	for key, values := range header {
+		if key == "Content-Length" {
+			continue
+		}
+		refreshed.Header[key] = values
+	}
+	now := time.Now()
+	ttl, stale, ok := policy.ResponseTTL(r.Header, refreshed.Header, now)
+	if !ok {
+		return entry
+	}
+	refreshed.SetLifetime(now, ttl, stale)
+	if err := saveCacheEntry(store, policy, r, appID, &refreshed); err != nil {
+		log.Printf(" [ERROR] HTTPCacheMiddleware refresh err:%v\n", err)
+	}
+	return &refreshed
+}
+
+func setValidators(reqHeader, header http.Header) {
+	if etag := header.Get("ETag"); etag != "" {
+		reqHeader.Set("If-None-Match", etag)
+	}
+	if lastModified := header.Get("Last-Modified"); lastModified != "" {
+		reqHeader.Set("If-Modified-Since", lastModified)
+	}
+}
+
+// 返回缓存的响应，header以进入缓存中间件前的为基础，去掉转发过程中写入的上游header
+func serveCacheEntry(c *gin.Context, entry *public.CacheEntry, status string, base http.Header) {
+	header := c.Writer.Header()
+	for key := range header {
+		delete(header, key)
+	}
+	for key, values := range base {
+		header[key] = values
+	}
+	for key, values := range entry.Header {
+		header[key] = append([]string(nil), values...)
+	}
+	header.Set(public.HeaderXCache, status)
+	header.Set("Age", strconv.FormatInt(entry.Age(time.Now()), 10))
+	if public.NotModified(c.Request.Header, entry.Header) {
+		header.Del("Content-Length")
+		c.Writer.WriteHeader(http.StatusNotModified)
+		c.Writer.WriteHeaderNow()
+		c.Abort()
+		return
+	}
+	c.Writer.WriteHeader(entry.StatusCode)
+	if c.Request.Method == http.MethodHead {
+		c.Writer.WriteHeaderNow()
+	} else {
+		c.Writer.Write(entry.Body)
+	}
+	c.Abort()
+}
+
+// 后台向上游刷新缓存，使用独立的上下文，不受客户端断开影响
+func revalidateInBackground(c *gin.Context, store public.CacheStore, policy *public.CachePolicy, entry *public.CacheEntry) {
+	if _, loaded := cacheRevalidating.LoadOrStore(entry.Key, true); loaded {
+		return
+	}
+	serviceDetail := c.MustGet("service").(*dao.ServiceDetail)
+	appID := cacheAppID(c)
+	base := c.Writer.Header().Clone()
+	keys := make(map[string]interface{}, len(c.Keys))
+	for key, value := range c.Keys {
+		keys[key] = value
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), cacheRevalidateTimeout)
+	req := c.Request.Clone(ctx)
+	req.Method = http.MethodGet
+	req.Header.Del("If-None-Match")
+	req.Header.Del("If-Modified-Since")
+	if entry.HasValidator() {
+		setValidators(req.Header, entry.Header)
+	}
+
+	go func() {
+		defer func() {
+			cancel()
+			cacheRevalidating.Delete(entry.Key)
+			if err := recover(); err != nil {
+				log.Printf(" [ERROR] HTTPCacheMiddleware revalidate panic:%v\n", err)
+			}
+		}()
+		recorder := newCacheRecorder(base, public.GetResponseCacheConf().MaxBodySize)
+		bc, _ := gin.CreateTestContext(recorder)
+		bc.Request = req
+		bc.Keys = keys
+
+		lb, err := dao.LoadBalancerHandler.GetLoadBalancer(serviceDetail)
+		if err != nil {
+			return
+		}
+		trans, err := dao.TransportorHandler.GetTrans(serviceDetail)
+		if err != nil {
+			return
+		}
+		reverse_proxy.NewLoadBalanceReverseProxy(bc, lb, trans).ServeHTTP(bc.Writer, req)
+		bc.Writer.WriteHeaderNow()
+		if bc.GetBool("upstream_error") {
+			return
+		}
+		switch {
+		case recorder.status == http.StatusNotModified:
+			refreshCacheEntry(store, policy, req, appID, entry, recorder.cacheCapture.header)
+		case recorder.status == http.StatusOK && !recorder.overflow:
+			if err := storeCacheEntry(store, policy, serviceDetail.Info.ServiceName, req, appID, recorder.cacheCapture.header, recorder.body.Bytes()); err != nil {
+				log.Printf(" [ERROR] HTTPCacheMiddleware revalidate store err:%v\n", err)
+			}
+		}
+	}()
+}
+
+// 响应捕获：记录状态码、上游写入的header与body，body超过maxSize后不再缓存
+type cacheCapture struct {
+	base     http.Header
+	maxSize  int
+	status   int
+	header   http.Header
+	body     bytes.Buffer
+	overflow bool
+}
+
+func (cc *cacheCapture) writeHeader(header http.Header, code int) {
+	cc.status = code
+	//只保留转发过程中新增或修改的header
+	cc.header = http.Header{}
+	for key, values := range header {
+		if baseValues, ok := cc.base[key]; ok && equalValues(baseValues, values) {
+			continue
+		}
+		cc.header[key] = append([]string(nil), values...)
+	}
+}
+
+func (cc *cacheCapture) write(b []byte) {
+	if cc.overflow {
+		return
+	}
+	if cc.body.Len()+len(b) > cc.maxSize {
+		cc.overflow = true
+		cc.body.Reset()
+		return
+	}
+	cc.body.Write(b)
+}
+
+func equalValues(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// cacheCaptureWriter 转发给客户端的同时捕获响应；条件刷新时上游的304不写给客户端
+type cacheCaptureWriter struct {
+	gin.ResponseWriter
+	*cacheCapture
+	suppress   bool
+	suppressed bool
+}
+
+func newCacheCaptureWriter(w gin.ResponseWriter, base http.Header, maxSize int, suppress304 bool) *cacheCaptureWriter {
+	return &cacheCaptureWriter{
+		ResponseWriter: w,
+		cacheCapture:   &cacheCapture{base: base, maxSize: maxSize},
+		suppress:       suppress304,
+	}
+}
+
+func (w *cacheCaptureWriter) WriteHeader(code int) {
+	w.writeHeader(w.Header(), code)
+	if w.suppress && code == http.StatusNotModified {
+		w.suppressed = true
+		return
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *cacheCaptureWriter) Write(b []byte) (int, error) {
+	if w.suppressed {
+		return len(b), nil
+	}
+	if w.status == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+	w.write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *cacheCaptureWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func (w *cacheCaptureWriter) WriteHeaderNow() {
+	if w.suppressed {
+		return
+	}
+	w.ResponseWriter.WriteHeaderNow()
+}
+
+// cacheRecorder 后台刷新时代替客户端连接
+type cacheRecorder struct {
+	*cacheCapture
+	header http.Header
+}
+
+func newCacheRecorder(base http.Header, maxSize int) *cacheRecorder {
+	return &cacheRecorder{
+		cacheCapture: &cacheCapture{base: base, maxSize: maxSize},
+		header:       base.Clone(),
+	}
+}
+
+func (r *cacheRecorder) Header() http.Header {
+	return r.header
+}
+
+func (r *cacheRecorder) WriteHeader(code int) {
+	if r.status == 0 {
+		r.writeHeader(r.header, code)
+	}
+}
+
+func (r *cacheRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.WriteHeader(http.StatusOK)
+	}
+	r.write(b)
+	return len(b), nil
+}
@@ -0,0 +1,172 @@
+package http_proxy_middleware
+
+import (
+	"FGateWay/dao"
+	"FGateWay/golang_common/lib"
+	"FGateWay/public"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/viper"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testFixedLB 固定返回一个上游地址
+type testFixedLB struct{ addr string }
+
+func (f *testFixedLB) Add(...string) error        { return nil }
+func (f *testFixedLB) Get(string) (string, error) { return f.addr, nil }
+func (f *testFixedLB) Update()                    {}
+
+func TestHTTPCacheMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	lib.ViperConfMap = map[string]*viper.Viper{"proxy": viper.New()}
+	defer func() { lib.ViperConfMap = nil }()
+
+	var hits, conditional int32
+	version := int32(1)
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		etag := fmt.Sprintf(`"v%d"`, atomic.LoadInt32(&version))
+		w.Header().Set("ETag", etag)
+		w.Header().Set("Cache-Control", "max-age=60")
+		if r.Header.Get("If-None-Match") == etag {
+			atomic.AddInt32(&conditional, 1)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		if r.URL.Path == "/vary" {
+			w.Header().Set("Vary", "X-Lang")
+		}
+		fmt.Fprintf(w, "%s %s", etag, r.Header.Get("X-Lang"))
+	}))
+	defer upstream.Close()
+
+	serviceName := "test_cache_service"
+	serviceDetail := &dao.ServiceDetail{
+		Info:        &dao.ServiceInfo{ServiceName: serviceName},
+		HTTPRule:    &dao.HttpRule{OpenCache: 1, CacheStaleTtl: 60},
+		LoadBalance: &dao.LoadBalance{},
+	}
+	dao.LoadBalancerHandler.LoadBanlanceSlice = append(dao.LoadBalancerHandler.LoadBanlanceSlice,
+		&dao.LoadBalancerItem{LoadBanlance: &testFixedLB{upstream.URL}, ServiceName: serviceName})
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set("service", serviceDetail)
+	}, HTTPCacheMiddleware(), HTTPReverseProxyMiddleware())
+	gateway := httptest.NewServer(router)
+	defer gateway.Close()
+
+	type result struct {
+		code   int
+		xcache string
+		body   string
+	}
+	get := func(path string, header map[string]string) result {
+		req, _ := http.NewRequest(http.MethodGet, gateway.URL+path, nil)
+		for key, value := range header {
+			req.Header.Set(key, value)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return result{resp.StatusCode, resp.Header.Get(public.HeaderXCache), string(body)}
+	}
+	expect := func(name string, got result, code int, xcache, body string) {
+		if got.code != code || got.xcache != xcache || got.body != body {
+			t.Fatalf("%s: expect %d %s %q, got %d %s %q", name, code, xcache, body, got.code, got.xcache, got.body)
+		}
+	}
+	policy := serviceDetail.HTTPRule.GetCachePolicy()
+	store := public.GetCacheStore(policy.Backend)
+	//把缓存调整为已过期，stale为true时仍在stale时间内
+	expire := func(path string, stale bool) {
+		entry, err := lookupCache(store, policy, serviceName, httptest.NewRequest(http.MethodGet, path, nil), "")
+		if err != nil || entry == nil {
+			t.Fatalf("cache of %s not found, err:%v", path, err)
+		}
+		aged := *entry
+		aged.ExpireAt = time.Now().UnixNano() - 1
+		if !stale {
+			aged.StaleUntil = aged.ExpireAt
+		}
+		store.Set(&aged)
+	}
+
+	expect("miss", get("/a", nil), http.StatusOK, public.CacheStatusMiss, `"v1" `)
+	expect("hit", get("/a", nil), http.StatusOK, public.CacheStatusHit, `"v1" `)
+	expect("client conditional", get("/a", map[string]string{"If-None-Match": `"v1"`}), http.StatusNotModified, public.CacheStatusHit, "")
+	if n := atomic.LoadInt32(&hits); n != 1 {
+		t.Fatalf("upstream hits=%d", n)
+	}
+
+	//stale时间内先返回旧数据，后台刷新
+	expire("/a", true)
+	atomic.StoreInt32(&version, 2)
+	expect("stale", get("/a", nil), http.StatusOK, public.CacheStatusStale, `"v1" `)
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		entry, _ := lookupCache(store, policy, serviceName, httptest.NewRequest(http.MethodGet, "/a", nil), "")
+		if entry != nil && entry.Header.Get("ETag") == `"v2"` {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("background revalidate not finished")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	expect("refreshed", get("/a", nil), http.StatusOK, public.CacheStatusHit, `"v2" `)
+
+	//超过stale时间时发条件请求，上游304不写给客户端，返回缓存内容
+	expire("/a", false)
+	expect("revalidated", get("/a", nil), http.StatusOK, public.CacheStatusRevalidated, `"v2" `)
+	if n, cond := atomic.LoadInt32(&hits), atomic.LoadInt32(&conditional); n != 3 || cond != 1 {
+		t.Fatalf("upstream hits=%d conditional=%d", n, cond)
+	}
+	expect("hit after revalidated", get("/a", nil), http.StatusOK, public.CacheStatusHit, `"v2" `)
+
+	//上游有Vary时按header分别缓存，并写入Vary标记
+	expect("vary miss en", get("/vary", map[string]string{"X-Lang": "en"}), http.StatusOK, public.CacheStatusMiss, `"v2" en`)
+	expect("vary hit en", get("/vary", map[string]string{"X-Lang": "en"}), http.StatusOK, public.CacheStatusHit, `"v2" en`)
+	expect("vary miss zh", get("/vary", map[string]string{"X-Lang": "zh"}), http.StatusOK, public.CacheStatusMiss, `"v2" zh`)
+	expect("vary hit zh", get("/vary", map[string]string{"X-Lang": "zh"}), http.StatusOK, public.CacheStatusHit, `"v2" zh`)
+	marker, _ := store.Get(policy.Key(serviceName, httptest.NewRequest(http.MethodGet, "/vary", nil), "", nil))
+	if marker == nil || !marker.VaryOnly || len(marker.Vary) != 1 || marker.Vary[0] != "X-Lang" {
+		t.Fatalf("unexpected vary marker %+v", marker)
+	}
+}
+
+func TestCacheCaptureWriterSuppress304(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	base := http.Header{"X-Base": []string{"1"}}
+	writer := newCacheCaptureWriter(c.Writer, base, 1024, true)
+	writer.Header().Set("X-Base", "1")
+	writer.Header().Set("ETag", `"v1"`)
+	writer.WriteHeader(http.StatusNotModified)
+	writer.Write([]byte("ignored"))
+	writer.WriteHeaderNow()
+	if c.Writer.Written() || recorder.Body.Len() != 0 {
+		t.Fatal("304 should not be written to client")
+	}
+	if writer.status != http.StatusNotModified || writer.header.Get("ETag") != `"v1"` || writer.header.Get("X-Base") != "" {
+		t.Fatalf("unexpected capture %d %v", writer.status, writer.header)
+	}
+
+	//非条件刷新时304照常返回
+	recorder = httptest.NewRecorder()
+	c, _ = gin.CreateTestContext(recorder)
+	writer = newCacheCaptureWriter(c.Writer, http.Header{}, 1024, false)
+	writer.WriteHeader(http.StatusNotModified)
+	writer.WriteHeaderNow()
+	if recorder.Code != http.StatusNotModified {
+		t.Fatalf("code=%d", recorder.Code)
+	}
+}
@@ -0,0 +1,99 @@
+package http_proxy_middleware
+
+import (
+	"FGateWay/dao"
+	"FGateWay/middleware"
+	"crypto/sha256"
+	"crypto/x509"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	"strings"
+)
+
+const (
+	HeaderClientCertSubject     = "X-Client-Cert-Subject"
+	HeaderClientCertSan         = "X-Client-Cert-San"
+	HeaderClientCertFingerprint = "X-Client-Cert-Fingerprint"
+)
+
+// 客户端证书(mTLS)校验，并将证书身份透传给下游
+func HTTPClientCertMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		serverInterface, ok := c.Get("service")
+		if !ok {
+			middleware.ResponseError(c, 2001, errors.New("service not found"))
+			c.Abort()
+			return
+		}
+		serviceDetail := serverInterface.(*dao.ServiceDetail)
+
+		//先删除再设置，防止客户端伪造
+		c.Request.Header.Del(HeaderClientCertSubject)
+		c.Request.Header.Del(HeaderClientCertSan)
+		c.Request.Header.Del(HeaderClientCertFingerprint)
+
+		//只信任经过CA校验的证书链
+		var cert *x509.Certificate
+		if c.Request.TLS != nil && len(c.Request.TLS.VerifiedChains) > 0 && len(c.Request.TLS.VerifiedChains[0]) > 0 {
+			cert = c.Request.TLS.VerifiedChains[0][0]
+		}
+		if cert == nil {
+			if serviceDetail.AccessControl.NeedClientCert == 1 {
+				middleware.ResponseError(c, 2007, errors.New("client certificate required"))
+				c.Abort()
+				return
+			}
+			c.Next()
+			return
+		}
+
+		sanList := GetCertSanList(cert)
+		c.Request.Header.Set(HeaderClientCertSubject, cert.Subject.String())
+		c.Request.Header.Set(HeaderClientCertSan, strings.Join(sanList, ","))
+		c.Request.Header.Set(HeaderClientCertFingerprint, fmt.Sprintf("%x", sha256.Sum256(cert.Raw)))
+
+		identities := []string{}
+		switch serviceDetail.AccessControl.ClientCertAppKey {
+		case "cn":
+			identities = append(identities, cert.Subject.CommonName)
+		case "san":
+			identities = sanList
+		}
+		if len(identities) > 0 {
+			appMatched := false
+			for _, appInfo := range dao.AppManagerHandler.GetAppList() {
+				for _, identity := range identities {
+					if identity != "" && appInfo.AppID == identity {
+						c.Set("app", appInfo)
+						appMatched = true
+						break
+					}
+				}
+				if appMatched {
+					break
+				}
+			}
+			if serviceDetail.AccessControl.OpenAuth == 1 && !appMatched {
+				middleware.ResponseError(c, 2008, errors.New("client certificate not match valid app"))
+				c.Abort()
+				return
+			}
+		}
+		c.Next()
+	}
+}
+
+// GetCertSanList 返回证书中的DNS、Email、IP、URI等SAN
+func GetCertSanList(cert *x509.Certificate) []string {
+	sanList := []string{}
+	sanList = append(sanList, cert.DNSNames...)
+	sanList = append(sanList, cert.EmailAddresses...)
+	for _, ip := range cert.IPAddresses {
+		sanList = append(sanList, ip.String())
+	}
+	for _, uri := range cert.URIs {
+		sanList = append(sanList, uri.String())
+	}
+	return sanList
+}
@@ -0,0 +1,107 @@
+package http_proxy_middleware
+
+import (
+	"FGateWay/dao"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newClientCert(t *testing.T, cn string, dnsNames ...string) *x509.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: cn},
+		DNSNames:     dnsNames,
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
+
+func TestHTTPClientCertMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	dao.AppManagerHandler.AppSlice = []*dao.App{{AppID: "app_a"}, {AppID: "app_b"}}
+	defer func() { dao.AppManagerHandler.AppSlice = nil }()
+
+	cases := []struct {
+		name     string
+		cert     *x509.Certificate
+		control  *dao.AccessControl
+		wantCode int
+		wantApp  string
+	}{
+		{"no cert not required", nil, &dao.AccessControl{}, http.StatusOK, ""},
+		{"no cert required", nil, &dao.AccessControl{NeedClientCert: 1}, 2007, ""},
+		{"cn mapping", newClientCert(t, "app_a"), &dao.AccessControl{NeedClientCert: 1, ClientCertAppKey: "cn"}, http.StatusOK, "app_a"},
+		{"san mapping", newClientCert(t, "other", "x.test.com", "app_b"), &dao.AccessControl{ClientCertAppKey: "san"}, http.StatusOK, "app_b"},
+		{"san ignored for cn", newClientCert(t, "other", "app_b"), &dao.AccessControl{ClientCertAppKey: "cn"}, http.StatusOK, ""},
+		{"unknown app with auth", newClientCert(t, "app_x"), &dao.AccessControl{OpenAuth: 1, ClientCertAppKey: "cn"}, 2008, ""},
+		{"no mapping", newClientCert(t, "app_a"), &dao.AccessControl{OpenAuth: 1}, http.StatusOK, ""},
+	}
+	for _, item := range cases {
+		var gotApp, gotSubject string
+		router := gin.New()
+		router.Use(func(c *gin.Context) {
+			c.Set("service", &dao.ServiceDetail{AccessControl: item.control})
+		}, HTTPClientCertMiddleware())
+		router.GET("/", func(c *gin.Context) {
+			if appInterface, ok := c.Get("app"); ok {
+				gotApp = appInterface.(*dao.App).AppID
+			}
+			gotSubject = c.GetHeader(HeaderClientCertSubject)
+			c.String(http.StatusOK, "ok")
+		})
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		//伪造的证书header应被覆盖
+		req.Header.Set(HeaderClientCertSubject, "CN=forged")
+		if item.cert != nil {
+			req.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{item.cert}}}
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if item.wantCode != http.StatusOK {
+			if w.Body.String() == "ok" || !containsErrno(w.Body.String(), item.wantCode) {
+				t.Errorf("%s: want errno %d, got %s", item.name, item.wantCode, w.Body.String())
+			}
+			continue
+		}
+		if w.Body.String() != "ok" {
+			t.Errorf("%s: unexpected response %s", item.name, w.Body.String())
+		}
+		if gotApp != item.wantApp {
+			t.Errorf("%s: app=%q, want %q", item.name, gotApp, item.wantApp)
+		}
+		if gotSubject == "CN=forged" {
+			t.Errorf("%s: forged subject header passed through", item.name)
+		}
+	}
+}
+
+func containsErrno(body string, code int) bool {
+	return strings.Contains(body, fmt.Sprintf(`"errno":%d`, code))
+}
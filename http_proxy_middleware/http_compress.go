@@ -0,0 +1,207 @@
+package http_proxy_middleware
+
+import (
+	"FGateWay/dao"
+	"FGateWay/middleware"
+	"FGateWay/public"
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// 压缩：请求体按Content-Encoding解压后转发，响应按Accept-Encoding协商压缩。
+// 解压在请求体大小限制之前，限制的是解压后的大小
+func HTTPCompressMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		serverInterface, ok := c.Get("service")
+		if !ok {
+			middleware.ResponseError(c, 2001, errors.New("service not found"))
+			c.Abort()
+			return
+		}
+		httpRule := serverInterface.(*dao.ServiceDetail).HTTPRule
+
+		if encoding := c.GetHeader("Content-Encoding"); httpRule.DecompressRequest == 1 && public.DecompressSupported(encoding) &&
+			c.Request.Body != nil && c.Request.Body != http.NoBody {
+			body, err := public.NewDecompressReader(c.Request.Body, encoding)
+			if err != nil {
+				middleware.ResponseErrorStatus(c, http.StatusBadRequest, 6007, errors.Wrap(err, "decompress request body"))
+				return
+			}
+			c.Request.Body = body
+			c.Request.ContentLength = -1
+			c.Request.Header.Del("Content-Encoding")
+			c.Request.Header.Del("Content-Length")
+		}
+
+		if httpRule.OpenCompress != 1 || c.Request.Method == http.MethodHead {
+			c.Next()
+			return
+		}
+		policy := httpRule.GetCompressPolicy()
+		encoding := policy.Negotiate(c.GetHeader("Accept-Encoding"))
+		//响应是否压缩与Accept-Encoding有关，未压缩时也需告知缓存
+		c.Writer.Header().Add("Vary", "Accept-Encoding")
+		if encoding == "" {
+			c.Next()
+			return
+		}
+		//由网关统一压缩，上游返回未编码的响应
+		c.Request.Header.Del("Accept-Encoding")
+		writer := &compressWriter{ResponseWriter: c.Writer, policy: policy, encoding: encoding}
+		c.Writer = writer
+		defer func() {
+			c.Writer = writer.ResponseWriter
+			if err := writer.Close(); err != nil {
+				log.Printf(" [ERROR] HTTPCompressMiddleware close err:%v\n", err)
+			}
+		}()
+		c.Next()
+	}
+}
+
+// compressWriter 第一次写入时按响应header决定是否压缩；长度未知的响应先缓存到MinSize再决定，
+// 响应结束时不足MinSize的原样返回。长度未知且要求立即刷新的流式响应无法预知大小，在Flush时直接压缩
+type compressWriter struct {
+	gin.ResponseWriter
+	policy   *public.CompressPolicy
+	encoding string
+	status   int
+	started  bool
+	decided  bool
+	buf      []byte
+	writer   public.CompressWriter
+}
+
+func (w *compressWriter) WriteHeader(code int) {
+	if w.decided {
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+	w.status = code
+	//无响应体或协议升级的直接透传
+	if code < http.StatusOK || code == http.StatusNoContent || code == http.StatusNotModified {
+		w.decide(false)
+	}
+}
+
+func (w *compressWriter) WriteHeaderNow() {
+	if !w.decided {
+		w.decide(false)
+	}
+	w.ResponseWriter.WriteHeaderNow()
+}
+
+func (w *compressWriter) Write(b []byte) (int, error) {
+	w.started = true
+	if !w.decided {
+		if w.status == 0 {
+			w.status = http.StatusOK
+		}
+		decided, compress := w.check(len(w.buf) + len(b))
+		if !decided {
+			w.buf = append(w.buf, b...)
+			return len(b), nil
+		}
+		w.decide(compress)
+	}
+	if w.writer != nil {
+		return w.writer.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *compressWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func (w *compressWriter) Written() bool {
+	return w.started || w.ResponseWriter.Written()
+}
+
+func (w *compressWriter) Status() int {
+	if !w.decided && w.status != 0 {
+		return w.status
+	}
+	return w.ResponseWriter.Status()
+}
+
+func (w *compressWriter) Flush() {
+	if !w.decided {
+		_, compress := w.check(len(w.buf))
+		w.decide(compress)
+	}
+	if w.writer != nil {
+		if err := w.writer.Flush(); err != nil {
+			log.Printf(" [ERROR] HTTPCompressMiddleware flush err:%v\n", err)
+		}
+	}
+	w.ResponseWriter.Flush()
+}
+
+func (w *compressWriter) Close() error {
+	if !w.decided {
+		decided, compress := w.check(len(w.buf))
+		w.decide(decided && compress)
+	}
+	if w.writer != nil {
+		return w.writer.Close()
+	}
+	return nil
+}
+
+// check 按响应header与已写入的字节数判断，长度未知且不足MinSize时未决定，compress为true
+func (w *compressWriter) check(pending int) (decided bool, compress bool) {
+	header := w.Header()
+	if !w.policy.Compressible(header) {
+		return true, false
+	}
+	//分段响应压缩后与Content-Range不再对应，原样返回
+	if w.status == http.StatusPartialContent || header.Get("Content-Range") != "" {
+		return true, false
+	}
+	if length := header.Get("Content-Length"); length != "" {
+		size, err := strconv.Atoi(length)
+		return true, err == nil && size >= w.policy.MinSize
+	}
+	return pending >= w.policy.MinSize, true
+}
+
+// decide 写出响应header，缓存的数据按决定的方式写出
+func (w *compressWriter) decide(compress bool) {
+	w.decided = true
+	if compress {
+		writer, err := public.NewCompressWriter(w.ResponseWriter, w.encoding)
+		if err != nil {
+			log.Printf(" [ERROR] HTTPCompressMiddleware err:%v\n", err)
+			compress = false
+		} else {
+			w.writer = writer
+		}
+	}
+	if compress {
+		header := w.Header()
+		header.Set("Content-Encoding", w.encoding)
+		header.Del("Content-Length")
+		header.Del("Accept-Ranges")
+		//压缩后内容与原ETag不再字节一致，改为弱校验
+		if etag := header.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+			header.Set("ETag", "W/"+etag)
+		}
+	}
+	if w.status != 0 {
+		w.ResponseWriter.WriteHeader(w.status)
+	}
+	if len(w.buf) > 0 {
+		buf := w.buf
+		w.buf = nil
+		if w.writer != nil {
+			w.writer.Write(buf)
+		} else {
+			w.ResponseWriter.Write(buf)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package http_proxy_middleware
+
+import (
+	"FGateWay/public"
+	"compress/gzip"
+	"github.com/gin-gonic/gin"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func newTestCompressWriter(minSize int) (*compressWriter, *httptest.ResponseRecorder) {
+	gin.SetMode(gin.TestMode)
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	policy := &public.CompressPolicy{Encodings: []string{public.EncodingGzip}, MinSize: minSize, ContentTypes: public.DefaultCompressContentTypes}
+	w := &compressWriter{ResponseWriter: c.Writer, policy: policy, encoding: public.EncodingGzip}
+	w.Header().Set("Content-Type", "text/plain")
+	return w, recorder
+}
+
+func gunzipBody(t *testing.T, recorder *httptest.ResponseRecorder) string {
+	reader, err := gzip.NewReader(recorder.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestCompressWriterMinSize(t *testing.T) {
+	//长度未知时缓存到MinSize再决定
+	w, recorder := newTestCompressWriter(10)
+	w.Write([]byte("hello"))
+	if w.decided || recorder.Body.Len() != 0 {
+		t.Fatal("should buffer until MinSize")
+	}
+	w.Write([]byte(" world"))
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if recorder.Header().Get("Content-Encoding") != public.EncodingGzip || gunzipBody(t, recorder) != "hello world" {
+		t.Fatalf("unexpected response %v", recorder.Header())
+	}
+
+	//结束时不足MinSize的原样返回
+	w, recorder = newTestCompressWriter(10)
+	w.Write([]byte("hello"))
+	w.Close()
+	if recorder.Header().Get("Content-Encoding") != "" || recorder.Body.String() != "hello" {
+		t.Fatalf("small response should not be compressed %v", recorder.Header())
+	}
+}
+
+func TestCompressWriterFlush(t *testing.T) {
+	//流式响应在Flush时直接压缩，已写入的数据立即发出
+	w, recorder := newTestCompressWriter(1024)
+	w.Write([]byte("data: 1\n\n"))
+	w.Flush()
+	if !w.decided || recorder.Header().Get("Content-Encoding") != public.EncodingGzip || recorder.Body.Len() == 0 {
+		t.Fatalf("flush should decide compression %v", recorder.Header())
+	}
+	w.Write([]byte("data: 2\n\n"))
+	w.Close()
+	if body := gunzipBody(t, recorder); body != "data: 1\n\ndata: 2\n\n" {
+		t.Fatalf("unexpected body %q", body)
+	}
+}
+
+func TestCompressWriterContentLength(t *testing.T) {
+	w, recorder := newTestCompressWriter(10)
+	w.Header().Set("Content-Length", "5")
+	w.Write([]byte("hello"))
+	w.Close()
+	if recorder.Header().Get("Content-Encoding") != "" || recorder.Header().Get("Content-Length") != "5" {
+		t.Fatalf("short content should not be compressed %v", recorder.Header())
+	}
+
+	body := strings.Repeat("a", 20)
+	w, recorder = newTestCompressWriter(10)
+	w.Header().Set("Content-Length", "20")
+	w.Header().Set("ETag", `"abc"`)
+	w.Header().Set("Accept-Ranges", "bytes")
+	w.Write([]byte(body))
+	w.Close()
+	header := recorder.Header()
+	if header.Get("Content-Encoding") != public.EncodingGzip || header.Get("Content-Length") != "" ||
+		header.Get("Accept-Ranges") != "" || header.Get("ETag") != `W/"abc"` {
+		t.Fatalf("unexpected header %v", header)
+	}
+	if gunzipBody(t, recorder) != body {
+		t.Fatal("unexpected body")
+	}
+}
+
+func TestCompressWriterPassThrough(t *testing.T) {
+	for _, code := range []int{http.StatusNotModified, http.StatusNoContent} {
+		w, recorder := newTestCompressWriter(0)
+		w.Header().Set("ETag", `"abc"`)
+		w.WriteHeader(code)
+		w.WriteHeaderNow()
+		w.Close()
+		if recorder.Code != code || recorder.Header().Get("Content-Encoding") != "" || recorder.Header().Get("ETag") != `"abc"` {
+			t.Fatalf("%d should pass through %v", code, recorder.Header())
+		}
+	}
+
+	//分段响应不压缩
+	w, recorder := newTestCompressWriter(0)
+	w.Header().Set("Content-Range", "bytes 0-4/100")
+	w.WriteHeader(http.StatusPartialContent)
+	w.Write([]byte("hello"))
+	w.Close()
+	if recorder.Code != http.StatusPartialContent || recorder.Header().Get("Content-Encoding") != "" || recorder.Body.String() != "hello" {
+		t.Fatalf("partial content should not be compressed %v", recorder.Header())
+	}
+}
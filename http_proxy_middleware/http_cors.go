@@ -0,0 +1,43 @@
+package http_proxy_middleware
+
+import (
+	"FGateWay/dao"
+	"FGateWay/middleware"
+	"FGateWay/public"
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	"net/http"
+)
+
+// 跨域：预检请求由网关直接应答不转发上游，其他请求为允许的来源附加跨域header
+func HTTPCorsMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		serverInterface, ok := c.Get("service")
+		if !ok {
+			middleware.ResponseError(c, 2001, errors.New("service not found"))
+			c.Abort()
+			return
+		}
+		serviceDetail := serverInterface.(*dao.ServiceDetail)
+		origin := c.GetHeader(public.HeaderOrigin)
+		if serviceDetail.HTTPRule.OpenCors != 1 || origin == "" {
+			c.Next()
+			return
+		}
+		policy := serviceDetail.HTTPRule.GetCorsPolicy()
+		if !public.IsPreflight(c.Request) {
+			//来源不允许时不附加header，由浏览器拦截响应
+			if policy.OriginAllowed(origin) {
+				policy.SetHeaders(c.Writer.Header(), c.Request, false)
+			}
+			c.Next()
+			return
+		}
+		if !policy.OriginAllowed(origin) || !policy.MethodAllowed(c.GetHeader(public.HeaderAccessControlRequestMethod)) {
+			c.AbortWithStatus(http.StatusForbidden)
+			return
+		}
+		policy.SetHeaders(c.Writer.Header(), c.Request, true)
+		c.AbortWithStatus(http.StatusNoContent)
+	}
+}
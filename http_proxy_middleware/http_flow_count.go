@@ -6,6 +6,7 @@ import (
 	"FGateWay/public"
 	"errors"
 	"github.com/gin-gonic/gin"
+	"time"
 )
 
 func HTTpFlowCountMiddleware() gin.HandlerFunc {
@@ -36,6 +37,14 @@ func HTTpFlowCountMiddleware() gin.HandlerFunc {
 		}
 
 		serviceCounter.Increase()
+
+		//Record与Increase成对调用，请求panic时也要结束计数器上的进行中请求
+		start := time.Now()
+		defer func() {
+			status, latency, upstreamErr := getResponseStatus(c), time.Since(start), c.GetBool("upstream_error")
+			totalCounter.Record(status, latency, upstreamErr)
+			serviceCounter.Record(status, latency, upstreamErr)
+		}()
 		c.Next()
 	}
 }
@@ -27,6 +27,7 @@ func HTTPFlowLimitMiddleware() gin.HandlerFunc {
 				return
 			}
 			if !serviceLimiter.Allow() {
+				flowLimitRejected(c, public.FlowLimitTypeService)
 				middleware.ResponseError(c, 5002, errors.New(fmt.Sprintf("service flow limit %v", serviceDetail.AccessControl.ServiceFlowLimit)))
 				c.Abort()
 				return
@@ -43,6 +44,7 @@ func HTTPFlowLimitMiddleware() gin.HandlerFunc {
 				return
 			}
 			if !clientLimiter.Allow() {
+				flowLimitRejected(c, public.FlowLimitTypeClientIP)
 				middleware.ResponseError(c, 5002, errors.New(fmt.Sprintf("%v flow limit %v", c.ClientIP(), serviceDetail.AccessControl.ClientIPFlowLimit)))
 				c.Abort()
 				return
@@ -0,0 +1,138 @@
+package http_proxy_middleware
+
+import (
+	"FGateWay/dao"
+	"FGateWay/middleware"
+	"FGateWay/public"
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const forwardAuthMaxBody = 64 * 1024
+
+var forwardAuthClient = &http.Client{
+	CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		//鉴权服务的跳转(如登录页)直接返回给客户端
+		return http.ErrUseLastResponse
+	},
+}
+
+// 外部鉴权：转发子请求到鉴权服务，2xx放行，其他状态直接返回给客户端
+func HTTPForwardAuthMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		serverInterface, ok := c.Get("service")
+		if !ok {
+			middleware.ResponseError(c, 2001, errors.New("service not found"))
+			c.Abort()
+			return
+		}
+		serviceDetail := serverInterface.(*dao.ServiceDetail)
+		forwardAuth := serviceDetail.ForwardAuth
+		if forwardAuth == nil || forwardAuth.OpenForwardAuth != 1 || forwardAuth.AuthUrl == "" {
+			c.Next()
+			return
+		}
+
+		requestHeaders := forwardAuth.GetRequestHeaders()
+		cacheKey := forwardAuthCacheKey(c, serviceDetail.Info.ServiceName, requestHeaders)
+
+		result, hit := public.ForwardAuthCacheHandler.Get(cacheKey)
+		if !hit {
+			var err error
+			result, err = forwardAuthRequest(c, forwardAuth, requestHeaders)
+			if err != nil {
+				middleware.ResponseError(c, 2010, err)
+				c.Abort()
+				return
+			}
+			if forwardAuth.CacheTtl > 0 {
+				result.ExpireAt = time.Now().Add(time.Duration(forwardAuth.CacheTtl) * time.Second)
+				public.ForwardAuthCacheHandler.Set(cacheKey, result)
+			}
+		}
+
+		if result.StatusCode < 200 || result.StatusCode > 299 {
+			for name, values := range result.Header {
+				for _, value := range values {
+					c.Writer.Header().Add(name, value)
+				}
+			}
+			c.Data(result.StatusCode, result.Header.Get("Content-Type"), result.Body)
+			c.Abort()
+			return
+		}
+
+		for _, name := range forwardAuth.GetResponseHeaders() {
+			c.Request.Header.Del(name)
+			if value := result.Header.Get(name); value != "" {
+				c.Request.Header.Set(name, value)
+			}
+		}
+		c.Next()
+	}
+}
+
+// 鉴权结果与请求的方法、host、uri有关，与转发给鉴权服务的X-Forwarded-*一致，避免一个接口的放行结果用于其他接口
+func forwardAuthCacheKey(c *gin.Context, serviceName string, requestHeaders []string) string {
+	parts := []string{
+		"method=" + c.Request.Method,
+		"host=" + c.Request.Host,
+		"uri=" + c.Request.URL.RequestURI(),
+	}
+	for _, name := range requestHeaders {
+		parts = append(parts, name+"="+c.Request.Header.Get(name))
+	}
+	return serviceName + "_" + public.MD5(strings.Join(parts, "&"))
+}
+
+func forwardAuthRequest(c *gin.Context, forwardAuth *dao.ForwardAuth, requestHeaders []string) (*public.ForwardAuthItem, error) {
+	timeout := time.Duration(forwardAuth.Timeout) * time.Millisecond
+	if timeout <= 0 {
+		timeout = 3 * time.Second
+	}
+	req, err := http.NewRequest(c.Request.Method, forwardAuth.AuthUrl, nil)
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range requestHeaders {
+		if value := c.Request.Header.Get(name); value != "" {
+			req.Header.Set(name, value)
+		}
+	}
+	proto := "http"
+	if c.Request.TLS != nil {
+		proto = "https"
+	}
+	req.Header.Set("X-Forwarded-Method", c.Request.Method)
+	req.Header.Set("X-Forwarded-Proto", proto)
+	req.Header.Set("X-Forwarded-Host", c.Request.Host)
+	req.Header.Set("X-Forwarded-Uri", c.Request.URL.RequestURI())
+	req.Header.Set("X-Forwarded-For", c.ClientIP())
+	otel.GetTextMapPropagator().Inject(c.Request.Context(), propagation.HeaderCarrier(req.Header))
+
+	client := *forwardAuthClient
+	client.Timeout = timeout
+	resp, err := client.Do(req.WithContext(c.Request.Context()))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, forwardAuthMaxBody))
+	if err != nil {
+		return nil, err
+	}
+	resp.Header.Del("Content-Length")
+	resp.Header.Del("Connection")
+	return &public.ForwardAuthItem{
+		StatusCode: resp.StatusCode,
+		Header:     resp.Header,
+		Body:       body,
+	}, nil
+}
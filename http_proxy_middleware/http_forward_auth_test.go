@@ -0,0 +1,64 @@
+package http_proxy_middleware
+
+import (
+	"FGateWay/dao"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+func TestHTTPForwardAuthCache(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	var calls int32
+	//只放行GET /public
+	authServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		if r.Header.Get("X-Forwarded-Method") == http.MethodGet && r.Header.Get("X-Forwarded-Uri") == "/public" {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer authServer.Close()
+
+	serviceDetail := &dao.ServiceDetail{
+		Info:        &dao.ServiceInfo{ServiceName: "forward_auth_test"},
+		ForwardAuth: &dao.ForwardAuth{OpenForwardAuth: 1, AuthUrl: authServer.URL, CacheTtl: 60},
+	}
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set("service", serviceDetail)
+	}, HTTPForwardAuthMiddleware())
+	router.Any("/*path", func(c *gin.Context) {
+		c.String(http.StatusOK, "ok")
+	})
+	do := func(method, target string) int {
+		req := httptest.NewRequest(method, target, nil)
+		req.Header.Set("Authorization", "Bearer same_token")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	if code := do(http.MethodGet, "/public"); code != http.StatusOK {
+		t.Fatalf("GET /public code=%d", code)
+	}
+	if code := do(http.MethodGet, "/public"); code != http.StatusOK || atomic.LoadInt32(&calls) != 1 {
+		t.Fatalf("GET /public should hit cache, code=%d calls=%d", code, calls)
+	}
+	//同一token访问其他方法或uri不能复用放行结果
+	if code := do(http.MethodDelete, "/public"); code != http.StatusForbidden {
+		t.Fatalf("DELETE /public code=%d", code)
+	}
+	if code := do(http.MethodGet, "/admin"); code != http.StatusForbidden {
+		t.Fatalf("GET /admin code=%d", code)
+	}
+	if code := do(http.MethodGet, "/public?x=1"); code != http.StatusForbidden {
+		t.Fatalf("GET /public?x=1 code=%d", code)
+	}
+	if n := atomic.LoadInt32(&calls); n != 4 {
+		t.Fatalf("calls=%d, want 4", n)
+	}
+}
@@ -3,9 +3,10 @@ package http_proxy_middleware
 import (
 	"FGateWay/dao"
 	"FGateWay/middleware"
+	"FGateWay/public"
+	"FGateWay/reverse_proxy"
 	"errors"
 	"github.com/gin-gonic/gin"
-	"strings"
 )
 
 // 匹配接入方式 基于请求信息
@@ -18,18 +19,7 @@ func HTTPHeaderTransferMiddleware() gin.HandlerFunc {
 			return
 		}
 		serviceDetail := serverInterface.(*dao.ServiceDetail)
-		for _, item := range strings.Split(serviceDetail.HTTPRule.HeaderTransfor, ",") {
-			items := strings.Split(item, " ")
-			if len(items) != 3 {
-				continue
-			}
-			if items[0] == "add" || items[0] == "edit" {
-				c.Request.Header.Set(items[1], items[2])
-			}
-			if items[0] == "del" {
-				c.Request.Header.Del(items[1])
-			}
-		}
+		public.ApplyHeaderRules(c.Request.Header, serviceDetail.HTTPRule.GetHeaderRules(), reverse_proxy.RuleVars(c))
 		c.Next()
 	}
 }
@@ -0,0 +1,67 @@
+package http_proxy_middleware
+
+import (
+	"FGateWay/dao"
+	"FGateWay/golang_common/lib"
+	"FGateWay/middleware"
+	"FGateWay/public"
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	"log"
+	"net"
+	"sync"
+)
+
+// 客户端必须使用https的服务：http请求跳转到https监听，https请求按配置下发HSTS
+func HTTPHttpsMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		serverInterface, ok := c.Get("service")
+		if !ok {
+			middleware.ResponseError(c, 2001, errors.New("service not found"))
+			c.Abort()
+			return
+		}
+		serviceDetail := serverInterface.(*dao.ServiceDetail)
+		if serviceDetail.HTTPRule.NeedHttps != 1 {
+			c.Next()
+			return
+		}
+		if !public.IsHttpsRequest(c.Request, httpsTrustedProxies()) {
+			c.Redirect(public.HttpsRedirectCode(c.Request.Method), public.HttpsRedirectURL(c.Request, httpsRedirectPort()))
+			c.Abort()
+			return
+		}
+		hsts := public.HstsHeaderValue(serviceDetail.HTTPRule.HstsMaxAge, serviceDetail.HTTPRule.HstsIncludeSubdomains == 1)
+		if hsts != "" {
+			c.Header(public.HeaderHsts, hsts)
+		}
+		c.Next()
+	}
+}
+
+var (
+	trustedProxies     []*net.IPNet
+	trustedProxiesOnce sync.Once
+)
+
+// 终止tls的负载均衡地址，来自这些地址且X-Forwarded-Proto为https的请求视为https，避免循环跳转
+func httpsTrustedProxies() []*net.IPNet {
+	trustedProxiesOnce.Do(func() {
+		nets, err := public.ParseTrustedProxies(lib.GetStringSliceConf("proxy.http.trusted_proxies"))
+		if err != nil {
+			log.Printf(" [ERROR] parse trusted_proxies err:%v\n", err)
+			return
+		}
+		trustedProxies = nets
+	})
+	return trustedProxies
+}
+
+// 跳转使用的https端口，未配置redirect_port时取https监听端口
+func httpsRedirectPort() string {
+	if port := lib.GetStringConf("proxy.https.redirect_port"); port != "" {
+		return port
+	}
+	_, port, _ := net.SplitHostPort(lib.GetStringConf("proxy.https.addr"))
+	return port
+}
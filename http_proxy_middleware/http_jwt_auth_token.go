@@ -6,6 +6,7 @@ import (
 	"FGateWay/public"
 	"github.com/gin-gonic/gin"
 	"github.com/pkg/errors"
+	"net/http"
 	"strings"
 )
 
@@ -18,6 +19,16 @@ func HTTPJwtAuthTokenMiddleware() gin.HandlerFunc {
 			return
 		}
 		serviceDetail := serverInterface.(*dao.ServiceDetail)
+		//已开启外部OIDC校验的服务由HTTPOidcAuthMiddleware处理
+		if serviceDetail.OidcAuth != nil && serviceDetail.OidcAuth.OpenOidc == 1 {
+			c.Next()
+			return
+		}
+		//已通过客户端证书匹配到租户
+		if _, ok := c.Get("app"); ok {
+			c.Next()
+			return
+		}
 
 		token := strings.ReplaceAll(c.GetHeader("Authorization"), "Bearer ", "")
 		//fmt.Println("token",token)
@@ -29,6 +40,24 @@ func HTTPJwtAuthTokenMiddleware() gin.HandlerFunc {
 				c.Abort()
 				return
 			}
+			//吊销状态查询失败时默认拒绝，配置proxy.jwt.revoke_fail_open后放行
+			revoked, err := public.IsTokenRevoked(claims)
+			if err != nil {
+				public.ComLogWarning(c, "_com_token_revoke_failure", map[string]interface{}{
+					"issuer": claims.Issuer,
+					"err":    err.Error(),
+				})
+				if !public.TokenRevokeFailOpen() {
+					middleware.ResponseErrorStatus(c, http.StatusServiceUnavailable, 2009, errors.New("token revoke check unavailable"))
+					c.Abort()
+					return
+				}
+			}
+			if revoked {
+				middleware.ResponseError(c, 2004, errors.New("token has been revoked"))
+				c.Abort()
+				return
+			}
 			//fmt.Println("claims.Issuer",claims.Issuer)
 			appList := dao.AppManagerHandler.GetAppList()
 			for _, appInfo := range appList {
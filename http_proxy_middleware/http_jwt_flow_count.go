@@ -4,9 +4,8 @@ import (
 	"FGateWay/dao"
 	"FGateWay/middleware"
 	"FGateWay/public"
-	"fmt"
 	"github.com/gin-gonic/gin"
-	"github.com/pkg/errors"
+	"time"
 )
 
 func HTTPJwtFlowCountMiddleware() gin.HandlerFunc {
@@ -24,11 +23,10 @@ func HTTPJwtFlowCountMiddleware() gin.HandlerFunc {
 			return
 		}
 		appCounter.Increase()
-		if appInfo.Qpd > 0 && appCounter.TotalCount > appInfo.Qpd {
-			middleware.ResponseError(c, 2003, errors.New(fmt.Sprintf("租户日请求量限流 limit:%v current:%v", appInfo.Qpd, appCounter.TotalCount)))
-			c.Abort()
-			return
-		}
+		start := time.Now()
+		defer func() {
+			appCounter.Record(getResponseStatus(c), time.Since(start), c.GetBool("upstream_error"))
+		}()
 		c.Next()
 	}
 }
@@ -27,6 +27,7 @@ func HTTPJwtFlowLimitMiddleware() gin.HandlerFunc {
 				return
 			}
 			if !clientLimiter.Allow() {
+				flowLimitRejected(c, public.FlowLimitTypeApp)
 				middleware.ResponseError(c, 5002, errors.New(fmt.Sprintf("%v flow limit %v", c.ClientIP(), appInfo.Qps)))
 				c.Abort()
 				return
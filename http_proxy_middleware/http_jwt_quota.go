@@ -0,0 +1,60 @@
+package http_proxy_middleware
+
+import (
+	"FGateWay/dao"
+	"FGateWay/middleware"
+	"FGateWay/public"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	"strconv"
+)
+
+// HTTPJwtQuotaMiddleware 扣减租户日/月配额，放在所有会拒绝请求的中间件之后，被拒绝的请求不消耗配额
+func HTTPJwtQuotaMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		appInterface, ok := c.Get("app")
+		if !ok {
+			c.Next()
+			return
+		}
+		appInfo := appInterface.(*dao.App)
+		if appInfo.Qpd <= 0 && appInfo.MonthQuota <= 0 {
+			c.Next()
+			return
+		}
+
+		//在redis中原子地扣减配额，redis不可用时放行
+		allowed, day, month, err := public.ConsumeAppQuota(appInfo.AppID, appInfo.Qpd, appInfo.MonthQuota)
+		if err != nil {
+			public.ComLogWarning(c, "_com_redis_failure", map[string]interface{}{
+				"msg":    "consume app quota failed",
+				"app_id": appInfo.AppID,
+				"err":    err.Error(),
+			})
+			c.Next()
+			return
+		}
+
+		//取剩余量更少的配额作为响应头
+		quota := day
+		if appInfo.Qpd <= 0 || (appInfo.MonthQuota > 0 && month.Remaining < day.Remaining) {
+			quota = month
+		}
+		c.Header("X-RateLimit-Limit", strconv.FormatInt(quota.Limit, 10))
+		c.Header("X-RateLimit-Remaining", strconv.FormatInt(quota.Remaining, 10))
+		c.Header("X-RateLimit-Reset", strconv.FormatInt(quota.ResetAt, 10))
+
+		if !allowed {
+			flowLimitRejected(c, public.FlowLimitTypeQuota)
+			if appInfo.Qpd > 0 && day.Used >= appInfo.Qpd {
+				middleware.ResponseError(c, 2003, errors.New(fmt.Sprintf("租户日请求量限流 limit:%v current:%v", appInfo.Qpd, day.Used)))
+			} else {
+				middleware.ResponseError(c, 2003, errors.New(fmt.Sprintf("租户月请求量限流 limit:%v current:%v", appInfo.MonthQuota, month.Used)))
+			}
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
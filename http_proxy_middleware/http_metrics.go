@@ -0,0 +1,64 @@
+package http_proxy_middleware
+
+import (
+	"FGateWay/dao"
+	"FGateWay/public"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"time"
+)
+
+// 上报prometheus请求指标，需放在接入方式匹配之后
+func HTTPMetricsMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		serviceName := ""
+		if serviceInterface, ok := c.Get("service"); ok {
+			serviceName = serviceInterface.(*dao.ServiceDetail).Info.ServiceName
+		}
+		appID := ""
+		if appInterface, ok := c.Get("app"); ok {
+			appID = appInterface.(*dao.App).AppID
+		}
+		upstream := c.GetString("upstream")
+		codeClass := public.GetStatusClass(getResponseStatus(c))
+
+		public.MetricsRequestTotal.WithLabelValues(serviceName, appID, codeClass, upstream).Inc()
+		public.MetricsRequestDuration.WithLabelValues(serviceName, appID, codeClass, upstream).Observe(time.Since(start).Seconds())
+		size := c.Writer.Size()
+		if size < 0 {
+			size = 0
+		}
+		public.MetricsResponseSize.WithLabelValues(serviceName, appID, codeClass, upstream).Observe(float64(size))
+	}
+}
+
+// 网关自身的错误响应固定为200，这里按实际含义折算状态码：
+// 下游不可用视为502，被网关中间件拒绝视为400，显式指定的错误状态码(413等)保持不变
+func getResponseStatus(c *gin.Context) int {
+	if c.GetBool("upstream_error") {
+		return http.StatusBadGateway
+	}
+	if c.Writer.Status() >= http.StatusBadRequest {
+		return c.Writer.Status()
+	}
+	if _, ok := c.Get("upstream"); !ok && len(c.Errors) > 0 {
+		return http.StatusBadRequest
+	}
+	return c.Writer.Status()
+}
+
+// 限流拒绝计数
+func flowLimitRejected(c *gin.Context, limitType string) {
+	serviceName := ""
+	if serviceInterface, ok := c.Get("service"); ok {
+		serviceName = serviceInterface.(*dao.ServiceDetail).Info.ServiceName
+	}
+	appID := ""
+	if appInterface, ok := c.Get("app"); ok {
+		appID = appInterface.(*dao.App).AppID
+	}
+	public.MetricsFlowLimitRejected.WithLabelValues(serviceName, appID, limitType).Inc()
+}
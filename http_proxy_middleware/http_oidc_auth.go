@@ -0,0 +1,69 @@
+package http_proxy_middleware
+
+import (
+	"FGateWay/dao"
+	"FGateWay/middleware"
+	"FGateWay/public"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	"strings"
+)
+
+// 外部身份提供方(OIDC)签发的token校验
+func HTTPOidcAuthMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		serverInterface, ok := c.Get("service")
+		if !ok {
+			middleware.ResponseError(c, 2001, errors.New("service not found"))
+			c.Abort()
+			return
+		}
+		serviceDetail := serverInterface.(*dao.ServiceDetail)
+		oidcAuth := serviceDetail.OidcAuth
+		if oidcAuth == nil || oidcAuth.OpenOidc != 1 {
+			c.Next()
+			return
+		}
+
+		token := strings.ReplaceAll(c.GetHeader("Authorization"), "Bearer ", "")
+		if token == "" {
+			middleware.ResponseError(c, 2004, errors.New("oidc token not found"))
+			c.Abort()
+			return
+		}
+		claims, err := public.OidcDecode(token, oidcAuth.GetOidcConf())
+		if err != nil {
+			middleware.ResponseError(c, 2005, err)
+			c.Abort()
+			return
+		}
+
+		appMatched := false
+		if oidcAuth.AppClaim != "" {
+			appID := public.ClaimString(claims, oidcAuth.AppClaim)
+			for _, appInfo := range dao.AppManagerHandler.GetAppList() {
+				if appID != "" && appInfo.AppID == appID {
+					c.Set("app", appInfo)
+					appMatched = true
+					break
+				}
+			}
+			if serviceDetail.AccessControl.OpenAuth == 1 && !appMatched {
+				middleware.ResponseError(c, 2006, errors.New(fmt.Sprintf("claim %s not match valid app", oidcAuth.AppClaim)))
+				c.Abort()
+				return
+			}
+		}
+
+		//先删除再设置，防止客户端伪造
+		for claim, header := range oidcAuth.GetClaimHeaders() {
+			c.Request.Header.Del(header)
+			if value := public.ClaimString(claims, claim); value != "" {
+				c.Request.Header.Set(header, value)
+			}
+		}
+		c.Set("oidc_claims", claims)
+		c.Next()
+	}
+}
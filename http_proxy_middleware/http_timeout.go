@@ -0,0 +1,47 @@
+package http_proxy_middleware
+
+import (
+	"FGateWay/dao"
+	"FGateWay/middleware"
+	"FGateWay/public"
+	"context"
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	"time"
+)
+
+// 按服务设置请求总超时，并调整连接写超时：流式服务不受全局write_timeout限制
+func HTTPTimeoutMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		serverInterface, ok := c.Get("service")
+		if !ok {
+			middleware.ResponseError(c, 2001, errors.New("service not found"))
+			c.Abort()
+			return
+		}
+		serviceDetail := serverInterface.(*dao.ServiceDetail)
+		//websocket连接不受http超时限制
+		if c.GetBool("websocket") {
+			c.Next()
+			return
+		}
+
+		serviceTimeout := time.Duration(serviceDetail.LoadBalance.UpstreamRequestTimeout) * time.Second
+		timeout := public.GetRequestTimeout(c.Request, serviceTimeout)
+		if rc := public.GetResponseController(c.Request); rc != nil {
+			if serviceDetail.HTTPRule.NeedStream == 1 {
+				rc.SetWriteDeadline(time.Time{})
+			} else if serviceTimeout > 0 {
+				//写超时只按服务配置调整，客户端header不能放宽全局write_timeout；留出写错误响应的时间
+				rc.SetWriteDeadline(time.Now().Add(serviceTimeout + time.Second))
+			}
+		}
+
+		if timeout > 0 {
+			ctx, cancel := context.WithTimeout(c.Request.Context(), timeout)
+			defer cancel()
+			c.Request = c.Request.WithContext(ctx)
+		}
+		c.Next()
+	}
+}
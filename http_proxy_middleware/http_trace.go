@@ -0,0 +1,51 @@
+package http_proxy_middleware
+
+import (
+	"FGateWay/dao"
+	"FGateWay/public"
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// 为每个代理请求创建server span，上游的traceparent作为父span
+func HTTPTraceMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := otel.GetTextMapPropagator().Extract(c.Request.Context(), propagation.HeaderCarrier(c.Request.Header))
+		ctx, span := public.Tracer().Start(ctx, "proxy "+c.Request.Method,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				semconv.HTTPMethod(c.Request.Method),
+				semconv.HTTPTarget(c.Request.URL.RequestURI()),
+				semconv.ClientAddress(c.ClientIP()),
+				semconv.NetHostName(c.Request.Host),
+			))
+		defer span.End()
+		c.Request = c.Request.WithContext(ctx)
+
+		c.Next()
+
+		if serviceInterface, ok := c.Get("service"); ok {
+			serviceName := serviceInterface.(*dao.ServiceDetail).Info.ServiceName
+			span.SetName("proxy " + serviceName)
+			span.SetAttributes(public.TraceAttrService.String(serviceName))
+		}
+		if appInterface, ok := c.Get("app"); ok {
+			span.SetAttributes(public.TraceAttrApp.String(appInterface.(*dao.App).AppID))
+		}
+		if upstream := c.GetString("upstream"); upstream != "" {
+			span.SetAttributes(public.TraceAttrUpstream.String(upstream))
+		}
+		status := getResponseStatus(c)
+		span.SetAttributes(semconv.HTTPStatusCode(status))
+		if status >= 500 {
+			span.SetStatus(codes.Error, "")
+		}
+		if len(c.Errors) > 0 {
+			span.RecordError(c.Errors.Last())
+		}
+	}
+}
@@ -3,10 +3,9 @@ package http_proxy_middleware
 import (
 	"FGateWay/dao"
 	"FGateWay/middleware"
+	"FGateWay/reverse_proxy"
 	"github.com/gin-gonic/gin"
 	"github.com/pkg/errors"
-	"regexp"
-	"strings"
 )
 
 // 匹配接入方式 基于请求信息
@@ -19,17 +18,17 @@ func HTTPUrlRewriteMiddleware() gin.HandlerFunc {
 			return
 		}
 		serviceDetail := serverInterface.(*dao.ServiceDetail)
-		for _, item := range strings.Split(serviceDetail.HTTPRule.UrlRewrite, ",") {
-			items := strings.Split(item, " ")
-			if len(items) != 2 {
+		lookup := reverse_proxy.RuleVars(c)
+		for _, rule := range serviceDetail.HTTPRule.GetUrlRewriteRules() {
+			groups, ok := rule.Apply(c.Request.URL, lookup)
+			if !ok {
 				continue
 			}
-			regexp, err := regexp.Compile(items[0])
-			if err != nil {
-				continue
+			//最后一条命中规则的分组供header规则以${1}引用
+			c.Set("rewrite_groups", groups)
+			if rule.Last {
+				break
 			}
-			replacePath := regexp.ReplaceAll([]byte(c.Request.URL.Path), []byte(items[1]))
-			c.Request.URL.Path = string(replacePath)
 		}
 		c.Next()
 	}
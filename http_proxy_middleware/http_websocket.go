@@ -0,0 +1,49 @@
+package http_proxy_middleware
+
+import (
+	"FGateWay/dao"
+	"FGateWay/middleware"
+	"FGateWay/public"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	"net/http"
+	"time"
+)
+
+// websocket升级请求：未开启need_websocket的服务直接拒绝，按服务限制连接数，并取消连接的读写超时
+func HTTPWebsocketMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !public.IsWebsocketRequest(c.Request) {
+			c.Next()
+			return
+		}
+		serverInterface, ok := c.Get("service")
+		if !ok {
+			middleware.ResponseError(c, 2001, errors.New("service not found"))
+			c.Abort()
+			return
+		}
+		serviceDetail := serverInterface.(*dao.ServiceDetail)
+		if serviceDetail.HTTPRule.NeedWebsocket != 1 {
+			middleware.ResponseErrorStatus(c, http.StatusBadRequest, 6005, errors.New("websocket not enabled"))
+			return
+		}
+
+		serviceName := serviceDetail.Info.ServiceName
+		if !public.WebsocketConnHandler.Acquire(serviceName, serviceDetail.HTTPRule.WebsocketMaxConn) {
+			flowLimitRejected(c, public.FlowLimitTypeWsConn)
+			middleware.ResponseErrorStatus(c, http.StatusServiceUnavailable, 6006, errors.New(fmt.Sprintf("websocket connection limit %v", serviceDetail.HTTPRule.WebsocketMaxConn)))
+			return
+		}
+		defer public.WebsocketConnHandler.Release(serviceName)
+
+		//升级后的连接由ReverseProxy接管，需先清除server的读写超时
+		if rc := public.GetResponseController(c.Request); rc != nil {
+			rc.SetReadDeadline(time.Time{})
+			rc.SetWriteDeadline(time.Time{})
+		}
+		c.Set("websocket", true)
+		c.Next()
+	}
+}
@@ -0,0 +1,33 @@
+package http_proxy_router
+
+import (
+	"FGateWay/golang_common/lib"
+	"FGateWay/public"
+	"log"
+)
+
+// AccessLogInit 按 proxy.access_log 配置初始化访问日志
+func AccessLogInit() {
+	if !lib.GetBoolConf("proxy.access_log.on") {
+		return
+	}
+	accessLogger, err := public.NewAccessLogger(&public.AccessLogConf{
+		Format:        lib.GetStringConf("proxy.access_log.format"),
+		Fields:        lib.GetStringSliceConf("proxy.access_log.fields"),
+		LogPath:       lib.GetStringConf("proxy.access_log.log_path"),
+		RotateLogPath: lib.GetStringConf("proxy.access_log.rotate_log_path"),
+		Console:       lib.GetBoolConf("proxy.access_log.console"),
+	})
+	if err != nil {
+		log.Fatalf(" [ERROR] access_log_init err:%v\n", err)
+	}
+	public.AccessLogHandler = accessLogger
+	log.Printf(" [INFO] access_log_init %s\n", lib.GetStringConf("proxy.access_log.log_path"))
+}
+
+// AccessLogStop 退出前刷新访问日志
+func AccessLogStop() {
+	if public.AccessLogHandler != nil {
+		public.AccessLogHandler.Close()
+	}
+}
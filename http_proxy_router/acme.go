@@ -0,0 +1,93 @@
+package http_proxy_router
+
+import (
+	"FGateWay/dao"
+	"FGateWay/golang_common/lib"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"time"
+)
+
+// AcmeManager 为空表示未开启自动签发
+var AcmeManager *autocert.Manager
+
+// AcmeInit 按 proxy.acme 配置初始化自动签发，需在http/https服务启动前调用
+func AcmeInit() {
+	if !lib.GetBoolConf("proxy.acme.on") {
+		return
+	}
+	client := &acme.Client{DirectoryURL: lib.GetStringConf("proxy.acme.directory_url")}
+	if client.DirectoryURL == "" {
+		client.DirectoryURL = acme.LetsEncryptURL
+	}
+	//测试环境(如Pebble)的ACME服务使用自签CA
+	if caFile := lib.GetStringConf("proxy.acme.ca_file"); caFile != "" {
+		httpClient, err := acmeHTTPClient(caFile)
+		if err != nil {
+			log.Fatalf(" [ERROR] acme_init err:%v\n", err)
+		}
+		client.HTTPClient = httpClient
+	}
+	renewBefore := lib.GetIntConf("proxy.acme.renew_before")
+	if renewBefore <= 0 {
+		renewBefore = 30
+	}
+	AcmeManager = &autocert.Manager{
+		Prompt:      autocert.AcceptTOS,
+		Cache:       dao.NewAcmeCache(time.Duration(renewBefore) * 24 * time.Hour),
+		HostPolicy:  dao.AcmeHostPolicy,
+		RenewBefore: time.Duration(renewBefore) * 24 * time.Hour,
+		Email:       lib.GetStringConf("proxy.acme.email"),
+		Client:      client,
+	}
+	log.Printf(" [INFO] acme_init directory:%s\n", client.DirectoryURL)
+}
+
+func acmeHTTPClient(caFile string) (*http.Client, error) {
+	caPem, err := ioutil.ReadFile(caFile)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(caPem) {
+		return nil, errors.New("no valid ca in " + caFile)
+	}
+	return &http.Client{
+		Timeout:   30 * time.Second,
+		Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}},
+	}, nil
+}
+
+// acmeHTTPHandler 在http监听上响应HTTP-01校验，其他请求交给网关
+func acmeHTTPHandler(h http.Handler) http.Handler {
+	if AcmeManager == nil {
+		return h
+	}
+	return AcmeManager.HTTPHandler(h)
+}
+
+// acmeTLSConfig 为https监听开启TLS-ALPN-01，允许签发的域名在没有上传证书时使用自动签发的证书
+func acmeTLSConfig(tlsConf *tls.Config) {
+	if AcmeManager == nil {
+		return
+	}
+	tlsConf.NextProtos = append(tlsConf.NextProtos, acme.ALPNProto)
+	getCertificate := tlsConf.GetCertificate
+	tlsConf.GetCertificate = func(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+		if isAcmeChallenge(hello) ||
+			(dao.AcmeHostAllowed(hello.ServerName) && !dao.CertManagerHandler.HasUploadCert(hello.ServerName)) {
+			return AcmeManager.GetCertificate(hello)
+		}
+		return getCertificate(hello)
+	}
+}
+
+func isAcmeChallenge(hello *tls.ClientHelloInfo) bool {
+	return len(hello.SupportedProtos) == 1 && hello.SupportedProtos[0] == acme.ALPNProto
+}
@@ -0,0 +1,113 @@
+package http_proxy_router
+
+import (
+	"FGateWay/dao"
+	"FGateWay/golang_common/lib"
+	"FGateWay/public"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"github.com/spf13/viper"
+	"golang.org/x/crypto/acme/autocert"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// ca_file配置的自签CA用于访问Pebble等测试ACME服务
+func TestAcmeHTTPClient(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	caPem := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+	if err := ioutil.WriteFile(caFile, caPem, 0600); err != nil {
+		t.Fatal(err)
+	}
+	client, err := acmeHTTPClient(caFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := client.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if _, err := http.Get(srv.URL); err == nil {
+		t.Fatal("default client should not trust the test ca")
+	}
+
+	invalid := filepath.Join(t.TempDir(), "invalid.pem")
+	ioutil.WriteFile(invalid, []byte("invalid"), 0600)
+	if _, err := acmeHTTPClient(invalid); err == nil {
+		t.Fatal("expect error for invalid ca file")
+	}
+}
+
+// TestAcmePebble 使用本地Pebble签发证书，未配置时跳过：
+// PEBBLE_VA_ALWAYS_VALID=1 pebble -config test/config/pebble-config.json
+// ACME_PEBBLE_DIR=https://127.0.0.1:14000/dir ACME_PEBBLE_CA=test/certs/pebble.minica.pem go test ./http_proxy_router -run Pebble
+// 未设置PEBBLE_VA_ALWAYS_VALID时，ACME_PEBBLE_HTTP_ADDR配置为Pebble的httpPort地址，由本测试响应HTTP-01校验
+func TestAcmePebble(t *testing.T) {
+	directory, caFile := os.Getenv("ACME_PEBBLE_DIR"), os.Getenv("ACME_PEBBLE_CA")
+	if directory == "" || caFile == "" {
+		t.Skip("ACME_PEBBLE_DIR or ACME_PEBBLE_CA not set")
+	}
+	conf := viper.New()
+	conf.Set("acme.on", true)
+	conf.Set("acme.directory_url", directory)
+	conf.Set("acme.ca_file", caFile)
+	conf.Set("acme.email", "admin@example.com")
+	lib.ViperConfMap = map[string]*viper.Viper{"proxy": conf}
+	defer func() { lib.ViperConfMap = nil }()
+
+	domain := "pebble.example.com"
+	services := dao.ServiceManagerHandler.ServiceSlice
+	dao.ServiceManagerHandler.ServiceSlice = []*dao.ServiceDetail{{
+		Info:     &dao.ServiceInfo{LoadType: public.LoadTypeHTTP, ServiceName: "pebble_service"},
+		HTTPRule: &dao.HttpRule{RuleType: public.HTTPRuleTypeDomain, Rule: domain, NeedHttps: 1},
+	}}
+	defer func() {
+		dao.ServiceManagerHandler.ServiceSlice = services
+		AcmeManager = nil
+	}()
+
+	AcmeInit()
+	if AcmeManager == nil {
+		t.Fatal("acme manager not initialized")
+	}
+	//证书库与redis的读写由dao包的AcmeCache测试覆盖，这里使用本地目录
+	AcmeManager.Cache = autocert.DirCache(t.TempDir())
+	if addr := os.Getenv("ACME_PEBBLE_HTTP_ADDR"); addr != "" {
+		srv := &http.Server{Addr: addr, Handler: acmeHTTPHandler(http.NotFoundHandler())}
+		go srv.ListenAndServe()
+		defer srv.Close()
+	}
+
+	errFallback := errors.New("fallback")
+	tlsConf := &tls.Config{GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+		return nil, errFallback
+	}}
+	acmeTLSConfig(tlsConf)
+	cert, err := tlsConf.GetCertificate(&tls.ClientHelloInfo{ServerName: domain})
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := leaf.VerifyHostname(domain); err != nil {
+		t.Fatal(err)
+	}
+	//未开启https的域名不签发
+	if _, err := tlsConf.GetCertificate(&tls.ClientHelloInfo{ServerName: "other.example.com"}); err != errFallback {
+		t.Fatalf("expect fallback, got %v", err)
+	}
+}
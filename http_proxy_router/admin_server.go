@@ -0,0 +1,66 @@
+package http_proxy_router
+
+import (
+	"FGateWay/dao"
+	"FGateWay/golang_common/lib"
+	"FGateWay/public"
+	"context"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"log"
+	"net"
+	"net/http"
+	"time"
+)
+
+var AdminSrvHandler *http.Server
+
+// AdminServerRun 独立的管理端口，提供 /metrics，未配置 proxy.admin.addr 时不启动
+func AdminServerRun() {
+	addr := lib.GetStringConf("proxy.admin.addr")
+	if addr == "" {
+		return
+	}
+	prometheus.MustRegister(public.NewUpstreamHealthCollector(dao.LoadBalancerHandler.GetUpstreamHealth))
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	mux.HandleFunc("/ping", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("pong"))
+	})
+	AdminSrvHandler = &http.Server{
+		Addr:         addr,
+		Handler:      mux,
+		ReadTimeout:  10 * time.Second,
+		WriteTimeout: 10 * time.Second,
+	}
+	log.Printf(" [INFO] admin_server_run %s\n", addr)
+	if err := AdminSrvHandler.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		log.Fatalf(" [ERROR] admin_server_run %s err:%v\n", addr, err)
+	}
+}
+
+func AdminServerStop() {
+	if AdminSrvHandler == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := AdminSrvHandler.Shutdown(ctx); err != nil {
+		log.Printf(" [ERROR] admin_server_stop err:%v\n", err)
+	}
+	log.Printf(" [INFO] admin_server_stop %v stopped\n", lib.GetStringConf("proxy.admin.addr"))
+}
+
+// 统计代理端口当前的客户端连接数
+func connStateMetrics(listener string) func(net.Conn, http.ConnState) {
+	gauge := public.MetricsActiveConnections.WithLabelValues(listener)
+	return func(conn net.Conn, state http.ConnState) {
+		switch state {
+		case http.StateNew:
+			gauge.Inc()
+		case http.StateHijacked, http.StateClosed:
+			gauge.Dec()
+		}
+	}
+}
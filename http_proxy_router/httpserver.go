@@ -1,10 +1,16 @@
 package http_proxy_router
 
 import (
+	"FGateWay/dao"
 	"FGateWay/golang_common/lib"
 	"FGateWay/middleware"
+	"FGateWay/public"
 	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
 	"github.com/gin-gonic/gin"
+	"io/ioutil"
 	"log"
 	"net/http"
 	"time"
@@ -21,10 +27,11 @@ func HttpServerRun() {
 		middleware.RequestLog())
 	HttpSrvHandler = &http.Server{
 		Addr:           lib.GetStringConf("proxy.http.addr"),
-		Handler:        r,
+		Handler:        acmeHTTPHandler(public.ResponseControllerHandler(r)),
 		ReadTimeout:    time.Duration(lib.GetIntConf("proxy.http.read_timeout")) * time.Second,
 		WriteTimeout:   time.Duration(lib.GetIntConf("proxy.http.write_timeout")) * time.Second,
 		MaxHeaderBytes: 1 << uint(lib.GetIntConf("proxy.http.max_header_bytes")),
+		ConnState:      connStateMetrics("http"),
 	}
 	log.Printf(" [INFO] HttpServerRun %s\n", lib.GetStringConf("proxy.http.addr"))
 	if err := HttpSrvHandler.ListenAndServe(); err != nil {
@@ -38,18 +45,73 @@ func HttpsServerRun() {
 		middleware.RequestLog())
 	HttpsSrvHandler = &http.Server{
 		Addr:           lib.GetStringConf("proxy.https.addr"),
-		Handler:        r,
+		Handler:        public.ResponseControllerHandler(r),
 		ReadTimeout:    time.Duration(lib.GetIntConf("proxy.https.read_timeout")) * time.Second,
 		WriteTimeout:   time.Duration(lib.GetIntConf("proxy.https.write_timeout")) * time.Second,
 		MaxHeaderBytes: 1 << uint(lib.GetIntConf("proxy.https.max_header_bytes")),
+		ConnState:      connStateMetrics("https"),
 	}
+	tlsConf, err := HttpsTLSConfig()
+	if err != nil {
+		log.Fatalf(" [ERROR] https_proxy_run %s err:%v\n", lib.GetStringConf("proxy.https.addr"), err)
+	}
+	//证书库按SNI选证书，未匹配时回落到配置文件中的证书
+	if err := dao.CertManagerHandler.Reload(); err != nil {
+		log.Printf(" [ERROR] load certificate err:%v\n", err)
+	}
+	reloadInterval := lib.GetIntConf("proxy.https.cert_reload_interval")
+	if reloadInterval <= 0 {
+		reloadInterval = 30
+	}
+	go dao.CertManagerHandler.WatchReload(time.Duration(reloadInterval) * time.Second)
+	tlsConf.GetCertificate = dao.CertManagerHandler.GetCertificate
+	acmeTLSConfig(tlsConf)
+	HttpsSrvHandler.TLSConfig = tlsConf
 	log.Printf(" [INFO] https_proxy_run %s\n", lib.GetStringConf("proxy.https.addr"))
 
-	if err := HttpsSrvHandler.ListenAndServeTLS("./cert_file/server.crt", "./cert_file/server.key"); err != nil && err != http.ErrServerClosed {
+	certFile := lib.GetStringConf("proxy.https.cert_file")
+	if certFile == "" {
+		certFile = "./cert_file/server.crt"
+	}
+	keyFile := lib.GetStringConf("proxy.https.key_file")
+	if keyFile == "" {
+		keyFile = "./cert_file/server.key"
+	}
+	if err := HttpsSrvHandler.ListenAndServeTLS(certFile, keyFile); err != nil && err != http.ErrServerClosed {
 		log.Fatalf(" [ERROR] https_proxy_run %s err:%v\n", lib.GetStringConf("proxy.https.addr"), err)
 	}
 }
 
+// HttpsTLSConfig 根据配置设置客户端证书校验方式与CA
+func HttpsTLSConfig() (*tls.Config, error) {
+	tlsConf := &tls.Config{}
+	if caFile := lib.GetStringConf("proxy.https.client_ca_file"); caFile != "" {
+		caPem, err := ioutil.ReadFile(caFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caPem) {
+			return nil, errors.New("no valid ca in " + caFile)
+		}
+		tlsConf.ClientCAs = pool
+	}
+	switch lib.GetStringConf("proxy.https.client_auth") {
+	case "request":
+		tlsConf.ClientAuth = tls.RequestClientCert
+	case "verify_if_given":
+		tlsConf.ClientAuth = tls.VerifyClientCertIfGiven
+	case "require":
+		tlsConf.ClientAuth = tls.RequireAndVerifyClientCert
+	default:
+		tlsConf.ClientAuth = tls.NoClientCert
+	}
+	if tlsConf.ClientAuth >= tls.VerifyClientCertIfGiven && tlsConf.ClientCAs == nil {
+		return nil, errors.New("client_auth need client_ca_file")
+	}
+	return tlsConf, nil
+}
+
 func HttpServerStop() {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
@@ -0,0 +1,84 @@
+package http_proxy_router
+
+import (
+	"FGateWay/dao"
+	"FGateWay/golang_common/lib"
+	"FGateWay/public"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+var (
+	instanceLocker sync.Mutex
+	instanceInfo   *public.InstanceInfo
+	instanceStop   = make(chan struct{})
+	instanceDone   = make(chan struct{})
+)
+
+// InstanceRegister 在redis中注册当前代理实例并定时发送心跳
+func InstanceRegister() {
+	hostname, _ := os.Hostname()
+	startTime := time.Now()
+	info := &public.InstanceInfo{
+		InstanceID: fmt.Sprintf("%s_%d_%d", hostname, os.Getpid(), startTime.Unix()),
+		Hostname:   hostname,
+		Pid:        os.Getpid(),
+		HttpAddr:   lib.GetStringConf("proxy.http.addr"),
+		HttpsAddr:  lib.GetStringConf("proxy.https.addr"),
+		AdminAddr:  lib.GetStringConf("proxy.admin.addr"),
+		StartTime:  startTime.Unix(),
+	}
+	instanceLocker.Lock()
+	select {
+	case <-instanceStop:
+		//已在注销，不再注册
+		instanceLocker.Unlock()
+		return
+	default:
+	}
+	instanceInfo = info
+	instanceLocker.Unlock()
+	defer close(instanceDone)
+
+	ticker := time.NewTicker(public.InstanceHeartbeatInterval)
+	defer ticker.Stop()
+	for {
+		info.ServiceNum = len(dao.ServiceManagerHandler.GetServiceList())
+		info.ServiceRevision = dao.ServiceManagerHandler.Revision
+		info.AppNum = len(dao.AppManagerHandler.GetAppList())
+		info.AppRevision = dao.AppManagerHandler.Revision
+		if err := public.SaveInstance(info); err != nil {
+			log.Printf(" [ERROR] instance heartbeat err:%v\n", err)
+		}
+		select {
+		case <-ticker.C:
+		case <-instanceStop:
+			return
+		}
+	}
+}
+
+// InstanceUnregister 停止心跳并等待进行中的心跳结束后注销实例，避免注销后又被心跳写回
+func InstanceUnregister() {
+	instanceLocker.Lock()
+	info := instanceInfo
+	select {
+	case <-instanceStop:
+		instanceLocker.Unlock()
+		return
+	default:
+		close(instanceStop)
+	}
+	instanceLocker.Unlock()
+	if info == nil {
+		return
+	}
+	<-instanceDone
+	if err := public.RemoveInstance(info.InstanceID); err != nil {
+		log.Printf(" [ERROR] instance unregister err:%v\n", err)
+	}
+	log.Printf(" [INFO] instance %s unregistered\n", info.InstanceID)
+}
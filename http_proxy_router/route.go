@@ -23,17 +23,32 @@ func InitRouter(middlewares ...gin.HandlerFunc) *gin.Engine {
 		controller.OAuthRegister(oauth)
 	}
 	router.Use(
+		http_proxy_middleware.HTTPAccessLogMiddleware(),
+		http_proxy_middleware.HTTPTraceMiddleware(),
 		http_proxy_middleware.HttpAccessModeMiddleware(),
+		http_proxy_middleware.HTTPHttpsMiddleware(),
+		http_proxy_middleware.HTTPCompressMiddleware(),
+		http_proxy_middleware.HTTPBodyLogMiddleware(),
+		http_proxy_middleware.HTTPMetricsMiddleware(),
+		http_proxy_middleware.HTTPCorsMiddleware(),
+		http_proxy_middleware.HTTPBodyLimitMiddleware(),
+		http_proxy_middleware.HTTPWebsocketMiddleware(),
+		http_proxy_middleware.HTTPTimeoutMiddleware(),
 		http_proxy_middleware.HTTpFlowCountMiddleware(),
 		http_proxy_middleware.HTTPFlowLimitMiddleware(),
+		http_proxy_middleware.HTTPClientCertMiddleware(),
+		http_proxy_middleware.HTTPOidcAuthMiddleware(),
 		http_proxy_middleware.HTTPJwtAuthTokenMiddleware(),
 		http_proxy_middleware.HTTPJwtFlowCountMiddleware(),
 		http_proxy_middleware.HTTPJwtFlowLimitMiddleware(),
 		http_proxy_middleware.HTTPWhiteListMiddleware(),
 		http_proxy_middleware.HTTPBlackListMiddleware(),
-		http_proxy_middleware.HTTPHeaderTransferMiddleware(),
+		http_proxy_middleware.HTTPForwardAuthMiddleware(),
+		http_proxy_middleware.HTTPJwtQuotaMiddleware(),
 		http_proxy_middleware.HTTPStripUriMiddleware(),
 		http_proxy_middleware.HTTPUrlRewriteMiddleware(),
+		http_proxy_middleware.HTTPHeaderTransferMiddleware(),
+		http_proxy_middleware.HTTPCacheMiddleware(),
 		http_proxy_middleware.HTTPReverseProxyMiddleware())
 	return router
 }
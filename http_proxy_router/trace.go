@@ -0,0 +1,39 @@
+package http_proxy_router
+
+import (
+	"FGateWay/golang_common/lib"
+	"FGateWay/public"
+	"context"
+	"log"
+	"time"
+)
+
+var traceShutdown func(context.Context) error
+
+// TraceInit 按 proxy.trace 配置初始化链路追踪
+func TraceInit() {
+	shutdown, err := public.InitTracer(&public.TraceConf{
+		Exporter:    lib.GetStringConf("proxy.trace.exporter"),
+		Endpoint:    lib.GetStringConf("proxy.trace.endpoint"),
+		Insecure:    lib.GetBoolConf("proxy.trace.insecure"),
+		SampleRatio: lib.GetFloat64Conf("proxy.trace.sample_ratio"),
+		ServiceName: lib.GetStringConf("proxy.trace.service_name"),
+	})
+	if err != nil {
+		log.Fatalf(" [ERROR] trace_init err:%v\n", err)
+	}
+	traceShutdown = shutdown
+	log.Printf(" [INFO] trace_init exporter:%s\n", lib.GetStringConf("proxy.trace.exporter"))
+}
+
+// TraceStop 退出前导出剩余的span
+func TraceStop() {
+	if traceShutdown == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := traceShutdown(ctx); err != nil {
+		log.Printf(" [ERROR] trace_stop err:%v\n", err)
+	}
+}
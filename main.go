@@ -4,8 +4,10 @@ import (
 	"FGateWay/dao"
 	"FGateWay/golang_common/lib"
 	"FGateWay/http_proxy_router"
+	"FGateWay/public"
 	"FGateWay/router"
 	"flag"
+	"log"
 	"os"
 	"os/signal"
 	"syscall"
@@ -30,6 +32,11 @@ func main() {
 	if *endpoint == "dashboard" {
 		lib.InitModule(*config)
 		defer lib.Destroy()
+		if count, err := dao.MigrateLegacyRules(); err != nil {
+			log.Printf(" [ERROR] migrate legacy http rules err:%v\n", err)
+		} else if count > 0 {
+			log.Printf(" [INFO] migrated %d legacy http rules to json\n", count)
+		}
 		router.HttpServerRun()
 
 		quit := make(chan os.Signal)
@@ -41,7 +48,16 @@ func main() {
 		lib.InitModule(*config)
 		defer lib.Destroy()
 		dao.ServiceManagerHandler.LoadOnce()
-		//dao.AppManagerHandler.LoadOnce()
+		dao.AppManagerHandler.LoadOnce()
+		if err := dao.LoadBalancerHandler.LoadOnce(); err != nil {
+			log.Printf(" [ERROR] load balancer init err:%v\n", err)
+		}
+		http_proxy_router.TraceInit()
+		http_proxy_router.AccessLogInit()
+		http_proxy_router.AcmeInit()
+		go dao.LoadBalancerHandler.ReportHealth()
+		go http_proxy_router.InstanceRegister()
+		go public.WatchCachePurge()
 
 		go func() {
 			http_proxy_router.HttpServerRun()
@@ -49,13 +65,20 @@ func main() {
 		go func() {
 			http_proxy_router.HttpsServerRun()
 		}()
+		go func() {
+			http_proxy_router.AdminServerRun()
+		}()
 
 		quit := make(chan os.Signal)
 		signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 		<-quit
 
+		http_proxy_router.InstanceUnregister()
 		http_proxy_router.HttpServerStop()
 		http_proxy_router.HttpsServerStop()
+		http_proxy_router.AdminServerStop()
+		http_proxy_router.TraceStop()
+		http_proxy_router.AccessLogStop()
 
 	}
 }
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"github.com/e421083458/golang_common/lib"
 	"github.com/gin-gonic/gin"
+	"net/http"
 	"runtime/debug"
 )
 
@@ -14,6 +15,10 @@ func RecoveryMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		defer func() {
 			if err := recover(); err != nil {
+				//反向代理转发中途中断(如响应超限、客户端断开)，交给http server关闭连接
+				if err == http.ErrAbortHandler {
+					panic(err)
+				}
 				//先做一下日志记录
 				fmt.Println(string(debug.Stack()))
 				public.ComLogNotice(c, "_com_panic", map[string]interface{}{
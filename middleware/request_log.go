@@ -2,10 +2,8 @@ package middleware
 
 import (
 	"FGateWay/public"
-	"bytes"
 	"github.com/e421083458/golang_common/lib"
 	"github.com/gin-gonic/gin"
-	"io/ioutil"
 	"time"
 )
 
@@ -22,14 +20,20 @@ func RequestInLog(c *gin.Context) {
 	c.Set("startExecTime", time.Now())
 	c.Set("trace", traceContext)
 
-	bodyBytes, _ := ioutil.ReadAll(c.Request.Body)
-	c.Request.Body = ioutil.NopCloser(bytes.NewBuffer(bodyBytes)) // Write body back
+	//不再预读body，下游读取时按配置旁路记录，body在请求输出日志中打印
+	conf := public.GetBodyCaptureConf()
+	if c.Request.Body != nil {
+		capture := public.NewBodyCapture(c.Request.Body, conf.MaxBodySize)
+		capture.Enabled = conf.CaptureBody && public.BodyContentTypeAllowed(c.GetHeader("Content-Type"), conf.ContentTypes)
+		c.Request.Body = capture
+		c.Set("body_capture", capture)
+	}
 
 	lib.Log.TagInfo(traceContext, "_com_request_in", map[string]interface{}{
 		"uri":    c.Request.RequestURI,
 		"method": c.Request.Method,
 		"args":   c.Request.PostForm,
-		"body":   string(bodyBytes),
+		"header": public.RedactHeader(c.Request.Header, conf.RedactHeaders),
 		"from":   c.ClientIP(),
 	})
 }
@@ -42,14 +46,22 @@ func RequestOutLog(c *gin.Context) {
 	st, _ := c.Get("startExecTime")
 
 	startExecTime, _ := st.(time.Time)
-	public.ComLogNotice(c, "_com_request_out", map[string]interface{}{
+	fields := map[string]interface{}{
 		"uri":       c.Request.RequestURI,
 		"method":    c.Request.Method,
 		"args":      c.Request.PostForm,
 		"from":      c.ClientIP(),
 		"response":  response,
 		"proc_time": endExecTime.Sub(startExecTime).Seconds(),
-	})
+	}
+	if captureInterface, ok := c.Get("body_capture"); ok {
+		if capture := captureInterface.(*public.BodyCapture); capture.Enabled {
+			conf := public.GetBodyCaptureConf()
+			fields["body"] = public.RedactBody(c.GetHeader("Content-Type"), capture.Bytes(), conf.RedactFields)
+			fields["body_truncated"] = capture.Truncated()
+		}
+	}
+	public.ComLogNotice(c, "_com_request_out", fields)
 }
 
 func RequestLog() gin.HandlerFunc {
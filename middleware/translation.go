@@ -59,27 +59,12 @@ func TranslationMiddleware() gin.HandlerFunc {
 				matched, _ := regexp.Match(`^\S+$`, []byte(fl.Field().String()))
 				return matched
 			})
+			//支持JSON规则与旧的空格、逗号间隔格式
 			val.RegisterValidation("valid_url_rewrite", func(fl validator.FieldLevel) bool {
-				if fl.Field().String() == "" {
-					return true
-				}
-				for _, ms := range strings.Split(fl.Field().String(), ",") {
-					if len(strings.Split(ms, " ")) != 2 {
-						return false
-					}
-				}
-				return true
+				return public.ValidUrlRewriteRules(fl.Field().String()) == nil
 			})
 			val.RegisterValidation("valid_header_transfor", func(fl validator.FieldLevel) bool {
-				if fl.Field().String() == "" {
-					return true
-				}
-				for _, ms := range strings.Split(fl.Field().String(), ",") {
-					if len(strings.Split(ms, " ")) != 3 {
-						return false
-					}
-				}
-				return true
+				return public.ValidHeaderRules(fl.Field().String()) == nil
 			})
 			val.RegisterValidation("valid_ipportlist", func(fl validator.FieldLevel) bool {
 				for _, ms := range strings.Split(fl.Field().String(), ",") {
@@ -131,9 +116,14 @@ func TranslationMiddleware() gin.HandlerFunc {
 				return t
 			})
 			val.RegisterTranslation("valid_url_rewrite", trans, func(ut ut.Translator) error {
-				return ut.Add("valid_url_rewrite", "{0} 不符合输入格式", true)
+				return ut.Add("valid_url_rewrite", "{0} 不符合输入格式: {1}", true)
 			}, func(ut ut.Translator, fe validator.FieldError) string {
-				t, _ := ut.T("valid_url_rewrite", fe.Field())
+				//带上具体的解析或正则编译错误
+				reason := ""
+				if err := public.ValidUrlRewriteRules(fmt.Sprint(fe.Value())); err != nil {
+					reason = err.Error()
+				}
+				t, _ := ut.T("valid_url_rewrite", fe.Field(), reason)
 				return t
 			})
 			val.RegisterTranslation("valid_header_transfor", trans, func(ut ut.Translator) error {
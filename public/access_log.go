@@ -0,0 +1,200 @@
+package public
+
+import (
+	dlog "FGateWay/golang_common/log"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	AccessLogFormatJSON     = "json"
+	AccessLogFormatCombined = "combined"
+)
+
+// 默认输出的字段
+var DefaultAccessLogFields = []string{
+	"time", "trace_id", "client_ip", "method", "host", "uri", "proto", "status",
+	"service", "app", "upstream", "bytes_in", "bytes_out", "upstream_latency", "latency",
+	"referer", "user_agent",
+}
+
+// AccessLogEntry 一次代理请求的访问日志，耗时单位ms
+type AccessLogEntry struct {
+	Time            time.Time
+	TraceID         string
+	ClientIP        string
+	Method          string
+	Host            string
+	URI             string
+	Proto           string
+	Status          int
+	Service         string
+	App             string
+	Upstream        string
+	BytesIn         int64
+	BytesOut        int64
+	UpstreamLatency float64
+	Latency         float64
+	Referer         string
+	UserAgent       string
+}
+
+func (e *AccessLogEntry) field(name string) (interface{}, bool) {
+	switch name {
+	case "time":
+		return e.Time.Format(time.RFC3339Nano), true
+	case "trace_id":
+		return e.TraceID, true
+	case "client_ip":
+		return e.ClientIP, true
+	case "method":
+		return e.Method, true
+	case "host":
+		return e.Host, true
+	case "uri":
+		return e.URI, true
+	case "proto":
+		return e.Proto, true
+	case "status":
+		return e.Status, true
+	case "service":
+		return e.Service, true
+	case "app":
+		return e.App, true
+	case "upstream":
+		return e.Upstream, true
+	case "bytes_in":
+		return e.BytesIn, true
+	case "bytes_out":
+		return e.BytesOut, true
+	case "upstream_latency":
+		return e.UpstreamLatency, true
+	case "latency":
+		return e.Latency, true
+	case "referer":
+		return e.Referer, true
+	case "user_agent":
+		return e.UserAgent, true
+	}
+	return nil, false
+}
+
+// AccessLogger 访问日志，通过golang_common/log的writer异步写入
+type AccessLogger struct {
+	format string
+	fields []string
+	logger *dlog.Logger
+	locker sync.RWMutex
+	closed bool
+}
+
+var AccessLogHandler *AccessLogger
+
+// AccessLogConf 访问日志配置，Fields只对json格式生效
+type AccessLogConf struct {
+	Format        string
+	Fields        []string
+	LogPath       string
+	RotateLogPath string
+	Console       bool
+}
+
+// NewAccessLogger 根据配置创建访问日志
+func NewAccessLogger(conf *AccessLogConf) (*AccessLogger, error) {
+	if conf.Format == "" {
+		conf.Format = AccessLogFormatJSON
+	}
+	if conf.Format != AccessLogFormatJSON && conf.Format != AccessLogFormatCombined {
+		return nil, fmt.Errorf("unsupported access log format %s", conf.Format)
+	}
+	fields := conf.Fields
+	if len(fields) == 0 {
+		fields = DefaultAccessLogFields
+	}
+	entry := &AccessLogEntry{}
+	for _, name := range fields {
+		if _, ok := entry.field(name); !ok {
+			return nil, fmt.Errorf("unsupported access log field %s", name)
+		}
+	}
+
+	logger := dlog.NewPlainLogger()
+	if conf.LogPath != "" {
+		w := dlog.NewFileWriter()
+		w.SetFileName(conf.LogPath)
+		if err := w.SetPathPattern(conf.RotateLogPath); err != nil {
+			return nil, err
+		}
+		w.SetLogLevelFloor(dlog.TRACE)
+		w.SetLogLevelCeil(dlog.FATAL)
+		logger.Register(w)
+	}
+	if conf.Console {
+		logger.Register(dlog.NewConsoleWriter())
+	}
+	return &AccessLogger{format: conf.Format, fields: fields, logger: logger}, nil
+}
+
+// Log 格式化后投递到日志队列，关闭后的日志直接丢弃
+func (a *AccessLogger) Log(entry *AccessLogEntry) {
+	a.locker.RLock()
+	defer a.locker.RUnlock()
+	if a.closed {
+		return
+	}
+	a.logger.Info("%s", a.Format(entry))
+}
+
+// Close 关闭日志队列并刷盘，可重复调用
+func (a *AccessLogger) Close() {
+	a.locker.Lock()
+	defer a.locker.Unlock()
+	if a.closed {
+		return
+	}
+	a.closed = true
+	a.logger.Close()
+}
+
+func (a *AccessLogger) Format(entry *AccessLogEntry) string {
+	if a.format == AccessLogFormatCombined {
+		return formatCombined(entry)
+	}
+	buf := bytes.Buffer{}
+	buf.WriteByte('{')
+	for i, name := range a.fields {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		value, _ := entry.field(name)
+		buf.WriteString(strconv.Quote(name))
+		buf.WriteByte(':')
+		data, _ := json.Marshal(value)
+		buf.Write(data)
+	}
+	buf.WriteByte('}')
+	return buf.String()
+}
+
+// Apache combined格式
+func formatCombined(entry *AccessLogEntry) string {
+	user := "-"
+	if entry.App != "" {
+		user = entry.App
+	}
+	return fmt.Sprintf(`%s - %s [%s] "%s %s %s" %d %d %q %q`,
+		entry.ClientIP, user, entry.Time.Format("02/Jan/2006:15:04:05 -0700"),
+		entry.Method, entry.URI, entry.Proto, entry.Status, entry.BytesOut,
+		orDash(entry.Referer), orDash(entry.UserAgent))
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
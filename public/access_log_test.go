@@ -0,0 +1,50 @@
+package public
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAccessLogFormat(t *testing.T) {
+	entry := &AccessLogEntry{
+		Time:      time.Date(2026, 10, 19, 10, 0, 0, 0, time.UTC),
+		ClientIP:  "127.0.0.1",
+		Method:    "GET",
+		URI:       "/test_http_service/abc?a=1",
+		Proto:     "HTTP/1.1",
+		Status:    200,
+		Service:   "test_http_service",
+		BytesOut:  12,
+		Latency:   1.5,
+		UserAgent: "curl/8.0",
+	}
+
+	logger, err := NewAccessLogger(&AccessLogConf{Fields: []string{"status", "service", "uri", "latency"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer logger.Close()
+	if line := logger.Format(entry); line != `{"status":200,"service":"test_http_service","uri":"/test_http_service/abc?a=1","latency":1.5}` {
+		t.Fatalf("unexpected json line %s", line)
+	}
+
+	logger.format = AccessLogFormatCombined
+	if line := logger.Format(entry); line != `127.0.0.1 - - [19/Oct/2026:10:00:00 +0000] "GET /test_http_service/abc?a=1 HTTP/1.1" 200 12 "-" "curl/8.0"` {
+		t.Fatalf("unexpected combined line %s", line)
+	}
+
+	if _, err := NewAccessLogger(&AccessLogConf{Fields: []string{"unknown"}}); err == nil {
+		t.Fatal("unknown field should fail")
+	}
+}
+
+func TestAccessLogClose(t *testing.T) {
+	logger, err := NewAccessLogger(&AccessLogConf{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger.Close()
+	//关闭后的日志丢弃，重复关闭不panic
+	logger.Log(&AccessLogEntry{Time: time.Now()})
+	logger.Close()
+}
@@ -0,0 +1,254 @@
+package public
+
+import (
+	"FGateWay/golang_common/lib"
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+const (
+	BodyCaptureDefaultMaxSize = 4096
+	RedactedValue             = "***"
+)
+
+var (
+	DefaultBodyCaptureContentTypes = []string{"application/json", "application/x-www-form-urlencoded", "text/"}
+	DefaultRedactFields            = []string{"password", "passwd", "secret", "client_secret", "token", "access_token", "refresh_token"}
+	DefaultRedactHeaders           = []string{"Authorization", "Cookie", "Set-Cookie", "Proxy-Authorization"}
+)
+
+// BodyCaptureConf 请求体日志配置
+type BodyCaptureConf struct {
+	CaptureBody   bool     //未按服务开启时是否默认记录请求体
+	MaxBodySize   int      //最大记录字节数
+	ContentTypes  []string //允许记录的content-type，以/结尾时按前缀匹配
+	RedactFields  []string //需要脱敏的字段，不区分大小写
+	RedactHeaders []string //需要脱敏的header
+}
+
+var (
+	bodyCaptureConf     *BodyCaptureConf
+	bodyCaptureConfOnce sync.Once
+)
+
+// GetBodyCaptureConf 读取base.request_log配置，只加载一次
+func GetBodyCaptureConf() *BodyCaptureConf {
+	bodyCaptureConfOnce.Do(func() {
+		conf := &BodyCaptureConf{
+			CaptureBody:   lib.GetBoolConf("base.request_log.capture_body"),
+			MaxBodySize:   lib.GetIntConf("base.request_log.max_body_size"),
+			ContentTypes:  lib.GetStringSliceConf("base.request_log.content_types"),
+			RedactFields:  lib.GetStringSliceConf("base.request_log.redact_fields"),
+			RedactHeaders: lib.GetStringSliceConf("base.request_log.redact_headers"),
+		}
+		if conf.MaxBodySize <= 0 {
+			conf.MaxBodySize = BodyCaptureDefaultMaxSize
+		}
+		if len(conf.ContentTypes) == 0 {
+			conf.ContentTypes = DefaultBodyCaptureContentTypes
+		}
+		if len(conf.RedactFields) == 0 {
+			conf.RedactFields = DefaultRedactFields
+		}
+		if len(conf.RedactHeaders) == 0 {
+			conf.RedactHeaders = DefaultRedactHeaders
+		}
+		bodyCaptureConf = conf
+	})
+	return bodyCaptureConf
+}
+
+// BodyCapture 包装请求体，上游读取时旁路复制最多MaxSize字节，不会预先读取整个body
+// 上游未读完请求体就响应时，记录日志与transport读取body可能同时进行，buf由locker保护
+type BodyCapture struct {
+	io.ReadCloser
+	Enabled   bool
+	MaxSize   int
+	locker    sync.Mutex
+	truncated bool
+	buf       bytes.Buffer
+}
+
+func NewBodyCapture(body io.ReadCloser, maxSize int) *BodyCapture {
+	return &BodyCapture{ReadCloser: body, MaxSize: maxSize}
+}
+
+func (b *BodyCapture) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if n > 0 && b.Enabled {
+		b.locker.Lock()
+		remain := b.MaxSize - b.buf.Len()
+		if n > remain {
+			b.truncated = true
+			if remain > 0 {
+				b.buf.Write(p[:remain])
+			}
+		} else {
+			b.buf.Write(p[:n])
+		}
+		b.locker.Unlock()
+	}
+	return n, err
+}
+
+// Bytes 返回已记录内容的副本
+func (b *BodyCapture) Bytes() []byte {
+	b.locker.Lock()
+	defer b.locker.Unlock()
+	return append([]byte(nil), b.buf.Bytes()...)
+}
+
+// Truncated 请求体是否超过MaxSize被截断
+func (b *BodyCapture) Truncated() bool {
+	b.locker.Lock()
+	defer b.locker.Unlock()
+	return b.truncated
+}
+
+// BodyContentTypeAllowed 判断content-type是否在允许记录的列表中
+func BodyContentTypeAllowed(contentType string, allowList []string) bool {
+	if contentType == "" {
+		return false
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	for _, item := range allowList {
+		item = strings.ToLower(strings.TrimSpace(item))
+		if item == "" {
+			continue
+		}
+		if strings.HasSuffix(item, "/") {
+			if strings.HasPrefix(mediaType, item) {
+				return true
+			}
+			continue
+		}
+		if mediaType == item {
+			return true
+		}
+	}
+	return false
+}
+
+// RedactBody 按content-type对body中的敏感字段脱敏
+func RedactBody(contentType string, body []byte, fields []string) string {
+	if len(body) == 0 || len(fields) == 0 {
+		return string(body)
+	}
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch {
+	case mediaType == "application/x-www-form-urlencoded":
+		if values, err := url.ParseQuery(string(body)); err == nil {
+			redactValues(values, fields)
+			return values.Encode()
+		}
+	case mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"):
+		var data interface{}
+		if err := json.Unmarshal(body, &data); err == nil {
+			out, err := json.Marshal(redactJSON(data, fieldSet(fields)))
+			if err == nil {
+				return string(out)
+			}
+		}
+	}
+	//被截断或无法解析的body按正则兜底
+	return redactByPattern(string(body), fields)
+}
+
+// RedactHeader 复制header并将敏感header替换为***
+func RedactHeader(header http.Header, names []string) map[string]string {
+	set := fieldSet(names)
+	out := map[string]string{}
+	for key, values := range header {
+		if _, ok := set[strings.ToLower(key)]; ok {
+			out[key] = RedactedValue
+			continue
+		}
+		out[key] = strings.Join(values, ",")
+	}
+	return out
+}
+
+func fieldSet(fields []string) map[string]struct{} {
+	set := map[string]struct{}{}
+	for _, field := range fields {
+		set[strings.ToLower(strings.TrimSpace(field))] = struct{}{}
+	}
+	return set
+}
+
+func redactValues(values url.Values, fields []string) {
+	set := fieldSet(fields)
+	for key := range values {
+		if _, ok := set[strings.ToLower(key)]; ok {
+			values[key] = []string{RedactedValue}
+		}
+	}
+}
+
+func redactJSON(data interface{}, set map[string]struct{}) interface{} {
+	switch v := data.(type) {
+	case map[string]interface{}:
+		for key, item := range v {
+			if _, ok := set[strings.ToLower(key)]; ok {
+				v[key] = RedactedValue
+				continue
+			}
+			v[key] = redactJSON(item, set)
+		}
+	case []interface{}:
+		for i, item := range v {
+			v[i] = redactJSON(item, set)
+		}
+	}
+	return data
+}
+
+// redactPattern 脱敏字段对应的正则，按字段列表编译一次后复用
+type redactPattern struct {
+	jsonRe *regexp.Regexp
+	formRe *regexp.Regexp
+}
+
+var redactPatterns sync.Map
+
+func getRedactPattern(fields []string) *redactPattern {
+	key := strings.Join(fields, ",")
+	if item, ok := redactPatterns.Load(key); ok {
+		return item.(*redactPattern)
+	}
+	quoted := make([]string, 0, len(fields))
+	for _, field := range fields {
+		if field = strings.TrimSpace(field); field != "" {
+			quoted = append(quoted, regexp.QuoteMeta(field))
+		}
+	}
+	pattern := &redactPattern{}
+	if len(quoted) > 0 {
+		names := strings.Join(quoted, "|")
+		//"key": "value" 或 "key": 123
+		pattern.jsonRe = regexp.MustCompile(`(?i)("(?:` + names + `)"\s*:\s*)("(?:[^"\\]|\\.)*"?|[^,}\s]*)`)
+		//key=value
+		pattern.formRe = regexp.MustCompile(`(?i)((?:^|&)(?:` + names + `)=)[^&]*`)
+	}
+	item, _ := redactPatterns.LoadOrStore(key, pattern)
+	return item.(*redactPattern)
+}
+
+func redactByPattern(body string, fields []string) string {
+	pattern := getRedactPattern(fields)
+	if pattern.jsonRe == nil {
+		return body
+	}
+	body = pattern.jsonRe.ReplaceAllString(body, `${1}"`+RedactedValue+`"`)
+	return pattern.formRe.ReplaceAllString(body, "${1}"+RedactedValue)
+}
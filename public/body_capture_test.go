@@ -0,0 +1,98 @@
+package public
+
+import (
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestBodyCapture(t *testing.T) {
+	body := `{"user":"abc","password":"123456"}`
+	capture := NewBodyCapture(ioutil.NopCloser(strings.NewReader(body)), 10)
+	capture.Enabled = true
+	data, err := ioutil.ReadAll(capture)
+	if err != nil {
+		t.Fatal(err)
+	}
+	//上游读到完整body，日志只保留前10字节
+	if string(data) != body {
+		t.Fatalf("upstream body = %s", data)
+	}
+	if string(capture.Bytes()) != body[:10] || !capture.Truncated() {
+		t.Fatalf("captured = %s, truncated = %v", capture.Bytes(), capture.Truncated())
+	}
+}
+
+// 上游未读完body时记录日志，与transport并发读取
+func TestBodyCaptureConcurrentRead(t *testing.T) {
+	capture := NewBodyCapture(ioutil.NopCloser(strings.NewReader(strings.Repeat("a", 1<<16))), 1024)
+	capture.Enabled = true
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		buf := make([]byte, 16)
+		for {
+			if _, err := capture.Read(buf); err != nil {
+				return
+			}
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		if len(capture.Bytes()) > 1024 {
+			t.Fatal("captured more than max size")
+		}
+		capture.Truncated()
+	}
+	<-done
+	if len(capture.Bytes()) != 1024 || !capture.Truncated() {
+		t.Fatalf("captured %d, truncated = %v", len(capture.Bytes()), capture.Truncated())
+	}
+}
+
+func TestBodyContentTypeAllowed(t *testing.T) {
+	allow := []string{"application/json", "text/"}
+	cases := map[string]bool{
+		"application/json; charset=utf-8": true,
+		"text/plain":                      true,
+		"multipart/form-data; boundary=x": false,
+		"":                                false,
+	}
+	for contentType, want := range cases {
+		if got := BodyContentTypeAllowed(contentType, allow); got != want {
+			t.Errorf("%q: got %v, want %v", contentType, got, want)
+		}
+	}
+}
+
+func TestRedactBody(t *testing.T) {
+	fields := []string{"password", "token"}
+	cases := []struct {
+		contentType string
+		body        string
+		want        string
+	}{
+		{"application/json", `{"user":"abc","Password":"123","data":[{"token":"t"}]}`, `{"Password":"***","data":[{"token":"***"}],"user":"abc"}`},
+		{"application/x-www-form-urlencoded", "user=abc&password=123", "password=%2A%2A%2A&user=abc"},
+		{"application/json", `{"user":"abc","password":"12`, `{"user":"abc","password":"***"`},
+	}
+	for _, item := range cases {
+		got := RedactBody(item.contentType, []byte(item.body), fields)
+		if got != item.want {
+			t.Errorf("got %s, want %s", got, item.want)
+		}
+	}
+	if getRedactPattern(fields) != getRedactPattern(fields) {
+		t.Fatal("redact pattern should be compiled once")
+	}
+}
+
+func TestRedactHeader(t *testing.T) {
+	header := http.Header{}
+	header.Set("Authorization", "Bearer abc")
+	header.Set("X-Test", "1")
+	out := RedactHeader(header, DefaultRedactHeaders)
+	if out["Authorization"] != RedactedValue || out["X-Test"] != "1" {
+		t.Fatalf("got %v", out)
+	}
+}
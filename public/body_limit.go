@@ -0,0 +1,56 @@
+package public
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"sync/atomic"
+)
+
+var ErrBodyTooLarge = errors.New("body too large")
+
+// LimitedBody 限制body读取的总字节数，超出后返回ErrBodyTooLarge
+// exceeded由transport读取body的goroutine写入，在错误处理中读取，使用原子操作
+type LimitedBody struct {
+	io.ReadCloser
+	Limit    int64
+	read     int64
+	exceeded int32
+}
+
+func NewLimitedBody(body io.ReadCloser, limit int64) *LimitedBody {
+	return &LimitedBody{ReadCloser: body, Limit: limit}
+}
+
+func (b *LimitedBody) Read(p []byte) (int, error) {
+	if atomic.LoadInt32(&b.exceeded) == 1 {
+		return 0, ErrBodyTooLarge
+	}
+	//多读一个字节用于判断是否超限
+	if remain := b.Limit - b.read + 1; int64(len(p)) > remain {
+		p = p[:remain]
+	}
+	n, err := b.ReadCloser.Read(p)
+	b.read += int64(n)
+	if b.read > b.Limit {
+		atomic.StoreInt32(&b.exceeded, 1)
+		return n - int(b.read-b.Limit), ErrBodyTooLarge
+	}
+	return n, err
+}
+
+// Exceeded 是否已超出限制
+func (b *LimitedBody) Exceeded() bool {
+	return atomic.LoadInt32(&b.exceeded) == 1
+}
+
+// HeaderStat 统计header行数和字节数，字节数按 "Key: value\r\n" 计算
+func HeaderStat(header http.Header) (count int, size int) {
+	for key, values := range header {
+		for _, value := range values {
+			count++
+			size += len(key) + len(value) + 4
+		}
+	}
+	return count, size
+}
@@ -0,0 +1,20 @@
+package public
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestLimitedBody(t *testing.T) {
+	body := NewLimitedBody(ioutil.NopCloser(strings.NewReader("0123456789")), 10)
+	if data, err := ioutil.ReadAll(body); err != nil || string(data) != "0123456789" || body.Exceeded() {
+		t.Fatalf("data = %s, err = %v", data, err)
+	}
+
+	body = NewLimitedBody(ioutil.NopCloser(strings.NewReader("0123456789")), 4)
+	data, err := ioutil.ReadAll(body)
+	if err != ErrBodyTooLarge || string(data) != "0123" || !body.Exceeded() {
+		t.Fatalf("data = %s, err = %v", data, err)
+	}
+}
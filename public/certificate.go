@@ -0,0 +1,137 @@
+package public
+
+import (
+	"FGateWay/golang_common/lib"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"github.com/pkg/errors"
+	"io"
+	"strings"
+	"time"
+)
+
+// 加密后的私钥前缀，便于识别与后续更换算法
+const certKeyCipherPrefix = "enc:v1:"
+
+// CertInfo 证书中解析出的域名与有效期
+type CertInfo struct {
+	Domains   []string
+	NotBefore time.Time
+	NotAfter  time.Time
+}
+
+// ParseCertPair 校验PEM证书与私钥是否匹配，并解析出域名与有效期
+func ParseCertPair(certPem, keyPem string) (*tls.Certificate, *CertInfo, error) {
+	cert, err := tls.X509KeyPair([]byte(certPem), []byte(keyPem))
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "invalid certificate or key")
+	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "parse certificate")
+	}
+	cert.Leaf = leaf
+
+	domains := []string{}
+	seen := map[string]bool{}
+	for _, name := range append([]string{leaf.Subject.CommonName}, leaf.DNSNames...) {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+		domains = append(domains, name)
+	}
+	return &cert, &CertInfo{Domains: domains, NotBefore: leaf.NotBefore, NotAfter: leaf.NotAfter}, nil
+}
+
+// SplitCertKeyPem 将私钥与证书链混合的PEM(autocert缓存格式)拆分为证书链与私钥
+func SplitCertKeyPem(data []byte) (string, string, error) {
+	var certBuf, keyBuf bytes.Buffer
+	for {
+		block, rest := pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type == "CERTIFICATE" {
+			pem.Encode(&certBuf, block)
+		} else if strings.HasSuffix(block.Type, "PRIVATE KEY") {
+			pem.Encode(&keyBuf, block)
+		}
+		data = rest
+	}
+	if certBuf.Len() == 0 || keyBuf.Len() == 0 {
+		return "", "", errors.New("pem data need both certificate and private key")
+	}
+	return certBuf.String(), keyBuf.String(), nil
+}
+
+// 证书私钥加密密钥，由base.cert.secret_key派生
+func certKeySecret() ([]byte, error) {
+	secret := lib.GetStringConf("base.cert.secret_key")
+	if secret == "" {
+		return nil, errors.New("base.cert.secret_key not configured")
+	}
+	sum := sha256.Sum256([]byte(secret))
+	return sum[:], nil
+}
+
+// EncryptCertKey 使用AES-GCM加密私钥后入库
+func EncryptCertKey(plain string) (string, error) {
+	secret, err := certKeySecret()
+	if err != nil {
+		return "", err
+	}
+	block, err := aes.NewCipher(secret)
+	if err != nil {
+		return "", err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+	data := gcm.Seal(nonce, nonce, []byte(plain), nil)
+	return certKeyCipherPrefix + base64.StdEncoding.EncodeToString(data), nil
+}
+
+// DecryptCertKey 解密入库的私钥
+func DecryptCertKey(encrypted string) (string, error) {
+	if !strings.HasPrefix(encrypted, certKeyCipherPrefix) {
+		return "", errors.New("certificate key is not encrypted")
+	}
+	data, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(encrypted, certKeyCipherPrefix))
+	if err != nil {
+		return "", err
+	}
+	secret, err := certKeySecret()
+	if err != nil {
+		return "", err
+	}
+	block, err := aes.NewCipher(secret)
+	if err != nil {
+		return "", err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return "", err
+	}
+	if len(data) < gcm.NonceSize() {
+		return "", errors.New("invalid encrypted certificate key")
+	}
+	plain, err := gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], nil)
+	if err != nil {
+		return "", errors.Wrap(err, "decrypt certificate key")
+	}
+	return string(plain), nil
+}
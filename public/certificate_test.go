@@ -0,0 +1,87 @@
+package public
+
+import (
+	"FGateWay/golang_common/lib"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"github.com/spf13/viper"
+	"math/big"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func testCertPem(t *testing.T, commonName string, dnsNames ...string) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: commonName},
+		DNSNames:     dnsNames,
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(24 * time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})),
+		string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}))
+}
+
+func TestParseCertPair(t *testing.T) {
+	certPem, keyPem := testCertPem(t, "www.test.com", "www.test.com", "*.Test.com")
+	cert, info, err := ParseCertPair(certPem, keyPem)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cert.Leaf == nil || !reflect.DeepEqual(info.Domains, []string{"www.test.com", "*.test.com"}) {
+		t.Fatalf("domains = %v", info.Domains)
+	}
+
+	_, otherKey := testCertPem(t, "other.com")
+	if _, _, err := ParseCertPair(certPem, otherKey); err == nil {
+		t.Fatal("mismatched key should fail")
+	}
+}
+
+func TestSplitCertKeyPem(t *testing.T) {
+	certPem, keyPem := testCertPem(t, "www.test.com")
+	gotCert, gotKey, err := SplitCertKeyPem([]byte(keyPem + certPem))
+	if err != nil || gotCert != certPem || gotKey != keyPem {
+		t.Fatalf("cert = %s, key = %s, err = %v", gotCert, gotKey, err)
+	}
+	if _, _, err := SplitCertKeyPem([]byte(certPem)); err == nil {
+		t.Fatal("missing key should fail")
+	}
+}
+
+func TestEncryptCertKey(t *testing.T) {
+	conf := viper.New()
+	conf.Set("cert.secret_key", "test_secret")
+	lib.ViperConfMap = map[string]*viper.Viper{"base": conf}
+	defer func() { lib.ViperConfMap = nil }()
+
+	encrypted, err := EncryptCertKey("private key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plain, err := DecryptCertKey(encrypted); err != nil || plain != "private key" {
+		t.Fatalf("plain = %s, err = %v", plain, err)
+	}
+
+	conf.Set("cert.secret_key", "other_secret")
+	if _, err := DecryptCertKey(encrypted); err == nil {
+		t.Fatal("decrypt with other secret should fail")
+	}
+}
@@ -0,0 +1,145 @@
+package public
+
+import (
+	"compress/gzip"
+	"github.com/andybalholm/brotli"
+	"github.com/pkg/errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	EncodingGzip     = "gzip"
+	EncodingBrotli   = "br"
+	EncodingIdentity = "identity"
+
+	CompressDefaultMinSize = 1024
+	//压缩级别兼顾速度，网关上不使用最高级别
+	CompressGzipLevel   = gzip.DefaultCompression
+	CompressBrotliLevel = 5
+)
+
+var (
+	DefaultCompressEncodings    = []string{EncodingBrotli, EncodingGzip}
+	DefaultCompressContentTypes = []string{"text/html", "text/plain", "text/css", "text/xml", "text/javascript",
+		"application/json", "application/javascript", "application/xml", "application/problem+json"}
+)
+
+// CompressPolicy 服务的响应压缩配置
+type CompressPolicy struct {
+	Encodings    []string //支持的编码，按优先级排列
+	MinSize      int      //小于该字节数的响应不压缩
+	ContentTypes []string //允许压缩的content-type，以/结尾时按前缀匹配
+}
+
+// Negotiate 按Accept-Encoding的q值选择编码，q值相同时按服务配置的优先级；无可用编码返回空
+func (p *CompressPolicy) Negotiate(acceptEncoding string) string {
+	if acceptEncoding == "" {
+		return ""
+	}
+	qualities := map[string]float64{}
+	for _, item := range strings.Split(acceptEncoding, ",") {
+		name, q := strings.TrimSpace(item), 1.0
+		if idx := strings.Index(name, ";"); idx >= 0 {
+			params := strings.TrimSpace(name[idx+1:])
+			name = strings.TrimSpace(name[:idx])
+			if strings.HasPrefix(params, "q=") {
+				if value, err := strconv.ParseFloat(strings.TrimPrefix(params, "q="), 64); err == nil {
+					q = value
+				}
+			}
+		}
+		if name != "" {
+			qualities[strings.ToLower(name)] = q
+		}
+	}
+	best, bestQ := "", 0.0
+	for _, encoding := range p.Encodings {
+		q, ok := qualities[encoding]
+		if !ok {
+			if q, ok = qualities["*"]; !ok {
+				continue
+			}
+		}
+		if q > bestQ {
+			best, bestQ = encoding, q
+		}
+	}
+	return best
+}
+
+// Compressible 响应是否允许压缩：已编码、no-transform或content-type不在列表中的不压缩
+func (p *CompressPolicy) Compressible(header http.Header) bool {
+	if encoding := header.Get("Content-Encoding"); encoding != "" && encoding != EncodingIdentity {
+		return false
+	}
+	if _, ok := ParseCacheControl(header)["no-transform"]; ok {
+		return false
+	}
+	return BodyContentTypeAllowed(header.Get("Content-Type"), p.ContentTypes)
+}
+
+// ValidCompressEncodings 校验逗号间隔的压缩编码，只支持br与gzip
+func ValidCompressEncodings(encodings string) error {
+	for _, encoding := range splitList(encodings) {
+		if encoding = strings.ToLower(encoding); encoding != EncodingBrotli && encoding != EncodingGzip {
+			return errors.Errorf("不支持的压缩编码 %v", encoding)
+		}
+	}
+	return nil
+}
+
+// CompressWriter 压缩后写入w，Flush用于流式响应
+type CompressWriter interface {
+	io.WriteCloser
+	Flush() error
+}
+
+func NewCompressWriter(w io.Writer, encoding string) (CompressWriter, error) {
+	switch encoding {
+	case EncodingGzip:
+		writer, err := gzip.NewWriterLevel(w, CompressGzipLevel)
+		if err != nil {
+			return nil, err
+		}
+		return writer, nil
+	case EncodingBrotli:
+		return brotli.NewWriterLevel(w, CompressBrotliLevel), nil
+	}
+	return nil, errors.Errorf("unsupported encoding %v", encoding)
+}
+
+// NewDecompressReader 解压请求体，gzip在创建时校验头部
+func NewDecompressReader(body io.ReadCloser, encoding string) (io.ReadCloser, error) {
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case EncodingGzip, "x-gzip":
+		reader, err := gzip.NewReader(body)
+		if err != nil {
+			return nil, err
+		}
+		return &decompressBody{Reader: reader, body: body}, nil
+	case EncodingBrotli:
+		return &decompressBody{Reader: brotli.NewReader(body), body: body}, nil
+	}
+	return nil, errors.Errorf("unsupported encoding %v", encoding)
+}
+
+// DecompressSupported 请求的Content-Encoding是否可以由网关解压
+func DecompressSupported(encoding string) bool {
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case EncodingGzip, "x-gzip", EncodingBrotli:
+		return true
+	}
+	return false
+}
+
+type decompressBody struct {
+	io.Reader
+	body io.ReadCloser
+}
+
+func (d *decompressBody) Close() error {
+	return d.body.Close()
+}
@@ -0,0 +1,90 @@
+package public
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestCompressPolicyNegotiate(t *testing.T) {
+	policy := &CompressPolicy{Encodings: DefaultCompressEncodings}
+	cases := []struct {
+		accept string
+		want   string
+	}{
+		{"", ""},
+		{"gzip, deflate, br", EncodingBrotli},
+		{"gzip", EncodingGzip},
+		{"br;q=0.5, gzip", EncodingGzip},
+		{"br;q=0, gzip;q=0", ""},
+		{"deflate", ""},
+		{"*", EncodingBrotli},
+		{"gzip;q=0, *;q=0.1", EncodingBrotli},
+	}
+	for _, item := range cases {
+		if got := policy.Negotiate(item.accept); got != item.want {
+			t.Errorf("Negotiate(%q)=%q, want %q", item.accept, got, item.want)
+		}
+	}
+	gzipOnly := &CompressPolicy{Encodings: []string{EncodingGzip}}
+	if got := gzipOnly.Negotiate("br, gzip"); got != EncodingGzip {
+		t.Errorf("gzip only got %q", got)
+	}
+}
+
+func TestCompressPolicyCompressible(t *testing.T) {
+	policy := &CompressPolicy{ContentTypes: DefaultCompressContentTypes}
+	cases := []struct {
+		header http.Header
+		want   bool
+	}{
+		{http.Header{"Content-Type": {"application/json; charset=utf-8"}}, true},
+		{http.Header{"Content-Type": {"image/png"}}, false},
+		{http.Header{}, false},
+		{http.Header{"Content-Type": {"text/html"}, "Content-Encoding": {"gzip"}}, false},
+		{http.Header{"Content-Type": {"text/html"}, "Cache-Control": {"public, no-transform"}}, false},
+	}
+	for _, item := range cases {
+		if got := policy.Compressible(item.header); got != item.want {
+			t.Errorf("Compressible(%v)=%v, want %v", item.header, got, item.want)
+		}
+	}
+}
+
+func TestCompressRoundTrip(t *testing.T) {
+	data := strings.Repeat(`{"name":"gateway","value":12345}`, 100)
+	for _, encoding := range DefaultCompressEncodings {
+		buf := &bytes.Buffer{}
+		writer, err := NewCompressWriter(buf, encoding)
+		if err != nil {
+			t.Fatal(err)
+		}
+		writer.Write([]byte(data))
+		writer.Close()
+		if buf.Len() >= len(data) {
+			t.Errorf("%s not compressed: %d >= %d", encoding, buf.Len(), len(data))
+		}
+		reader, err := NewDecompressReader(io.NopCloser(buf), encoding)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out, err := io.ReadAll(reader)
+		if err != nil || string(out) != data {
+			t.Errorf("%s round trip failed: %v", encoding, err)
+		}
+	}
+	if _, err := NewDecompressReader(io.NopCloser(strings.NewReader("plain")), EncodingGzip); err == nil {
+		t.Error("invalid gzip body should fail")
+	}
+}
+
+func TestValidCompressEncodings(t *testing.T) {
+	if err := ValidCompressEncodings("br, GZIP"); err != nil {
+		t.Error(err)
+	}
+	if err := ValidCompressEncodings("gzip,deflate"); err == nil {
+		t.Error("deflate should be rejected")
+	}
+}
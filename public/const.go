@@ -15,6 +15,29 @@ const (
 	RedisFlowDayKey  = "flow_day_count"
 	RedisFlowHourKey = "flow_hour_count"
 
+	RedisFlowStatMinuteKey = "flow_stat_minute"
+	RedisFlowStatHourKey   = "flow_stat_hour"
+
+	RedisUpstreamHealthKey = "upstream_health"
+	RedisInstanceKey       = "gateway_instance"
+
+	RedisQuotaDayKey   = "flow_quota_day"
+	RedisQuotaMonthKey = "flow_quota_month"
+
+	RedisTokenRevokeJtiKey = "token_revoke_jti"
+	RedisTokenRevokeAppKey = "token_revoke_app"
+
+	RedisAcmeCacheKey = "acme_cache"
+	RedisAcmeLockKey  = "acme_lock"
+
+	RedisResponseCacheKey      = "response_cache"
+	RedisResponseCacheIndexKey = "response_cache_index"
+	RedisCachePurgeKey         = "response_cache_purge"
+	RedisCachePurgeSeqKey      = "response_cache_purge_seq"
+
+	CertSourceUpload = "upload"
+	CertSourceAcme   = "acme"
+
 	FlowTotal         = "flow_total"
 	FlowServicePrefix = "flow_service_"
 	FlowAppPrefix     = "flow_app_"
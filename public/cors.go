@@ -0,0 +1,147 @@
+package public
+
+import (
+	"github.com/pkg/errors"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	HeaderOrigin                     = "Origin"
+	HeaderAccessControlRequestMethod = "Access-Control-Request-Method"
+	HeaderAccessControlRequestHeader = "Access-Control-Request-Headers"
+	HeaderAccessControlAllowOrigin   = "Access-Control-Allow-Origin"
+	HeaderAccessControlAllowMethods  = "Access-Control-Allow-Methods"
+	HeaderAccessControlAllowHeaders  = "Access-Control-Allow-Headers"
+	HeaderAccessControlAllowCreds    = "Access-Control-Allow-Credentials"
+	HeaderAccessControlExposeHeaders = "Access-Control-Expose-Headers"
+	HeaderAccessControlMaxAge        = "Access-Control-Max-Age"
+)
+
+const DefaultCorsAllowMethods = "GET,POST,PUT,PATCH,DELETE,HEAD,OPTIONS"
+
+// CorsPolicy 服务的跨域策略，列表均为逗号间隔
+type CorsPolicy struct {
+	AllowOrigins     string //允许的来源，支持*及通配子域名，如 https://*.test.com
+	AllowMethods     string //为空时使用DefaultCorsAllowMethods
+	AllowHeaders     string //为空时回显预检请求的Access-Control-Request-Headers
+	ExposeHeaders    string
+	AllowCredentials bool
+	MaxAge           int //预检结果缓存时间, 单位s
+}
+
+// IsPreflight 是否为跨域预检请求
+func IsPreflight(r *http.Request) bool {
+	return r.Method == http.MethodOptions && r.Header.Get(HeaderOrigin) != "" &&
+		r.Header.Get(HeaderAccessControlRequestMethod) != ""
+}
+
+// ValidCorsOrigins 允许携带凭证时必须配置明确的来源，不能使用*
+func ValidCorsOrigins(origins string, allowCredentials bool) error {
+	if !allowCredentials {
+		return nil
+	}
+	for _, item := range splitList(origins) {
+		if item == "*" {
+			return errors.New("允许携带凭证时来源不能配置为*")
+		}
+	}
+	return nil
+}
+
+// AnyOrigin 是否允许任意来源
+func (p *CorsPolicy) AnyOrigin() bool {
+	for _, item := range splitList(p.AllowOrigins) {
+		if item == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// OriginAllowed 来源是否在允许列表中，忽略大小写
+func (p *CorsPolicy) OriginAllowed(origin string) bool {
+	origin = strings.ToLower(origin)
+	for _, item := range splitList(p.AllowOrigins) {
+		item = strings.ToLower(item)
+		if item == "*" || item == origin {
+			return true
+		}
+		//*.test.com 匹配任意层级子域名，不匹配test.com本身
+		idx := strings.Index(item, "*.")
+		if idx >= 0 && strings.HasPrefix(origin, item[:idx]) && strings.HasSuffix(origin, item[idx+1:]) &&
+			len(origin) > len(item)-1 {
+			return true
+		}
+	}
+	return false
+}
+
+// MethodAllowed 预检请求的方法是否允许
+func (p *CorsPolicy) MethodAllowed(method string) bool {
+	methods := p.AllowMethods
+	if methods == "" {
+		methods = DefaultCorsAllowMethods
+	}
+	for _, item := range splitList(methods) {
+		if strings.EqualFold(item, method) {
+			return true
+		}
+	}
+	return false
+}
+
+// SetHeaders 为允许的来源写入跨域响应头，preflight为true时附带预检相关的header
+func (p *CorsPolicy) SetHeaders(header http.Header, r *http.Request, preflight bool) {
+	origin := r.Header.Get(HeaderOrigin)
+	header.Add("Vary", HeaderOrigin)
+	//允许任意来源时不下发凭证，避免任意站点携带cookie访问；保存时已拒绝该组合，这里兼顾已有配置
+	if p.AnyOrigin() {
+		header.Set(HeaderAccessControlAllowOrigin, "*")
+	} else {
+		header.Set(HeaderAccessControlAllowOrigin, origin)
+		if p.AllowCredentials {
+			header.Set(HeaderAccessControlAllowCreds, "true")
+		}
+	}
+	if !preflight {
+		if p.ExposeHeaders != "" {
+			header.Set(HeaderAccessControlExposeHeaders, p.ExposeHeaders)
+		}
+		return
+	}
+	methods := p.AllowMethods
+	if methods == "" {
+		methods = DefaultCorsAllowMethods
+	}
+	header.Set(HeaderAccessControlAllowMethods, methods)
+	if p.AllowHeaders != "" {
+		header.Set(HeaderAccessControlAllowHeaders, p.AllowHeaders)
+	} else if reqHeaders := r.Header.Get(HeaderAccessControlRequestHeader); reqHeaders != "" {
+		header.Add("Vary", HeaderAccessControlRequestHeader)
+		header.Set(HeaderAccessControlAllowHeaders, reqHeaders)
+	}
+	if p.MaxAge > 0 {
+		header.Set(HeaderAccessControlMaxAge, strconv.Itoa(p.MaxAge))
+	}
+}
+
+// DelCorsHeaders 删除上游返回的跨域header，由网关统一下发
+func DelCorsHeaders(header http.Header) {
+	for _, key := range []string{HeaderAccessControlAllowOrigin, HeaderAccessControlAllowMethods,
+		HeaderAccessControlAllowHeaders, HeaderAccessControlAllowCreds,
+		HeaderAccessControlExposeHeaders, HeaderAccessControlMaxAge} {
+		header.Del(key)
+	}
+}
+
+func splitList(s string) []string {
+	list := []string{}
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
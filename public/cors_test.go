@@ -0,0 +1,98 @@
+package public
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCorsOriginAllowed(t *testing.T) {
+	policy := &CorsPolicy{AllowOrigins: "https://www.test.com, https://*.example.com"}
+	cases := map[string]bool{
+		"https://www.test.com":      true,
+		"HTTPS://WWW.TEST.COM":      true,
+		"http://www.test.com":       false,
+		"https://a.example.com":     true,
+		"https://a.b.example.com":   true,
+		"https://example.com":       false,
+		"https://evilexample.com":   false,
+		"https://a.example.com:443": false,
+	}
+	for origin, want := range cases {
+		if got := policy.OriginAllowed(origin); got != want {
+			t.Errorf("OriginAllowed(%s)=%v, want %v", origin, got, want)
+		}
+	}
+	if !(&CorsPolicy{AllowOrigins: "*"}).OriginAllowed("http://any.com") {
+		t.Fatal("* should allow any origin")
+	}
+}
+
+func TestCorsSetHeaders(t *testing.T) {
+	r := httptest.NewRequest(http.MethodOptions, "/abc", nil)
+	r.Header.Set(HeaderOrigin, "https://a.example.com")
+	r.Header.Set(HeaderAccessControlRequestMethod, http.MethodPut)
+	r.Header.Set(HeaderAccessControlRequestHeader, "X-Token")
+	if !IsPreflight(r) {
+		t.Fatal("should be preflight")
+	}
+
+	policy := &CorsPolicy{AllowOrigins: "https://*.example.com", AllowCredentials: true, MaxAge: 600}
+	if !policy.MethodAllowed(http.MethodPut) || policy.MethodAllowed("TRACE") {
+		t.Fatal("unexpected method check")
+	}
+	header := http.Header{}
+	policy.SetHeaders(header, r, true)
+	if header.Get(HeaderAccessControlAllowOrigin) != "https://a.example.com" {
+		t.Fatalf("credentials should echo origin, got %s", header.Get(HeaderAccessControlAllowOrigin))
+	}
+	if header.Get(HeaderAccessControlAllowCreds) != "true" || header.Get(HeaderAccessControlMaxAge) != "600" ||
+		header.Get(HeaderAccessControlAllowHeaders) != "X-Token" || header.Get(HeaderAccessControlAllowMethods) != DefaultCorsAllowMethods {
+		t.Fatalf("unexpected preflight headers %v", header)
+	}
+
+	policy = &CorsPolicy{AllowOrigins: "*", ExposeHeaders: "X-Trace-Id"}
+	header = http.Header{}
+	policy.SetHeaders(header, r, false)
+	if header.Get(HeaderAccessControlAllowOrigin) != "*" || header.Get(HeaderAccessControlExposeHeaders) != "X-Trace-Id" ||
+		header.Get(HeaderAccessControlAllowMethods) != "" {
+		t.Fatalf("unexpected headers %v", header)
+	}
+
+	//已保存的*与携带凭证组合不回显来源，也不下发凭证
+	policy = &CorsPolicy{AllowOrigins: "*", AllowCredentials: true}
+	header = http.Header{}
+	policy.SetHeaders(header, r, false)
+	if header.Get(HeaderAccessControlAllowOrigin) != "*" || header.Get(HeaderAccessControlAllowCreds) != "" {
+		t.Fatalf("unexpected headers %v", header)
+	}
+}
+
+func TestValidCorsOrigins(t *testing.T) {
+	if err := ValidCorsOrigins("*", true); err == nil {
+		t.Fatal("* with credentials should be rejected")
+	}
+	if err := ValidCorsOrigins("https://a.com, *", true); err == nil {
+		t.Fatal("* with credentials should be rejected")
+	}
+	if err := ValidCorsOrigins("*", false); err != nil {
+		t.Fatal(err)
+	}
+	if err := ValidCorsOrigins("https://a.com,https://*.b.com", true); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestApplyHeaderTransfor(t *testing.T) {
+	header := http.Header{}
+	header.Set("Server", "nginx")
+	header.Set("X-Old", "1")
+	rules, err := ParseHeaderRules("add X-Gateway fgateway,edit X-Old 2,del Server nginx,bad rule")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ApplyHeaderRules(header, rules, nil)
+	if header.Get("X-Gateway") != "fgateway" || header.Get("X-Old") != "2" || header.Get("Server") != "" {
+		t.Fatalf("unexpected header %v", header)
+	}
+}
@@ -1,33 +1,174 @@
 package public
 
 import (
+	"FGateWay/golang_common/lib"
+	"fmt"
+	"github.com/garyburd/redigo/redis"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
+const (
+	FlowCountInterval = 1 * time.Second
+	// 计数器超过该周期数未被访问则回收，对应已删除的服务/租户
+	FlowCountIdleTicks = 600
+)
+
 var FlowCounterHandler *FlowCounter
 
 type FlowCounter struct {
 	RedisFlowCountMap   map[string]*RedisFlowCountService
 	RedisFlowCountSlice []*RedisFlowCountService
 	Locker              sync.RWMutex
+	Tick                int64
+	once                sync.Once
 }
 
 func (c *FlowCounter) GetCounter(serverName string) (*RedisFlowCountService, error) {
-	for _, item := range c.RedisFlowCountSlice {
-		if item.AppID == serverName {
-			return item, nil
+	c.once.Do(func() {
+		go c.run()
+	})
+	tick := atomic.LoadInt64(&c.Tick)
+
+	c.Locker.RLock()
+	counter, ok := c.RedisFlowCountMap[serverName]
+	c.Locker.RUnlock()
+	if ok {
+		if atomic.LoadInt64(&counter.LastTick) != tick {
+			atomic.StoreInt64(&counter.LastTick, tick)
 		}
+		return counter, nil
 	}
 
-	newCounter := NewRedisFlowCountService(serverName, 1*time.Second)
-	c.RedisFlowCountSlice = append(c.RedisFlowCountSlice, newCounter)
 	c.Locker.Lock()
 	defer c.Locker.Unlock()
+	if counter, ok := c.RedisFlowCountMap[serverName]; ok {
+		return counter, nil
+	}
+	newCounter := NewRedisFlowCountService(serverName, FlowCountInterval)
+	newCounter.LastTick = tick
+	c.RedisFlowCountSlice = append(c.RedisFlowCountSlice, newCounter)
 	c.RedisFlowCountMap[serverName] = newCounter
 	return newCounter, nil
 }
 
+func (c *FlowCounter) rebuildSlice() {
+	slice := make([]*RedisFlowCountService, 0, len(c.RedisFlowCountMap))
+	for _, item := range c.RedisFlowCountSlice {
+		if _, ok := c.RedisFlowCountMap[item.AppID]; ok {
+			slice = append(slice, item)
+		}
+	}
+	c.RedisFlowCountSlice = slice
+}
+
+func (c *FlowCounter) run() {
+	ticker := time.NewTicker(FlowCountInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		c.flush()
+	}
+}
+
+// 所有计数器的增量合并到一个pipeline写入redis，再用一次MGET读回当日总量
+func (c *FlowCounter) flush() {
+	defer func() {
+		if err := recover(); err != nil {
+			fmt.Println(err)
+		}
+	}()
+	tick := atomic.AddInt64(&c.Tick, 1)
+	c.gc(tick)
+
+	c.Locker.RLock()
+	counters := make([]*RedisFlowCountService, len(c.RedisFlowCountSlice))
+	copy(counters, c.RedisFlowCountSlice)
+	c.Locker.RUnlock()
+	if len(counters) == 0 {
+		return
+	}
+
+	currentTime := time.Now()
+	tickerCounts := make([]int64, len(counters))
+	statDeltas := make([]*flowStatDelta, len(counters))
+	dayKeys := make([]interface{}, len(counters))
+	hasIncr := false
+	for i, counter := range counters {
+		tickerCounts[i] = counter.swapTickerCount()
+		statDeltas[i] = counter.swapStat()
+		dayKeys[i] = counter.GetDayKey(currentTime)
+		if tickerCounts[i] > 0 || statDeltas[i] != nil {
+			hasIncr = true
+		}
+	}
+	if hasIncr {
+		conn, err := lib.RedisConnFactory("default")
+		if err != nil {
+			fmt.Println("FlowCounter redis conn err", err)
+			//未发出任何命令，把增量还回去，下个周期重试
+			for i, counter := range counters {
+				atomic.AddInt64(&counter.TickerCount, tickerCounts[i])
+				if statDeltas[i] != nil {
+					counter.restoreStat(statDeltas[i])
+				}
+			}
+			return
+		}
+		for i, counter := range counters {
+			if statDeltas[i] != nil {
+				counter.sendStat(conn, currentTime, statDeltas[i])
+			}
+			if tickerCounts[i] == 0 {
+				continue
+			}
+			hourKey := counter.GetHourKey(currentTime)
+			conn.Send("INCRBY", dayKeys[i], tickerCounts[i])
+			conn.Send("EXPIRE", dayKeys[i], 86400*2)
+			conn.Send("INCRBY", hourKey, tickerCounts[i])
+			conn.Send("EXPIRE", hourKey, 86400*2)
+		}
+		//命令已发出后失败的可能已部分写入，重试会重复计数，丢弃本周期增量
+		err = conn.Flush()
+		conn.Close()
+		if err != nil {
+			fmt.Println("FlowCounter pipeline err, drop deltas", err)
+			return
+		}
+	}
+
+	totals, err := redis.Int64s(RedisConfDo("MGET", dayKeys...))
+	if err != nil {
+		fmt.Println("FlowCounter MGET err", err)
+		return
+	}
+	nowUnix := time.Now().Unix()
+	for i, counter := range counters {
+		if i < len(totals) {
+			counter.updateTotal(totals[i], nowUnix)
+		}
+	}
+}
+
+// 回收长时间未被访问、没有进行中的请求且没有待写入增量的计数器
+func (c *FlowCounter) gc(tick int64) {
+	c.Locker.Lock()
+	defer c.Locker.Unlock()
+	removed := false
+	for name, counter := range c.RedisFlowCountMap {
+		if tick-atomic.LoadInt64(&counter.LastTick) > FlowCountIdleTicks &&
+			atomic.LoadInt64(&counter.Inflight) == 0 &&
+			atomic.LoadInt64(&counter.TickerCount) == 0 &&
+			!counter.hasPendingStat() {
+			delete(c.RedisFlowCountMap, name)
+			removed = true
+		}
+	}
+	if removed {
+		c.rebuildSlice()
+	}
+}
+
 func NewFlowCounter() *FlowCounter {
 	return &FlowCounter{
 		RedisFlowCountMap:   map[string]*RedisFlowCountService{},
@@ -0,0 +1,60 @@
+package public
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestFlowCounterGC(t *testing.T) {
+	counter := NewFlowCounter()
+	counter.once.Do(func() {})
+	a, _ := counter.GetCounter("flow_service_a")
+	b, _ := counter.GetCounter("flow_service_b")
+	if same, _ := counter.GetCounter("flow_service_a"); same != a {
+		t.Fatal("GetCounter should return the same counter")
+	}
+
+	counter.Tick = FlowCountIdleTicks
+	counter.GetCounter("flow_service_a")
+	b.Increase()
+	counter.gc(FlowCountIdleTicks + 2)
+	if len(counter.RedisFlowCountSlice) != 2 {
+		t.Fatal("counter with pending count should not be removed")
+	}
+	b.swapTickerCount()
+	//请求尚未结束时不回收
+	counter.gc(FlowCountIdleTicks + 2)
+	if len(counter.RedisFlowCountSlice) != 2 {
+		t.Fatal("counter with inflight request should not be removed")
+	}
+	//请求结束后有待写入的统计时不回收
+	b.Record(200, 0, false)
+	counter.gc(FlowCountIdleTicks + 2)
+	if len(counter.RedisFlowCountSlice) != 2 {
+		t.Fatal("counter with pending stat should not be removed")
+	}
+	b.swapStat()
+	counter.gc(FlowCountIdleTicks + 2)
+	if _, ok := counter.RedisFlowCountMap["flow_service_b"]; ok || len(counter.RedisFlowCountSlice) != 1 {
+		t.Fatal("idle counter should be removed")
+	}
+}
+
+// 每个代理请求的计数开销：总量、服务、租户三个计数器
+func BenchmarkFlowCounterIncrease(b *testing.B) {
+	counter := NewFlowCounter()
+	counter.once.Do(func() {})
+	for i := 0; i < 100; i++ {
+		counter.GetCounter(FlowServicePrefix + strconv.Itoa(i))
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			for _, name := range []string{FlowTotal, FlowServicePrefix + "1", FlowAppPrefix + "app_id_a"} {
+				item, _ := counter.GetCounter(name)
+				item.Increase()
+			}
+		}
+	})
+}
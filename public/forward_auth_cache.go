@@ -0,0 +1,100 @@
+package public
+
+import (
+	"container/list"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ForwardAuthCacheMaxEntries 鉴权结果缓存最大条数
+const ForwardAuthCacheMaxEntries = 10000
+
+var ForwardAuthCacheHandler *ForwardAuthCache
+
+// ForwardAuthCache 外部鉴权结果缓存，key由服务名和凭证组成
+// 超过最大条数时淘汰最久未使用的，避免变换header的请求让缓存无限增长
+type ForwardAuthCache struct {
+	MaxEntries int
+	CacheMap   map[string]*list.Element
+	Locker     sync.Mutex
+	list       *list.List
+	lastSweep  time.Time
+}
+
+type ForwardAuthItem struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+	ExpireAt   time.Time
+	key        string
+}
+
+func NewForwardAuthCache(maxEntries int) *ForwardAuthCache {
+	if maxEntries <= 0 {
+		maxEntries = ForwardAuthCacheMaxEntries
+	}
+	return &ForwardAuthCache{
+		MaxEntries: maxEntries,
+		CacheMap:   map[string]*list.Element{},
+		Locker:     sync.Mutex{},
+		list:       list.New(),
+		lastSweep:  time.Now(),
+	}
+}
+
+func init() {
+	ForwardAuthCacheHandler = NewForwardAuthCache(ForwardAuthCacheMaxEntries)
+}
+
+func (f *ForwardAuthCache) Get(key string) (*ForwardAuthItem, bool) {
+	f.Locker.Lock()
+	defer f.Locker.Unlock()
+	elem, ok := f.CacheMap[key]
+	if !ok {
+		return nil, false
+	}
+	item := elem.Value.(*ForwardAuthItem)
+	if time.Now().After(item.ExpireAt) {
+		f.remove(elem)
+		return nil, false
+	}
+	f.list.MoveToFront(elem)
+	return item, true
+}
+
+func (f *ForwardAuthCache) Set(key string, item *ForwardAuthItem) {
+	f.Locker.Lock()
+	defer f.Locker.Unlock()
+	if elem, ok := f.CacheMap[key]; ok {
+		f.remove(elem)
+	}
+	item.key = key
+	f.CacheMap[key] = f.list.PushFront(item)
+	for f.list.Len() > f.MaxEntries {
+		f.remove(f.list.Back())
+	}
+
+	//每分钟清理一次过期数据
+	now := time.Now()
+	if now.Sub(f.lastSweep) < time.Minute {
+		return
+	}
+	f.lastSweep = now
+	for _, elem := range f.CacheMap {
+		if now.After(elem.Value.(*ForwardAuthItem).ExpireAt) {
+			f.remove(elem)
+		}
+	}
+}
+
+func (f *ForwardAuthCache) Len() int {
+	f.Locker.Lock()
+	defer f.Locker.Unlock()
+	return f.list.Len()
+}
+
+func (f *ForwardAuthCache) remove(elem *list.Element) {
+	item := f.list.Remove(elem).(*ForwardAuthItem)
+	delete(f.CacheMap, item.key)
+}
@@ -0,0 +1,38 @@
+package public
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestForwardAuthCacheEvict(t *testing.T) {
+	cache := NewForwardAuthCache(3)
+	for i := 0; i < 3; i++ {
+		cache.Set(fmt.Sprintf("key_%d", i), &ForwardAuthItem{StatusCode: 200, ExpireAt: time.Now().Add(time.Minute)})
+	}
+	//访问过的key_0不会被淘汰
+	if _, ok := cache.Get("key_0"); !ok {
+		t.Fatal("key_0 expected")
+	}
+	for i := 3; i < 10; i++ {
+		cache.Set(fmt.Sprintf("key_%d", i), &ForwardAuthItem{StatusCode: 200, ExpireAt: time.Now().Add(time.Minute)})
+		cache.Get("key_0")
+	}
+	if cache.Len() != 3 {
+		t.Fatalf("cache len=%d", cache.Len())
+	}
+	for _, key := range []string{"key_0", "key_8", "key_9"} {
+		if _, ok := cache.Get(key); !ok {
+			t.Fatalf("%s expected", key)
+		}
+	}
+	if _, ok := cache.Get("key_1"); ok {
+		t.Fatal("key_1 should be evicted")
+	}
+
+	cache.Set("expired", &ForwardAuthItem{ExpireAt: time.Now().Add(-time.Second)})
+	if _, ok := cache.Get("expired"); ok || cache.Len() != 2 {
+		t.Fatalf("expired item should be removed, len=%d", cache.Len())
+	}
+}
@@ -0,0 +1,115 @@
+package public
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	HeaderRuleSet    = "set"    //覆盖同名header
+	HeaderRuleAppend = "append" //追加一个值
+	HeaderRuleDel    = "del"    //删除header
+)
+
+// HeaderRule header转换规则，value支持${var}变量，如 {"op":"set","name":"X-Tenant","value":"${app.id}"}
+type HeaderRule struct {
+	Op    string `json:"op"`
+	Name  string `json:"name"`
+	Value string `json:"value,omitempty"`
+}
+
+// ParseHeaderRules 解析header转换规则，兼容旧格式: add headname headvalue，多个逗号间隔，add/edit均为覆盖。
+// 与旧版本一致，旧格式中无法解析的单条规则直接跳过；JSON格式任一规则无效时返回错误
+func ParseHeaderRules(rules string) ([]HeaderRule, error) {
+	return parseHeaderRules(rules, false)
+}
+
+// ValidHeaderRules 保存时校验，旧格式中的每条规则也必须有效
+func ValidHeaderRules(rules string) error {
+	_, err := parseHeaderRules(rules, true)
+	return err
+}
+
+func parseHeaderRules(rules string, strict bool) ([]HeaderRule, error) {
+	list := []HeaderRule{}
+	if strings.TrimSpace(rules) == "" {
+		return list, nil
+	}
+	if isJsonRules(rules) {
+		if err := json.Unmarshal([]byte(rules), &list); err != nil {
+			return nil, errors.Wrap(err, "invalid header rules")
+		}
+		for _, rule := range list {
+			if err := validHeaderRule(rule); err != nil {
+				return nil, err
+			}
+		}
+		return list, nil
+	}
+	for _, item := range strings.Split(rules, ",") {
+		items := strings.Split(item, " ")
+		if len(items) != 3 {
+			if strict {
+				return nil, errors.Errorf("invalid header rule %s", item)
+			}
+			continue
+		}
+		op := items[0]
+		if op == "add" || op == "edit" {
+			op = HeaderRuleSet
+		}
+		rule := HeaderRule{Op: op, Name: items[1], Value: items[2]}
+		if err := validHeaderRule(rule); err != nil {
+			if strict {
+				return nil, err
+			}
+			continue
+		}
+		list = append(list, rule)
+	}
+	return list, nil
+}
+
+func validHeaderRule(rule HeaderRule) error {
+	if rule.Op != HeaderRuleSet && rule.Op != HeaderRuleAppend && rule.Op != HeaderRuleDel {
+		return errors.Errorf("unsupported header rule op %s", rule.Op)
+	}
+	if rule.Name == "" || strings.ContainsAny(rule.Name, " :\r\n") {
+		return errors.Errorf("invalid header name %q", rule.Name)
+	}
+	if strings.ContainsAny(rule.Value, "\r\n") {
+		return errors.Errorf("invalid header value of %s", rule.Name)
+	}
+	return nil
+}
+
+// NormalizeHeaderRules 旧格式转为JSON保存
+func NormalizeHeaderRules(rules string) (string, error) {
+	if strings.TrimSpace(rules) == "" || isJsonRules(rules) {
+		return rules, nil
+	}
+	list, err := ParseHeaderRules(rules)
+	if err != nil {
+		return "", err
+	}
+	data, err := json.Marshal(list)
+	return string(data), err
+}
+
+// ApplyHeaderRules 按规则修改header，变量值中的换行会被去掉
+func ApplyHeaderRules(header http.Header, rules []HeaderRule, lookup VarLookup) {
+	for _, rule := range rules {
+		value := strings.NewReplacer("\r", "", "\n", "").Replace(Interpolate(rule.Value, lookup))
+		switch rule.Op {
+		case HeaderRuleSet:
+			header.Set(rule.Name, value)
+		case HeaderRuleAppend:
+			header.Add(rule.Name, value)
+		case HeaderRuleDel:
+			header.Del(rule.Name)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package public
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHttpsRedirectURL(t *testing.T) {
+	cases := []struct {
+		target string
+		host   string
+		port   string
+		want   string
+	}{
+		{"/abc/def?a=1&b=2", "www.test.com:8080", "4433", "https://www.test.com:4433/abc/def?a=1&b=2"},
+		{"/abc", "www.test.com", "443", "https://www.test.com/abc"},
+		{"/a%2Fb?x=%20", "127.0.0.1:8080", "", "https://127.0.0.1/a%2Fb?x=%20"},
+		{"/", "[::1]:8080", "4433", "https://[::1]:4433/"},
+	}
+	for _, item := range cases {
+		r := httptest.NewRequest(http.MethodGet, item.target, nil)
+		r.Host = item.host
+		if got := HttpsRedirectURL(r, item.port); got != item.want {
+			t.Errorf("HttpsRedirectURL(%s, %s)=%s, want %s", item.host+item.target, item.port, got, item.want)
+		}
+	}
+}
+
+func TestHttpsRedirectCode(t *testing.T) {
+	if HttpsRedirectCode(http.MethodGet) != http.StatusMovedPermanently {
+		t.Fatal("GET should use 301")
+	}
+	if HttpsRedirectCode(http.MethodPost) != http.StatusPermanentRedirect {
+		t.Fatal("POST should use 308")
+	}
+}
+
+func TestHstsHeaderValue(t *testing.T) {
+	if v := HstsHeaderValue(0, true); v != "" {
+		t.Fatalf("unexpected %s", v)
+	}
+	if v := HstsHeaderValue(31536000, true); v != "max-age=31536000; includeSubDomains" {
+		t.Fatalf("unexpected %s", v)
+	}
+}
+
+func TestIsHttpsRequest(t *testing.T) {
+	trusted, err := ParseTrustedProxies([]string{"10.0.0.0/8", "192.168.1.10", "::1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ParseTrustedProxies([]string{"10.0.0.300"}); err == nil {
+		t.Fatal("expect error for invalid proxy")
+	}
+	cases := []struct {
+		remoteAddr string
+		proto      string
+		trusted    []*net.IPNet
+		want       bool
+	}{
+		{"10.1.2.3:5000", "https", trusted, true},
+		{"192.168.1.10:5000", "HTTPS", trusted, true},
+		{"[::1]:5000", "https", trusted, true},
+		{"10.1.2.3:5000", "http", trusted, false},
+		{"192.168.1.11:5000", "https", trusted, false}, //非受信任地址伪造的header不生效
+		{"10.1.2.3:5000", "https", nil, false},
+	}
+	for _, item := range cases {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.RemoteAddr = item.remoteAddr
+		r.Header.Set("X-Forwarded-Proto", item.proto)
+		if got := IsHttpsRequest(r, item.trusted); got != item.want {
+			t.Errorf("IsHttpsRequest(%s, %s)=%v, want %v", item.remoteAddr, item.proto, got, item.want)
+		}
+	}
+	r := httptest.NewRequest(http.MethodGet, "https://www.test.com/", nil)
+	if !IsHttpsRequest(r, nil) {
+		t.Error("tls request should be https")
+	}
+}
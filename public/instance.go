@@ -0,0 +1,82 @@
+package public
+
+import (
+	"encoding/json"
+	"github.com/garyburd/redigo/redis"
+	"sort"
+	"time"
+)
+
+const (
+	InstanceHeartbeatInterval = 5 * time.Second
+	// 超过该时长没有心跳视为失联
+	InstanceStaleTimeout = 3 * InstanceHeartbeatInterval
+	// 失联超过该时长后从列表中移除
+	InstanceExpireTimeout = time.Hour
+)
+
+// InstanceInfo 代理实例信息
+type InstanceInfo struct {
+	InstanceID      string `json:"instance_id"`
+	Hostname        string `json:"hostname"`
+	Pid             int    `json:"pid"`
+	HttpAddr        string `json:"http_addr"`
+	HttpsAddr       string `json:"https_addr"`
+	AdminAddr       string `json:"admin_addr"`
+	StartTime       int64  `json:"start_time"`
+	Heartbeat       int64  `json:"heartbeat"`
+	ServiceNum      int    `json:"service_num"`
+	ServiceRevision string `json:"service_revision"`
+	AppNum          int    `json:"app_num"`
+	AppRevision     string `json:"app_revision"`
+	Stale           bool   `json:"stale"`
+}
+
+// SaveInstance 写入实例心跳
+func SaveInstance(info *InstanceInfo) error {
+	info.Heartbeat = time.Now().Unix()
+	data, err := json.Marshal(info)
+	if err != nil {
+		return err
+	}
+	_, err = RedisConfDo("HSET", RedisInstanceKey, info.InstanceID, string(data))
+	return err
+}
+
+// RemoveInstance 实例正常退出时注销
+func RemoveInstance(instanceID string) error {
+	_, err := RedisConfDo("HDEL", RedisInstanceKey, instanceID)
+	return err
+}
+
+// GetInstanceList 返回所有实例，标记失联实例并清理过期实例
+func GetInstanceList() ([]*InstanceInfo, error) {
+	values, err := redis.StringMap(RedisConfDo("HGETALL", RedisInstanceKey))
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	list := []*InstanceInfo{}
+	expired := redis.Args{}.Add(RedisInstanceKey)
+	for instanceID, value := range values {
+		info := &InstanceInfo{}
+		if err := json.Unmarshal([]byte(value), info); err != nil {
+			expired = expired.Add(instanceID)
+			continue
+		}
+		lastHeartbeat := time.Unix(info.Heartbeat, 0)
+		if now.Sub(lastHeartbeat) > InstanceExpireTimeout {
+			expired = expired.Add(instanceID)
+			continue
+		}
+		info.Stale = now.Sub(lastHeartbeat) > InstanceStaleTimeout
+		list = append(list, info)
+	}
+	if len(expired) > 1 {
+		RedisConfDo("HDEL", expired...)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].StartTime < list[j].StartTime
+	})
+	return list, nil
+}
@@ -0,0 +1,106 @@
+package public
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"strconv"
+)
+
+const MetricsNamespace = "gateway"
+
+var (
+	MetricsRequestTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: MetricsNamespace,
+		Name:      "http_requests_total",
+		Help:      "代理请求总数",
+	}, []string{"service", "app", "code_class", "upstream"})
+
+	MetricsRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: MetricsNamespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "代理请求耗时",
+		Buckets:   []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10},
+	}, []string{"service", "app", "code_class", "upstream"})
+
+	MetricsResponseSize = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: MetricsNamespace,
+		Name:      "http_response_size_bytes",
+		Help:      "代理响应体大小",
+		Buckets:   prometheus.ExponentialBuckets(128, 4, 8),
+	}, []string{"service", "app", "code_class", "upstream"})
+
+	MetricsActiveConnections = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: MetricsNamespace,
+		Name:      "active_connections",
+		Help:      "当前客户端连接数",
+	}, []string{"listener"})
+
+	MetricsFlowLimitRejected = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: MetricsNamespace,
+		Name:      "flow_limit_rejected_total",
+		Help:      "限流拒绝的请求数",
+	}, []string{"service", "app", "type"})
+
+	MetricsWebsocketConnections = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: MetricsNamespace,
+		Name:      "websocket_connections",
+		Help:      "当前websocket连接数",
+	}, []string{"service"})
+)
+
+// 限流类型
+const (
+	FlowLimitTypeService  = "service"
+	FlowLimitTypeClientIP = "client_ip"
+	FlowLimitTypeApp      = "app"
+	FlowLimitTypeQuota    = "app_quota"
+	FlowLimitTypeWsConn   = "websocket_conn"
+)
+
+// GetStatusClass 将状态码转换为 2xx/4xx/5xx
+func GetStatusClass(code int) string {
+	if code < 100 || code > 599 {
+		return "unknown"
+	}
+	return strconv.Itoa(code/100) + "xx"
+}
+
+// UpstreamHealthCollector 采集时读取负载均衡的探活结果
+type UpstreamHealthCollector struct {
+	desc   *prometheus.Desc
+	health func() map[string]map[string]bool
+}
+
+func NewUpstreamHealthCollector(health func() map[string]map[string]bool) *UpstreamHealthCollector {
+	return &UpstreamHealthCollector{
+		desc: prometheus.NewDesc(prometheus.BuildFQName(MetricsNamespace, "", "upstream_up"),
+			"下游节点探活状态 1=存活", []string{"service", "upstream"}, nil),
+		health: health,
+	}
+}
+
+func (u *UpstreamHealthCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- u.desc
+}
+
+func (u *UpstreamHealthCollector) Collect(ch chan<- prometheus.Metric) {
+	for serviceName, nodes := range u.health() {
+		for node, up := range nodes {
+			value := 0.0
+			if up {
+				value = 1
+			}
+			ch <- prometheus.MustNewConstMetric(u.desc, prometheus.GaugeValue, value, serviceName, node)
+		}
+	}
+}
+
+func init() {
+	prometheus.MustRegister(
+		MetricsRequestTotal,
+		MetricsRequestDuration,
+		MetricsResponseSize,
+		MetricsActiveConnections,
+		MetricsFlowLimitRejected,
+		MetricsWebsocketConnections,
+	)
+}
@@ -0,0 +1,363 @@
+package public
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"github.com/dgrijalva/jwt-go"
+	"github.com/pkg/errors"
+	"golang.org/x/sync/singleflight"
+	"math/big"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultJwksRefresh    = 300 * time.Second
+	DefaultJwksMinRefresh = 10 * time.Second
+)
+
+// OidcConf 外部身份提供方的token校验配置
+type OidcConf struct {
+	Issuer          string
+	Audience        []string
+	JwksURL         string
+	StaticKeys      string
+	RefreshInterval time.Duration
+	RequiredClaims  map[string]string
+}
+
+var JwksManagerHandler *JwksManager
+
+// JwksManager 按JWKS地址(或静态公钥)缓存公钥集合
+type JwksManager struct {
+	KeySetMap map[string]*KeySet
+	Locker    sync.RWMutex
+	Client    *http.Client
+}
+
+// KeySet 一组公钥, kid => key
+type KeySet struct {
+	URL         string
+	Keys        map[string]interface{}
+	Refresh     time.Duration
+	LastFetch   time.Time //最近一次成功拉取
+	LastAttempt time.Time //最近一次拉取，用于失败后限流
+	Locker      sync.RWMutex
+	client      *http.Client
+	group       singleflight.Group
+	lastErr     error
+}
+
+func NewJwksManager() *JwksManager {
+	return &JwksManager{
+		KeySetMap: map[string]*KeySet{},
+		Locker:    sync.RWMutex{},
+		Client:    &http.Client{Timeout: 5 * time.Second},
+	}
+}
+
+func init() {
+	JwksManagerHandler = NewJwksManager()
+}
+
+// GetKeySet 获取配置对应的公钥集合, JWKS优先于静态公钥
+// JWKS按地址和刷新间隔缓存，共用地址的服务各自按自己的间隔刷新
+// JWKS首次拉取失败时仍保留该集合，之后的拉取按DefaultJwksMinRefresh限流，避免每个请求都访问身份提供方
+func (m *JwksManager) GetKeySet(conf *OidcConf) (*KeySet, error) {
+	refresh := conf.RefreshInterval
+	if refresh <= 0 {
+		refresh = DefaultJwksRefresh
+	}
+	name := fmt.Sprintf("%s#%d", conf.JwksURL, refresh/time.Second)
+	if conf.JwksURL == "" {
+		name = "static_" + MD5(conf.StaticKeys)
+	}
+	m.Locker.RLock()
+	keySet, ok := m.KeySetMap[name]
+	m.Locker.RUnlock()
+	if ok {
+		return keySet, nil
+	}
+
+	if conf.JwksURL == "" {
+		keys, err := ParsePemPublicKeys(conf.StaticKeys)
+		if err != nil {
+			return nil, err
+		}
+		keySet = &KeySet{Keys: keys}
+	} else {
+		keySet = &KeySet{URL: conf.JwksURL, Refresh: refresh, Keys: map[string]interface{}{}, client: m.Client}
+	}
+
+	m.Locker.Lock()
+	if exist, ok := m.KeySetMap[name]; ok {
+		keySet = exist
+	} else {
+		m.KeySetMap[name] = keySet
+	}
+	m.Locker.Unlock()
+	if keySet.URL != "" {
+		if err := keySet.refresh(); err != nil {
+			return nil, err
+		}
+	}
+	return keySet, nil
+}
+
+// GetKeys 按kid返回候选公钥, 过期或未知kid时重新拉取JWKS
+func (k *KeySet) GetKeys(kid string) []interface{} {
+	if k.URL != "" {
+		k.Locker.RLock()
+		_, known := k.Keys[kid]
+		expired := time.Since(k.LastFetch) > k.Refresh
+		k.Locker.RUnlock()
+		if expired || (!known && kid != "") {
+			//拉取失败时继续使用旧的公钥
+			k.refresh()
+		}
+	}
+
+	k.Locker.RLock()
+	defer k.Locker.RUnlock()
+	if key, ok := k.Keys[kid]; ok && kid != "" {
+		return []interface{}{key}
+	}
+	list := []interface{}{}
+	for _, key := range k.Keys {
+		list = append(list, key)
+	}
+	return list
+}
+
+// refresh 并发的拉取合并为一次，距上次拉取不足DefaultJwksMinRefresh时不拉取，返回最近一次拉取的错误
+func (k *KeySet) refresh() error {
+	_, err, _ := k.group.Do("fetch", func() (interface{}, error) {
+		k.Locker.RLock()
+		throttled := !k.LastAttempt.IsZero() && time.Since(k.LastAttempt) < DefaultJwksMinRefresh
+		lastErr := k.lastErr
+		k.Locker.RUnlock()
+		if throttled {
+			return nil, lastErr
+		}
+		//请求身份提供方时不持有锁，不阻塞使用旧公钥的请求
+		keys, err := k.fetch()
+		k.Locker.Lock()
+		defer k.Locker.Unlock()
+		k.LastAttempt = time.Now()
+		k.lastErr = err
+		if err != nil {
+			return nil, err
+		}
+		k.Keys = keys
+		k.LastFetch = k.LastAttempt
+		return nil, nil
+	})
+	return err
+}
+
+func (k *KeySet) fetch() (map[string]interface{}, error) {
+	resp, err := k.client.Get(k.URL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.New(fmt.Sprintf("fetch jwks %s status %v", k.URL, resp.StatusCode))
+	}
+	jwks := struct {
+		Keys []JsonWebKey `json:"keys"`
+	}{}
+	if err := json.NewDecoder(resp.Body).Decode(&jwks); err != nil {
+		return nil, err
+	}
+	keys := map[string]interface{}{}
+	for _, item := range jwks.Keys {
+		if item.Use != "" && item.Use != "sig" {
+			continue
+		}
+		key, err := item.PublicKey()
+		if err != nil {
+			continue
+		}
+		keys[item.Kid] = key
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("jwks has no valid key")
+	}
+	return keys, nil
+}
+
+// JsonWebKey RFC7517 公钥
+type JsonWebKey struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func (j *JsonWebKey) PublicKey() (interface{}, error) {
+	switch j.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(j.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := base64.RawURLEncoding.DecodeString(j.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch j.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, errors.New("unsupported crv " + j.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(j.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := base64.RawURLEncoding.DecodeString(j.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}, nil
+	}
+	return nil, errors.New("unsupported kty " + j.Kty)
+}
+
+// ParsePemPublicKeys 解析PEM格式的公钥或证书, 支持多个拼接
+func ParsePemPublicKeys(pemStr string) (map[string]interface{}, error) {
+	keys := map[string]interface{}{}
+	rest := []byte(pemStr)
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		switch block.Type {
+		case "PUBLIC KEY":
+			key, err := x509.ParsePKIXPublicKey(block.Bytes)
+			if err != nil {
+				return nil, err
+			}
+			keys[fmt.Sprintf("static_%d", len(keys))] = key
+		case "CERTIFICATE":
+			cert, err := x509.ParseCertificate(block.Bytes)
+			if err != nil {
+				return nil, err
+			}
+			keys[fmt.Sprintf("static_%d", len(keys))] = cert.PublicKey
+		}
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("no pem public key found")
+	}
+	return keys, nil
+}
+
+// OidcDecode 使用外部身份提供方的公钥校验token并返回claims
+func OidcDecode(tokenString string, conf *OidcConf) (jwt.MapClaims, error) {
+	keySet, err := JwksManagerHandler.GetKeySet(conf)
+	if err != nil {
+		return nil, err
+	}
+	unverified, _, err := new(jwt.Parser).ParseUnverified(tokenString, jwt.MapClaims{})
+	if err != nil {
+		return nil, err
+	}
+	kid, _ := unverified.Header["kid"].(string)
+
+	var lastErr error = errors.New("no public key matched")
+	for _, key := range keySet.GetKeys(kid) {
+		pubKey := key
+		claims := jwt.MapClaims{}
+		_, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+			switch token.Method.(type) {
+			case *jwt.SigningMethodRSA, *jwt.SigningMethodRSAPSS, *jwt.SigningMethodECDSA:
+				return pubKey, nil
+			}
+			return nil, errors.New(fmt.Sprintf("unexpected signing method %v", token.Header["alg"]))
+		})
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if err := VerifyOidcClaims(claims, conf); err != nil {
+			return nil, err
+		}
+		return claims, nil
+	}
+	return nil, lastErr
+}
+
+// VerifyOidcClaims 校验iss、aud以及必须的claim
+func VerifyOidcClaims(claims jwt.MapClaims, conf *OidcConf) error {
+	if conf.Issuer != "" && !claims.VerifyIssuer(conf.Issuer, true) {
+		return errors.New("token issuer not match")
+	}
+	if len(conf.Audience) > 0 {
+		audList := []string{}
+		switch aud := claims["aud"].(type) {
+		case string:
+			audList = append(audList, aud)
+		case []interface{}:
+			for _, item := range aud {
+				if str, ok := item.(string); ok {
+					audList = append(audList, str)
+				}
+			}
+		}
+		matched := false
+		for _, aud := range conf.Audience {
+			if InStringSlice(audList, aud) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return errors.New("token audience not match")
+		}
+	}
+	for name, value := range conf.RequiredClaims {
+		if ClaimString(claims, name) != value {
+			return errors.New(fmt.Sprintf("token claim %s not match", name))
+		}
+	}
+	return nil
+}
+
+// ClaimString 读取claim并转为字符串, 不存在时返回空
+func ClaimString(claims jwt.MapClaims, name string) string {
+	value, ok := claims[name]
+	if !ok || value == nil {
+		return ""
+	}
+	switch v := value.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	return fmt.Sprint(value)
+}
@@ -0,0 +1,186 @@
+package public
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"github.com/dgrijalva/jwt-go"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newJwksServer(t *testing.T, kid string, key *rsa.PrivateKey) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys": []JsonWebKey{{
+				Kid: kid,
+				Kty: "RSA",
+				Use: "sig",
+				Alg: "RS256",
+				N:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}},
+		})
+	}))
+}
+
+func signRS256(t *testing.T, kid string, key *rsa.PrivateKey, claims jwt.MapClaims) string {
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = kid
+	str, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return str
+}
+
+func TestOidcDecode(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := newJwksServer(t, "kid_1", key)
+	defer srv.Close()
+
+	conf := &OidcConf{
+		Issuer:         "https://idp.example.com",
+		Audience:       []string{"gateway"},
+		JwksURL:        srv.URL,
+		RequiredClaims: map[string]string{"tenant": "app_id_a"},
+	}
+	claims := jwt.MapClaims{
+		"iss":    "https://idp.example.com",
+		"aud":    []string{"other", "gateway"},
+		"exp":    time.Now().Add(time.Minute).Unix(),
+		"tenant": "app_id_a",
+	}
+	out, err := OidcDecode(signRS256(t, "kid_1", key, claims), conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ClaimString(out, "tenant") != "app_id_a" {
+		t.Fatalf("unexpected claims %v", out)
+	}
+
+	claims["tenant"] = "app_id_b"
+	if _, err := OidcDecode(signRS256(t, "kid_1", key, claims), conf); err == nil {
+		t.Fatal("required claim mismatch should fail")
+	}
+
+	otherKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	claims["tenant"] = "app_id_a"
+	if _, err := OidcDecode(signRS256(t, "kid_1", otherKey, claims), conf); err == nil {
+		t.Fatal("token signed by unknown key should fail")
+	}
+
+	hmacToken, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte("secret"))
+	if _, err := OidcDecode(hmacToken, conf); err == nil {
+		t.Fatal("hmac token should fail")
+	}
+}
+
+func TestKeySetRefresh(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fetches int32
+	fail := int32(1)
+	jwks := newJwksServer(t, "kid_1", key)
+	defer jwks.Close()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&fetches, 1)
+		time.Sleep(50 * time.Millisecond)
+		if atomic.LoadInt32(&fail) == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		resp, err := http.Get(jwks.URL)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer resp.Body.Close()
+		io.Copy(w, resp.Body)
+	}))
+	defer srv.Close()
+
+	manager := NewJwksManager()
+	conf := &OidcConf{JwksURL: srv.URL}
+	if _, err := manager.GetKeySet(conf); err == nil {
+		t.Fatal("initial fetch should fail")
+	}
+	//失败后限流，不会每个请求都访问身份提供方
+	for i := 0; i < 5; i++ {
+		keySet, err := manager.GetKeySet(conf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(keySet.GetKeys("kid_1")) != 0 {
+			t.Fatal("no key expected")
+		}
+	}
+	if n := atomic.LoadInt32(&fetches); n != 1 {
+		t.Fatalf("failed fetch should be throttled, fetches=%d", n)
+	}
+
+	//过期时并发请求只拉取一次
+	atomic.StoreInt32(&fail, 0)
+	keySet, _ := manager.GetKeySet(conf)
+	keySet.Locker.Lock()
+	keySet.LastAttempt = time.Now().Add(-time.Hour)
+	keySet.Locker.Unlock()
+	wg := sync.WaitGroup{}
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if len(keySet.GetKeys("kid_1")) != 1 {
+				t.Error("key kid_1 expected")
+			}
+		}()
+	}
+	wg.Wait()
+	if n := atomic.LoadInt32(&fetches); n != 2 {
+		t.Fatalf("concurrent refresh should fetch once, fetches=%d", n)
+	}
+}
+
+func TestJwksManagerRefreshInterval(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := newJwksServer(t, "kid_1", key)
+	defer srv.Close()
+
+	//同一地址不同刷新间隔的服务各自缓存
+	manager := NewJwksManager()
+	fast, err := manager.GetKeySet(&OidcConf{JwksURL: srv.URL, RefreshInterval: time.Minute})
+	if err != nil {
+		t.Fatal(err)
+	}
+	slow, err := manager.GetKeySet(&OidcConf{JwksURL: srv.URL, RefreshInterval: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fast == slow || fast.Refresh != time.Minute || slow.Refresh != time.Hour {
+		t.Fatalf("unexpected key sets %v %v", fast.Refresh, slow.Refresh)
+	}
+	same, _ := manager.GetKeySet(&OidcConf{JwksURL: srv.URL, RefreshInterval: time.Minute})
+	if same != fast {
+		t.Fatal("same url and interval should share key set")
+	}
+	//未配置时使用默认间隔
+	def, _ := manager.GetKeySet(&OidcConf{JwksURL: srv.URL})
+	if def.Refresh != DefaultJwksRefresh {
+		t.Fatalf("unexpected default refresh %v", def.Refresh)
+	}
+}
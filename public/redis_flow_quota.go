@@ -0,0 +1,109 @@
+package public
+
+import (
+	"FGateWay/golang_common/lib"
+	"fmt"
+	"github.com/garyburd/redigo/redis"
+	"time"
+)
+
+// 原子地检查并消耗日/月配额，超出配额时不计数
+// KEYS[1]=日配额key KEYS[2]=月配额key
+// ARGV[1]=日限制 ARGV[2]=日重置时间 ARGV[3]=月限制 ARGV[4]=月重置时间
+var quotaConsumeScript = redis.NewScript(2, `
+local day = tonumber(redis.call('GET', KEYS[1]) or '0')
+local month = tonumber(redis.call('GET', KEYS[2]) or '0')
+local dayLimit = tonumber(ARGV[1])
+local monthLimit = tonumber(ARGV[3])
+if (dayLimit > 0 and day >= dayLimit) or (monthLimit > 0 and month >= monthLimit) then
+	return {0, day, month}
+end
+if dayLimit > 0 then
+	day = redis.call('INCR', KEYS[1])
+	if day == 1 then
+		redis.call('EXPIREAT', KEYS[1], ARGV[2])
+	end
+end
+if monthLimit > 0 then
+	month = redis.call('INCR', KEYS[2])
+	if month == 1 then
+		redis.call('EXPIREAT', KEYS[2], ARGV[4])
+	end
+end
+return {1, day, month}
+`)
+
+// QuotaResult 配额使用情况
+type QuotaResult struct {
+	Limit     int64 `json:"limit"`
+	Used      int64 `json:"used"`
+	Remaining int64 `json:"remaining"`
+	ResetAt   int64 `json:"reset_at"`
+}
+
+func newQuotaResult(limit, used int64, resetAt time.Time) *QuotaResult {
+	remaining := limit - used
+	if remaining < 0 {
+		remaining = 0
+	}
+	return &QuotaResult{Limit: limit, Used: used, Remaining: remaining, ResetAt: resetAt.Unix()}
+}
+
+func quotaLocation() *time.Location {
+	if lib.TimeLocation != nil {
+		return lib.TimeLocation
+	}
+	return time.Local
+}
+
+// GetDayResetTime 返回配置时区下一个零点
+func GetDayResetTime(t time.Time) time.Time {
+	t = t.In(quotaLocation())
+	return time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+}
+
+// GetMonthResetTime 返回配置时区下个月一号零点
+func GetMonthResetTime(t time.Time) time.Time {
+	t = t.In(quotaLocation())
+	return time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+}
+
+func GetQuotaDayKey(appID string, t time.Time) string {
+	return fmt.Sprintf("%s_%s_%s", RedisQuotaDayKey, t.In(quotaLocation()).Format("20060102"), appID)
+}
+
+func GetQuotaMonthKey(appID string, t time.Time) string {
+	return fmt.Sprintf("%s_%s_%s", RedisQuotaMonthKey, t.In(quotaLocation()).Format("200601"), appID)
+}
+
+// ConsumeAppQuota 消耗一次租户配额，qpd/monthQuota为0表示不限制
+func ConsumeAppQuota(appID string, qpd, monthQuota int64) (bool, *QuotaResult, *QuotaResult, error) {
+	now := time.Now()
+	dayReset := GetDayResetTime(now)
+	monthReset := GetMonthResetTime(now)
+
+	c, err := lib.RedisConnFactory("default")
+	if err != nil {
+		return false, nil, nil, err
+	}
+	defer c.Close()
+	values, err := redis.Int64s(quotaConsumeScript.Do(c,
+		GetQuotaDayKey(appID, now), GetQuotaMonthKey(appID, now),
+		qpd, dayReset.Unix(), monthQuota, monthReset.Unix()))
+	if err != nil {
+		return false, nil, nil, err
+	}
+	return values[0] == 1, newQuotaResult(qpd, values[1], dayReset), newQuotaResult(monthQuota, values[2], monthReset), nil
+}
+
+// GetAppQuota 查询租户当前配额使用情况
+func GetAppQuota(appID string, qpd, monthQuota int64) (*QuotaResult, *QuotaResult, error) {
+	now := time.Now()
+	values, err := redis.Values(RedisConfDo("MGET", GetQuotaDayKey(appID, now), GetQuotaMonthKey(appID, now)))
+	if err != nil {
+		return nil, nil, err
+	}
+	dayUsed, _ := redis.Int64(values[0], nil)
+	monthUsed, _ := redis.Int64(values[1], nil)
+	return newQuotaResult(qpd, dayUsed, GetDayResetTime(now)), newQuotaResult(monthQuota, monthUsed, GetMonthResetTime(now)), nil
+}
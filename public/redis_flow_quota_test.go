@@ -0,0 +1,28 @@
+package public
+
+import (
+	"FGateWay/golang_common/lib"
+	"testing"
+	"time"
+)
+
+func TestQuotaResetTime(t *testing.T) {
+	loc, _ := time.LoadLocation("Asia/Chongqing")
+	lib.TimeLocation = loc
+	defer func() { lib.TimeLocation = nil }()
+
+	//UTC 2026-01-31 16:30 即东八区 2026-02-01 00:30
+	now := time.Date(2026, 1, 31, 16, 30, 0, 0, time.UTC)
+	if day := GetDayResetTime(now); !day.Equal(time.Date(2026, 2, 2, 0, 0, 0, 0, loc)) {
+		t.Fatalf("unexpected day reset %v", day)
+	}
+	if month := GetMonthResetTime(now); !month.Equal(time.Date(2026, 3, 1, 0, 0, 0, 0, loc)) {
+		t.Fatalf("unexpected month reset %v", month)
+	}
+	if key := GetQuotaDayKey("app_id_a", now); key != "flow_quota_day_20260201_app_id_a" {
+		t.Fatalf("unexpected day key %v", key)
+	}
+	if key := GetQuotaMonthKey("app_id_a", now); key != "flow_quota_month_202602_app_id_a" {
+		t.Fatalf("unexpected month key %v", key)
+	}
+}
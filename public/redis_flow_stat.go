@@ -0,0 +1,293 @@
+package public
+
+import (
+	"FGateWay/golang_common/lib"
+	"fmt"
+	"github.com/garyburd/redigo/redis"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	FlowStatRange1h  = "1h"
+	FlowStatRange24h = "24h"
+	FlowStatRange7d  = "7d"
+
+	// 粗粒度时间桶：5分钟桶保留2天，小时桶保留8天
+	flowStatMinuteBucket = 5 * time.Minute
+	flowStatMinuteExpire = 86400 * 2
+	flowStatHourExpire   = 86400 * 8
+)
+
+// 耗时直方图的桶上界，单位ms，最后一个桶为超出上界的部分
+var FlowStatLatencyBuckets = [...]int64{5, 10, 25, 50, 100, 250, 500, 1000, 2500, 5000, 10000}
+
+// 一个统计周期内的增量
+type flowStatDelta struct {
+	status      [6]int64
+	upstreamErr int64
+	latency     [len(FlowStatLatencyBuckets) + 1]int64
+}
+
+// FlowStatItem 一个时间桶的统计结果，耗时单位ms
+type FlowStatItem struct {
+	Time        int64   `json:"time"`
+	Total       int64   `json:"total"`
+	Status2xx   int64   `json:"status_2xx"`
+	Status3xx   int64   `json:"status_3xx"`
+	Status4xx   int64   `json:"status_4xx"`
+	Status5xx   int64   `json:"status_5xx"`
+	UpstreamErr int64   `json:"upstream_err"`
+	P50         int64   `json:"p50"`
+	P90         int64   `json:"p90"`
+	P99         int64   `json:"p99"`
+	latency     []int64 `json:"-"`
+}
+
+// FlowStatRange 时间范围内的统计，Interval为每个点的秒数
+type FlowStatRange struct {
+	Range    string         `json:"range"`
+	Interval int64          `json:"interval"`
+	Summary  FlowStatItem   `json:"summary"`
+	List     []FlowStatItem `json:"list"`
+}
+
+// Record 记录一次请求的状态码、耗时以及是否为下游错误，与Increase成对调用
+func (o *RedisFlowCountService) Record(status int, latency time.Duration, upstreamErr bool) {
+	defer atomic.AddInt64(&o.Inflight, -1)
+	class := status / 100
+	if class < 1 || class > 5 {
+		class = 0
+	}
+	atomic.AddInt64(&o.stat.status[class], 1)
+	if upstreamErr {
+		atomic.AddInt64(&o.stat.upstreamErr, 1)
+	}
+	ms := latency.Milliseconds()
+	index := len(FlowStatLatencyBuckets)
+	for i, bound := range FlowStatLatencyBuckets {
+		if ms <= bound {
+			index = i
+			break
+		}
+	}
+	atomic.AddInt64(&o.stat.latency[index], 1)
+}
+
+// 取出本周期的增量并清零，没有增量时返回nil
+func (o *RedisFlowCountService) swapStat() *flowStatDelta {
+	delta := &flowStatDelta{}
+	empty := true
+	for i := range delta.status {
+		delta.status[i] = atomic.SwapInt64(&o.stat.status[i], 0)
+		if delta.status[i] > 0 {
+			empty = false
+		}
+	}
+	delta.upstreamErr = atomic.SwapInt64(&o.stat.upstreamErr, 0)
+	for i := range delta.latency {
+		delta.latency[i] = atomic.SwapInt64(&o.stat.latency[i], 0)
+	}
+	if empty && delta.upstreamErr == 0 {
+		return nil
+	}
+	return delta
+}
+
+// 是否有尚未写入的统计增量
+func (o *RedisFlowCountService) hasPendingStat() bool {
+	for i := range o.stat.status {
+		if atomic.LoadInt64(&o.stat.status[i]) > 0 {
+			return true
+		}
+	}
+	return atomic.LoadInt64(&o.stat.upstreamErr) > 0
+}
+
+// 写入失败时把增量还回去
+func (o *RedisFlowCountService) restoreStat(delta *flowStatDelta) {
+	for i, v := range delta.status {
+		atomic.AddInt64(&o.stat.status[i], v)
+	}
+	atomic.AddInt64(&o.stat.upstreamErr, delta.upstreamErr)
+	for i, v := range delta.latency {
+		atomic.AddInt64(&o.stat.latency[i], v)
+	}
+}
+
+func (o *RedisFlowCountService) GetStatMinuteKey(t time.Time) string {
+	t = t.In(lib.TimeLocation).Truncate(flowStatMinuteBucket)
+	return fmt.Sprintf("%s_%s_%s", RedisFlowStatMinuteKey, t.Format("200601021504"), o.AppID)
+}
+
+func (o *RedisFlowCountService) GetStatHourKey(t time.Time) string {
+	return fmt.Sprintf("%s_%s_%s", RedisFlowStatHourKey, t.In(lib.TimeLocation).Format("2006010215"), o.AppID)
+}
+
+// 在pipeline中写入分钟桶和小时桶
+func (o *RedisFlowCountService) sendStat(c redis.Conn, t time.Time, delta *flowStatDelta) {
+	var total int64
+	for _, v := range delta.status {
+		total += v
+	}
+	for _, item := range []struct {
+		key    string
+		expire int
+	}{
+		{o.GetStatMinuteKey(t), flowStatMinuteExpire},
+		{o.GetStatHourKey(t), flowStatHourExpire},
+	} {
+		c.Send("HINCRBY", item.key, "total", total)
+		for i, v := range delta.status {
+			if v > 0 {
+				c.Send("HINCRBY", item.key, strconv.Itoa(i)+"xx", v)
+			}
+		}
+		for i, v := range delta.latency {
+			if v > 0 {
+				c.Send("HINCRBY", item.key, "lat_"+strconv.Itoa(i), v)
+			}
+		}
+		if delta.upstreamErr > 0 {
+			c.Send("HINCRBY", item.key, "upstream_err", delta.upstreamErr)
+		}
+		c.Send("EXPIRE", item.key, item.expire)
+	}
+}
+
+// GetStatRange 查询最近1h/24h/7d的统计，1h按5分钟聚合，其余按小时聚合
+func (o *RedisFlowCountService) GetStatRange(rangeType string, now time.Time) (*FlowStatRange, error) {
+	times, interval, err := GetFlowStatRangeTimes(rangeType, now)
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]string, len(times))
+	for i, t := range times {
+		if interval == flowStatMinuteBucket {
+			keys[i] = o.GetStatMinuteKey(t)
+		} else {
+			keys[i] = o.GetStatHourKey(t)
+		}
+	}
+
+	c, err := lib.RedisConnFactory("default")
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close()
+	for _, key := range keys {
+		c.Send("HGETALL", key)
+	}
+	if err := c.Flush(); err != nil {
+		return nil, err
+	}
+
+	out := &FlowStatRange{
+		Range:    rangeType,
+		Interval: int64(interval / time.Second),
+		Summary:  FlowStatItem{Time: times[0].Unix(), latency: make([]int64, len(FlowStatLatencyBuckets)+1)},
+		List:     []FlowStatItem{},
+	}
+	for i := range keys {
+		values, err := redis.Int64Map(c.Receive())
+		if err != nil {
+			return nil, err
+		}
+		item := ParseFlowStatItem(times[i].Unix(), values)
+		out.Summary.merge(item)
+		out.List = append(out.List, item)
+	}
+	out.Summary.calcPercentile()
+	return out, nil
+}
+
+// GetFlowStatRangeTimes 返回时间范围内每个时间桶的起始时间
+func GetFlowStatRangeTimes(rangeType string, now time.Time) ([]time.Time, time.Duration, error) {
+	now = now.In(lib.TimeLocation)
+	var interval time.Duration
+	var num int
+	switch rangeType {
+	case FlowStatRange1h:
+		interval, num = flowStatMinuteBucket, 12
+	case FlowStatRange24h:
+		interval, num = time.Hour, 24
+	case FlowStatRange7d:
+		interval, num = time.Hour, 24*7
+	default:
+		return nil, 0, fmt.Errorf("unsupported range %s", rangeType)
+	}
+	end := now.Truncate(interval)
+	if interval == time.Hour {
+		//Truncate按UTC取整，小时桶需按本地时区取整
+		end = time.Date(now.Year(), now.Month(), now.Day(), now.Hour(), 0, 0, 0, now.Location())
+	}
+	times := make([]time.Time, num)
+	for i := 0; i < num; i++ {
+		times[i] = end.Add(-time.Duration(num-1-i) * interval)
+	}
+	return times, interval, nil
+}
+
+// ParseFlowStatItem 解析redis hash中的统计字段
+func ParseFlowStatItem(unix int64, values map[string]int64) FlowStatItem {
+	item := FlowStatItem{
+		Time:        unix,
+		Total:       values["total"],
+		Status2xx:   values["2xx"],
+		Status3xx:   values["3xx"],
+		Status4xx:   values["4xx"],
+		Status5xx:   values["5xx"],
+		UpstreamErr: values["upstream_err"],
+		latency:     make([]int64, len(FlowStatLatencyBuckets)+1),
+	}
+	for i := range item.latency {
+		item.latency[i] = values["lat_"+strconv.Itoa(i)]
+	}
+	item.calcPercentile()
+	return item
+}
+
+func (item *FlowStatItem) merge(other FlowStatItem) {
+	item.Total += other.Total
+	item.Status2xx += other.Status2xx
+	item.Status3xx += other.Status3xx
+	item.Status4xx += other.Status4xx
+	item.Status5xx += other.Status5xx
+	item.UpstreamErr += other.UpstreamErr
+	for i, v := range other.latency {
+		item.latency[i] += v
+	}
+}
+
+// 根据直方图估算分位数，取所在桶的上界
+func (item *FlowStatItem) calcPercentile() {
+	item.P50 = latencyPercentile(item.latency, 0.5)
+	item.P90 = latencyPercentile(item.latency, 0.9)
+	item.P99 = latencyPercentile(item.latency, 0.99)
+}
+
+func latencyPercentile(latency []int64, p float64) int64 {
+	var total int64
+	for _, v := range latency {
+		total += v
+	}
+	if total == 0 {
+		return 0
+	}
+	target := int64(float64(total)*p + 0.5)
+	if target < 1 {
+		target = 1
+	}
+	var sum int64
+	for i, v := range latency {
+		sum += v
+		if sum >= target {
+			if i < len(FlowStatLatencyBuckets) {
+				return FlowStatLatencyBuckets[i]
+			}
+			break
+		}
+	}
+	return FlowStatLatencyBuckets[len(FlowStatLatencyBuckets)-1]
+}
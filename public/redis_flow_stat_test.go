@@ -0,0 +1,59 @@
+package public
+
+import (
+	"FGateWay/golang_common/lib"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestFlowStatRecord(t *testing.T) {
+	counter := NewRedisFlowCountService(FlowTotal, FlowCountInterval)
+	for i := 0; i < 98; i++ {
+		counter.Record(200, 8*time.Millisecond, false)
+	}
+	counter.Record(404, 80*time.Millisecond, false)
+	counter.Record(502, 3*time.Second, true)
+
+	delta := counter.swapStat()
+	if delta == nil || delta.status[2] != 98 || delta.status[4] != 1 || delta.status[5] != 1 || delta.upstreamErr != 1 {
+		t.Fatalf("unexpected delta %+v", delta)
+	}
+	if counter.swapStat() != nil {
+		t.Fatal("stat should be reset after swap")
+	}
+
+	values := map[string]int64{"total": 100, "upstream_err": delta.upstreamErr}
+	for i, v := range delta.status {
+		values[strconv.Itoa(i)+"xx"] = v
+	}
+	for i, v := range delta.latency {
+		values["lat_"+strconv.Itoa(i)] = v
+	}
+	item := ParseFlowStatItem(0, values)
+	if item.Status2xx != 98 || item.P50 != 10 || item.P90 != 10 || item.P99 != 100 {
+		t.Fatalf("unexpected item %+v", item)
+	}
+}
+
+func TestFlowStatRangeTimes(t *testing.T) {
+	loc, _ := time.LoadLocation("Asia/Chongqing")
+	lib.TimeLocation = loc
+	defer func() { lib.TimeLocation = nil }()
+
+	now := time.Date(2026, 10, 19, 10, 37, 12, 0, loc)
+	times, interval, err := GetFlowStatRangeTimes(FlowStatRange1h, now)
+	if err != nil || interval != 5*time.Minute || len(times) != 12 {
+		t.Fatalf("unexpected 1h range %v %v %v", times, interval, err)
+	}
+	if !times[11].Equal(time.Date(2026, 10, 19, 10, 35, 0, 0, loc)) || !times[0].Equal(time.Date(2026, 10, 19, 9, 40, 0, 0, loc)) {
+		t.Fatalf("unexpected 1h buckets %v %v", times[0], times[11])
+	}
+	times, _, _ = GetFlowStatRangeTimes(FlowStatRange7d, now)
+	if len(times) != 168 || !times[167].Equal(time.Date(2026, 10, 19, 10, 0, 0, 0, loc)) {
+		t.Fatalf("unexpected 7d buckets %v", times[167])
+	}
+	if _, _, err := GetFlowStatRangeTimes("30d", now); err == nil {
+		t.Fatal("unsupported range should fail")
+	}
+}
@@ -0,0 +1,57 @@
+package public
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// 向上游透传剩余超时时间的header，单位ms
+const HeaderRequestTimeout = "X-Request-Timeout-Ms"
+
+type responseControllerKey struct{}
+
+// ResponseControllerHandler 在gin包装ResponseWriter之前保存原始writer的ResponseController，
+// 用于按请求调整读写deadline
+func ResponseControllerHandler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := context.WithValue(r.Context(), responseControllerKey{}, http.NewResponseController(w))
+		h.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// GetResponseController 获取原始writer的ResponseController，未经ResponseControllerHandler包装时返回nil
+func GetResponseController(r *http.Request) *http.ResponseController {
+	rc, _ := r.Context().Value(responseControllerKey{}).(*http.ResponseController)
+	return rc
+}
+
+// GetRequestTimeout 计算请求超时：取服务配置与客户端透传超时中较小的一个，0表示不限制。
+// 客户端透传超时只能缩短服务配置的超时，服务未配置时忽略
+func GetRequestTimeout(r *http.Request, serviceTimeout time.Duration) time.Duration {
+	timeout := serviceTimeout
+	if timeout <= 0 {
+		return 0
+	}
+	if ms, err := strconv.ParseInt(r.Header.Get(HeaderRequestTimeout), 10, 64); err == nil && ms > 0 {
+		if clientTimeout := time.Duration(ms) * time.Millisecond; clientTimeout < timeout {
+			timeout = clientTimeout
+		}
+	}
+	return timeout
+}
+
+// SetRequestTimeoutHeader 按context的deadline写入剩余超时
+func SetRequestTimeoutHeader(req *http.Request) {
+	deadline, ok := req.Context().Deadline()
+	if !ok {
+		req.Header.Del(HeaderRequestTimeout)
+		return
+	}
+	remain := time.Until(deadline).Milliseconds()
+	if remain < 1 {
+		remain = 1
+	}
+	req.Header.Set(HeaderRequestTimeout, strconv.FormatInt(remain, 10))
+}
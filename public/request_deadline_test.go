@@ -0,0 +1,39 @@
+package public
+
+import (
+	"context"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestGetRequestTimeout(t *testing.T) {
+	req := httptest.NewRequest("GET", "/", nil)
+	if timeout := GetRequestTimeout(req, 5*time.Second); timeout != 5*time.Second {
+		t.Fatalf("timeout = %v", timeout)
+	}
+	req.Header.Set(HeaderRequestTimeout, "1500")
+	if timeout := GetRequestTimeout(req, 5*time.Second); timeout != 1500*time.Millisecond {
+		t.Fatalf("timeout = %v", timeout)
+	}
+	//服务未配置超时时不接受客户端指定
+	if timeout := GetRequestTimeout(req, 0); timeout != 0 {
+		t.Fatalf("timeout = %v", timeout)
+	}
+	req.Header.Set(HeaderRequestTimeout, "60000")
+	if timeout := GetRequestTimeout(req, 5*time.Second); timeout != 5*time.Second {
+		t.Fatalf("timeout = %v", timeout)
+	}
+}
+
+func TestSetRequestTimeoutHeader(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	req := httptest.NewRequest("GET", "/", nil).WithContext(ctx)
+	SetRequestTimeoutHeader(req)
+	ms, err := strconv.Atoi(req.Header.Get(HeaderRequestTimeout))
+	if err != nil || ms <= 0 || ms > 2000 {
+		t.Fatalf("header = %s", req.Header.Get(HeaderRequestTimeout))
+	}
+}
@@ -0,0 +1,231 @@
+package public
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	CacheBackendMemory = "memory"
+	CacheBackendRedis  = "redis"
+
+	HeaderXCache = "X-Cache"
+
+	CacheStatusHit         = "HIT"
+	CacheStatusMiss        = "MISS"
+	CacheStatusStale       = "STALE"
+	CacheStatusRevalidated = "REVALIDATED"
+)
+
+// CachePolicy 服务的响应缓存配置
+type CachePolicy struct {
+	Backend        string        //memory/redis，为空时使用memory
+	Ttl            time.Duration //上游未指定缓存时间时使用
+	ForceTtl       bool          //忽略上游max-age，始终使用Ttl；no-store/private仍不缓存
+	StaleTtl       time.Duration //过期后仍可返回旧数据并后台刷新的时间
+	KeyIgnoreQuery bool          //缓存key不包含query
+	KeyHeaders     []string      //参与缓存key的请求header
+	KeyApp         bool          //缓存key包含租户
+}
+
+// CacheEntry 缓存的响应；VaryOnly为true时只记录上游的Vary，真正的响应按Vary的header值另存
+type CacheEntry struct {
+	Key        string      `json:"key"`
+	Service    string      `json:"service"`
+	Path       string      `json:"path"` //客户端请求的原始path，用于按前缀清除
+	StatusCode int         `json:"status_code"`
+	Header     http.Header `json:"header"`
+	Body       []byte      `json:"body"`
+	Vary       []string    `json:"vary"`
+	VaryOnly   bool        `json:"vary_only"`
+	StoredAt   int64       `json:"stored_at"`
+	ExpireAt   int64       `json:"expire_at"`
+	StaleUntil int64       `json:"stale_until"`
+	KeepUntil  int64       `json:"keep_until"` //有ETag/Last-Modified时过期后继续保留，用于条件请求刷新
+}
+
+func (e *CacheEntry) Fresh(now time.Time) bool {
+	return now.UnixNano() < e.ExpireAt
+}
+
+// Stale 已过期但仍在stale-while-revalidate时间内
+func (e *CacheEntry) Stale(now time.Time) bool {
+	return !e.Fresh(now) && now.UnixNano() < e.StaleUntil
+}
+
+func (e *CacheEntry) HasValidator() bool {
+	return e.Header.Get("ETag") != "" || e.Header.Get("Last-Modified") != ""
+}
+
+// Age 已缓存的秒数
+func (e *CacheEntry) Age(now time.Time) int64 {
+	return int64(now.Sub(time.Unix(0, e.StoredAt)) / time.Second)
+}
+
+// SetLifetime 按ttl与stale设置有效期，条件请求刷新后也用于延长有效期
+func (e *CacheEntry) SetLifetime(now time.Time, ttl, stale time.Duration) {
+	e.StoredAt = now.UnixNano()
+	e.ExpireAt = now.Add(ttl).UnixNano()
+	e.StaleUntil = now.Add(ttl + stale).UnixNano()
+	e.KeepUntil = e.StaleUntil
+	if e.HasValidator() {
+		e.KeepUntil += int64(ttl)
+	}
+}
+
+// ParseCacheControl 解析Cache-Control，指令名转为小写
+func ParseCacheControl(header http.Header) map[string]string {
+	directives := map[string]string{}
+	for _, item := range strings.Split(strings.Join(header.Values("Cache-Control"), ","), ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		name, value := item, ""
+		if idx := strings.Index(item, "="); idx >= 0 {
+			name, value = item[:idx], strings.Trim(strings.TrimSpace(item[idx+1:]), `"`)
+		}
+		directives[strings.ToLower(strings.TrimSpace(name))] = value
+	}
+	return directives
+}
+
+func cacheControlSeconds(directives map[string]string, name string) (time.Duration, bool) {
+	value, ok := directives[name]
+	if !ok {
+		return 0, false
+	}
+	seconds, err := strconv.Atoi(value)
+	if err != nil || seconds < 0 {
+		return 0, false
+	}
+	return time.Duration(seconds) * time.Second, true
+}
+
+// RequestBypass 客户端要求不使用缓存，noStore为true时响应也不写入缓存
+func RequestBypass(r *http.Request) (bypass bool, noStore bool) {
+	directives := ParseCacheControl(r.Header)
+	if _, ok := directives["no-store"]; ok {
+		return true, true
+	}
+	if _, ok := directives["no-cache"]; ok {
+		return true, false
+	}
+	if maxAge, ok := cacheControlSeconds(directives, "max-age"); ok && maxAge == 0 {
+		return true, false
+	}
+	return r.Header.Get("Pragma") == "no-cache", false
+}
+
+// ResponseTTL 按上游响应头与服务配置计算缓存时间，不可缓存时ok为false
+func (p *CachePolicy) ResponseTTL(reqHeader, header http.Header, now time.Time) (ttl, stale time.Duration, ok bool) {
+	directives := ParseCacheControl(header)
+	for _, name := range []string{"no-store", "private", "no-cache"} {
+		if _, found := directives[name]; found {
+			return 0, 0, false
+		}
+	}
+	if header.Get("Set-Cookie") != "" || strings.TrimSpace(header.Get("Vary")) == "*" {
+		return 0, 0, false
+	}
+	_, public := directives["public"]
+	sMaxAge, hasSMaxAge := cacheControlSeconds(directives, "s-maxage")
+	//带凭证的请求不按租户区分时，只缓存上游明确允许共享的响应
+	if reqHeader.Get("Authorization") != "" && !p.KeyApp && !public && !hasSMaxAge {
+		return 0, 0, false
+	}
+	switch {
+	case p.ForceTtl && p.Ttl > 0:
+		ttl = p.Ttl
+	case hasSMaxAge:
+		ttl = sMaxAge
+	default:
+		if maxAge, found := cacheControlSeconds(directives, "max-age"); found {
+			ttl = maxAge
+		} else if expires, err := http.ParseTime(header.Get("Expires")); err == nil {
+			ttl = expires.Sub(now)
+		} else {
+			ttl = p.Ttl
+		}
+	}
+	if ttl <= 0 {
+		return 0, 0, false
+	}
+	stale = p.StaleTtl
+	if swr, found := cacheControlSeconds(directives, "stale-while-revalidate"); found && swr > stale {
+		stale = swr
+	}
+	return ttl, stale, true
+}
+
+// Key 由服务、path、query、指定header、租户及上游Vary的header组成
+func (p *CachePolicy) Key(service string, r *http.Request, appID string, vary []string) string {
+	h := sha1.New()
+	write := func(parts ...string) {
+		for _, part := range parts {
+			h.Write([]byte(part))
+			h.Write([]byte{0})
+		}
+	}
+	write(r.URL.Path)
+	if !p.KeyIgnoreQuery {
+		write(r.URL.Query().Encode())
+	}
+	for _, name := range p.KeyHeaders {
+		write("header", name, r.Header.Get(name))
+	}
+	if p.KeyApp {
+		write("app", appID)
+	}
+	for _, name := range vary {
+		write("vary", name, strings.Join(r.Header.Values(name), ","))
+	}
+	return RedisResponseCacheKey + "_" + service + "_" + hex.EncodeToString(h.Sum(nil))
+}
+
+// VaryHeaders 上游响应的Vary列表，统一为规范header名
+func VaryHeaders(header http.Header) []string {
+	vary := []string{}
+	for _, item := range strings.Split(strings.Join(header.Values("Vary"), ","), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			vary = append(vary, http.CanonicalHeaderKey(item))
+		}
+	}
+	return vary
+}
+
+// NotModified 客户端的条件请求是否与缓存一致
+func NotModified(reqHeader, header http.Header) bool {
+	if inm := reqHeader.Get("If-None-Match"); inm != "" {
+		etag := strings.TrimPrefix(header.Get("ETag"), "W/")
+		if etag == "" {
+			return false
+		}
+		for _, item := range strings.Split(inm, ",") {
+			item = strings.TrimSpace(item)
+			if item == "*" || strings.TrimPrefix(item, "W/") == etag {
+				return true
+			}
+		}
+		return false
+	}
+	ims, err := http.ParseTime(reqHeader.Get("If-Modified-Since"))
+	if err != nil {
+		return false
+	}
+	lastModified, err := http.ParseTime(header.Get("Last-Modified"))
+	return err == nil && !lastModified.After(ims)
+}
+
+// SplitHeaderNames 逗号间隔的header名，统一为规范header名
+func SplitHeaderNames(s string) []string {
+	names := splitList(s)
+	for i, name := range names {
+		names[i] = http.CanonicalHeaderKey(name)
+	}
+	return names
+}
@@ -0,0 +1,339 @@
+package public
+
+import (
+	"FGateWay/golang_common/lib"
+	"container/list"
+	"encoding/json"
+	"github.com/garyburd/redigo/redis"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	CacheDefaultMaxEntries    = 10000
+	CacheDefaultMaxMemory     = 64 * 1024 * 1024
+	CacheDefaultMaxBodySize   = 1024 * 1024
+	CacheDefaultPurgeInterval = time.Second
+	//保留最近的清除通知条数
+	cachePurgeKeep = 100
+)
+
+// CacheStore 响应缓存存储
+type CacheStore interface {
+	Get(key string) (*CacheEntry, error)
+	Set(entry *CacheEntry) error
+	//按服务清除，pathPrefix为空时清除服务全部缓存
+	Purge(service, pathPrefix string) (int, error)
+}
+
+// ResponseCacheConf 读取proxy.cache配置
+type ResponseCacheConf struct {
+	MaxEntries  int   //内存缓存最大条数
+	MaxMemory   int64 //内存缓存最大字节
+	MaxBodySize int   //单个响应体超过该大小不缓存
+}
+
+var (
+	responseCacheConf     *ResponseCacheConf
+	responseCacheConfOnce sync.Once
+	memoryCache           *MemoryCache
+	memoryCacheOnce       sync.Once
+)
+
+func GetResponseCacheConf() *ResponseCacheConf {
+	responseCacheConfOnce.Do(func() {
+		conf := &ResponseCacheConf{
+			MaxEntries:  lib.GetIntConf("proxy.cache.max_entries"),
+			MaxMemory:   int64(lib.GetIntConf("proxy.cache.max_memory")),
+			MaxBodySize: lib.GetIntConf("proxy.cache.max_body_size"),
+		}
+		if conf.MaxEntries <= 0 {
+			conf.MaxEntries = CacheDefaultMaxEntries
+		}
+		if conf.MaxMemory <= 0 {
+			conf.MaxMemory = CacheDefaultMaxMemory
+		}
+		if conf.MaxBodySize <= 0 {
+			conf.MaxBodySize = CacheDefaultMaxBodySize
+		}
+		responseCacheConf = conf
+	})
+	return responseCacheConf
+}
+
+// GetCacheStore 内存缓存为进程内单例，redis缓存由所有代理实例共享
+func GetCacheStore(backend string) CacheStore {
+	if backend == CacheBackendRedis {
+		return &RedisCache{}
+	}
+	memoryCacheOnce.Do(func() {
+		conf := GetResponseCacheConf()
+		memoryCache = NewMemoryCache(conf.MaxEntries, conf.MaxMemory)
+	})
+	return memoryCache
+}
+
+// MemoryCache LRU内存缓存，超过条数或字节上限时淘汰最久未使用的
+type MemoryCache struct {
+	MaxEntries int
+	MaxMemory  int64
+	Locker     sync.Mutex
+	list       *list.List
+	items      map[string]*list.Element
+	size       int64
+}
+
+func NewMemoryCache(maxEntries int, maxMemory int64) *MemoryCache {
+	return &MemoryCache{
+		MaxEntries: maxEntries,
+		MaxMemory:  maxMemory,
+		Locker:     sync.Mutex{},
+		list:       list.New(),
+		items:      map[string]*list.Element{},
+	}
+}
+
+func cacheEntrySize(entry *CacheEntry) int64 {
+	size := len(entry.Key) + len(entry.Path) + len(entry.Body)
+	for key, values := range entry.Header {
+		size += len(key)
+		for _, value := range values {
+			size += len(value)
+		}
+	}
+	return int64(size)
+}
+
+func (m *MemoryCache) Get(key string) (*CacheEntry, error) {
+	m.Locker.Lock()
+	defer m.Locker.Unlock()
+	elem, ok := m.items[key]
+	if !ok {
+		return nil, nil
+	}
+	entry := elem.Value.(*CacheEntry)
+	if time.Now().UnixNano() >= entry.KeepUntil {
+		m.remove(elem)
+		return nil, nil
+	}
+	m.list.MoveToFront(elem)
+	return entry, nil
+}
+
+func (m *MemoryCache) Set(entry *CacheEntry) error {
+	m.Locker.Lock()
+	defer m.Locker.Unlock()
+	if elem, ok := m.items[entry.Key]; ok {
+		m.remove(elem)
+	}
+	m.items[entry.Key] = m.list.PushFront(entry)
+	m.size += cacheEntrySize(entry)
+	for m.list.Len() > 0 && (m.list.Len() > m.MaxEntries || m.size > m.MaxMemory) {
+		m.remove(m.list.Back())
+	}
+	return nil
+}
+
+func (m *MemoryCache) Purge(service, pathPrefix string) (int, error) {
+	m.Locker.Lock()
+	defer m.Locker.Unlock()
+	count := 0
+	for elem := m.list.Front(); elem != nil; {
+		next := elem.Next()
+		entry := elem.Value.(*CacheEntry)
+		if entry.Service == service && strings.HasPrefix(entry.Path, pathPrefix) {
+			m.remove(elem)
+			count++
+		}
+		elem = next
+	}
+	return count, nil
+}
+
+// Flush 清空全部缓存，返回清除的条数
+func (m *MemoryCache) Flush() int {
+	m.Locker.Lock()
+	defer m.Locker.Unlock()
+	count := m.list.Len()
+	m.list.Init()
+	m.items = map[string]*list.Element{}
+	m.size = 0
+	return count
+}
+
+func (m *MemoryCache) Len() int {
+	m.Locker.Lock()
+	defer m.Locker.Unlock()
+	return m.list.Len()
+}
+
+func (m *MemoryCache) remove(elem *list.Element) {
+	entry := m.list.Remove(elem).(*CacheEntry)
+	delete(m.items, entry.Key)
+	m.size -= cacheEntrySize(entry)
+}
+
+// RedisCache 响应存为json并设置过期时间，每个服务用一个zset记录key与path，用于按前缀清除；
+// zset的score为缓存的过期时间，写入时顺带清理已过期的记录
+type RedisCache struct{}
+
+func cacheIndexKey(service string) string {
+	return RedisResponseCacheIndexKey + "_" + service
+}
+
+// 索引成员为"key path"，key中不含空格
+func cacheIndexMember(key, path string) string {
+	return key + " " + path
+}
+
+func parseCacheIndexMember(member string) (string, string) {
+	if idx := strings.Index(member, " "); idx >= 0 {
+		return member[:idx], member[idx+1:]
+	}
+	return member, ""
+}
+
+func (r *RedisCache) Get(key string) (*CacheEntry, error) {
+	data, err := redis.Bytes(RedisConfDo("GET", key))
+	if err == redis.ErrNil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	entry := &CacheEntry{}
+	if err := json.Unmarshal(data, entry); err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+func (r *RedisCache) Set(entry *CacheEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	ttl := (time.Duration(entry.KeepUntil-time.Now().UnixNano()) + time.Second - 1) / time.Second
+	if ttl <= 0 {
+		return nil
+	}
+	c, err := lib.RedisConnFactory("default")
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	indexKey := cacheIndexKey(entry.Service)
+	c.Send("SET", entry.Key, data, "EX", int64(ttl))
+	c.Send("ZADD", indexKey, time.Now().Add(ttl*time.Second).Unix(), cacheIndexMember(entry.Key, entry.Path))
+	c.Send("ZREMRANGEBYSCORE", indexKey, "-inf", time.Now().Unix())
+	c.Send("EXPIRE", indexKey, 7*86400)
+	_, err = c.Do("")
+	return err
+}
+
+func (r *RedisCache) Purge(service, pathPrefix string) (int, error) {
+	members, err := redis.Strings(RedisConfDo("ZRANGE", cacheIndexKey(service), 0, -1))
+	if err != nil {
+		return 0, err
+	}
+	keys := redis.Args{}
+	removed := redis.Args{}.Add(cacheIndexKey(service))
+	for _, member := range members {
+		if key, path := parseCacheIndexMember(member); strings.HasPrefix(path, pathPrefix) {
+			keys = keys.Add(key)
+			removed = removed.Add(member)
+		}
+	}
+	if len(keys) == 0 {
+		return 0, nil
+	}
+	if _, err := RedisConfDo("DEL", keys...); err != nil {
+		return 0, err
+	}
+	if _, err := RedisConfDo("ZREM", removed...); err != nil {
+		return 0, err
+	}
+	return len(keys), nil
+}
+
+// CachePurge 清除通知，dashboard写入redis，代理实例拉取后清除各自的内存缓存
+type CachePurge struct {
+	Seq        int64  `json:"seq"`
+	Service    string `json:"service"`
+	PathPrefix string `json:"path_prefix"`
+}
+
+// 序号递增与写入通知在同一脚本中原子执行，代理读到的序号对应的通知一定已在列表中
+var cachePurgeScript = redis.NewScript(2, `
+local seq = redis.call('INCR', KEYS[1])
+local purge = cjson.decode(ARGV[1])
+purge['seq'] = seq
+redis.call('RPUSH', KEYS[2], cjson.encode(purge))
+redis.call('LTRIM', KEYS[2], -tonumber(ARGV[2]), -1)
+return seq
+`)
+
+// PublishCachePurge 通知所有代理实例清除内存缓存
+func PublishCachePurge(service, pathPrefix string) error {
+	data, err := json.Marshal(&CachePurge{Service: service, PathPrefix: pathPrefix})
+	if err != nil {
+		return err
+	}
+	c, err := lib.RedisConnFactory("default")
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	_, err = cachePurgeScript.Do(c, RedisCachePurgeSeqKey, RedisCachePurgeKey, data, cachePurgeKeep)
+	return err
+}
+
+// WatchCachePurge 代理实例定时拉取清除通知，只处理启动后发布的通知
+func WatchCachePurge() {
+	interval := time.Duration(lib.GetIntConf("proxy.cache.purge_interval")) * time.Second
+	if interval <= 0 {
+		interval = CacheDefaultPurgeInterval
+	}
+	lastSeq, err := redis.Int64(RedisConfDo("GET", RedisCachePurgeSeqKey))
+	if err != nil && err != redis.ErrNil {
+		log.Printf(" [ERROR] watch cache purge err:%v\n", err)
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		seq, err := redis.Int64(RedisConfDo("GET", RedisCachePurgeSeqKey))
+		if err != nil || seq <= lastSeq {
+			continue
+		}
+		values, err := redis.ByteSlices(RedisConfDo("LRANGE", RedisCachePurgeKey, 0, -1))
+		if err != nil {
+			log.Printf(" [ERROR] watch cache purge err:%v\n", err)
+			continue
+		}
+		lastSeq = applyCachePurges(GetCacheStore(CacheBackendMemory).(*MemoryCache), values, lastSeq, seq)
+	}
+}
+
+// applyCachePurges 按序号大于lastSeq的通知清除内存缓存，返回处理到的序号；
+// 落后超过保留条数时部分通知已被裁掉，无法逐条清除，直接清空内存缓存
+func applyCachePurges(cache *MemoryCache, values [][]byte, lastSeq, seq int64) int64 {
+	if lastSeq < seq-cachePurgeKeep {
+		log.Printf(" [INFO] cache purge lag %d exceeds %d, flush memory cache\n", seq-lastSeq, cachePurgeKeep)
+		cache.Flush()
+		return seq
+	}
+	maxSeq := seq
+	for _, value := range values {
+		purge := &CachePurge{}
+		if err := json.Unmarshal(value, purge); err != nil || purge.Seq <= lastSeq {
+			continue
+		}
+		cache.Purge(purge.Service, purge.PathPrefix)
+		if purge.Seq > maxSeq {
+			maxSeq = purge.Seq
+		}
+	}
+	return maxSeq
+}
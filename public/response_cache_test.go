@@ -0,0 +1,164 @@
+package public
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCachePolicyResponseTTL(t *testing.T) {
+	now := time.Now()
+	policy := &CachePolicy{Ttl: 60 * time.Second, StaleTtl: 10 * time.Second}
+	cases := []struct {
+		cacheControl string
+		auth         bool
+		ttl          time.Duration
+		stale        time.Duration
+		ok           bool
+	}{
+		{"", false, 60 * time.Second, 10 * time.Second, true},
+		{"max-age=30", false, 30 * time.Second, 10 * time.Second, true},
+		{"max-age=30, s-maxage=120", false, 120 * time.Second, 10 * time.Second, true},
+		{"max-age=30, stale-while-revalidate=100", false, 30 * time.Second, 100 * time.Second, true},
+		{"no-store", false, 0, 0, false},
+		{"private, max-age=30", false, 0, 0, false},
+		{"max-age=0", false, 0, 0, false},
+		{"max-age=30", true, 0, 0, false},
+		{"public, max-age=30", true, 30 * time.Second, 10 * time.Second, true},
+	}
+	for _, item := range cases {
+		reqHeader, header := http.Header{}, http.Header{}
+		if item.cacheControl != "" {
+			header.Set("Cache-Control", item.cacheControl)
+		}
+		if item.auth {
+			reqHeader.Set("Authorization", "Bearer x")
+		}
+		ttl, stale, ok := policy.ResponseTTL(reqHeader, header, now)
+		if ttl != item.ttl || stale != item.stale || ok != item.ok {
+			t.Errorf("%q auth=%v: got (%v,%v,%v), want (%v,%v,%v)", item.cacheControl, item.auth, ttl, stale, ok, item.ttl, item.stale, item.ok)
+		}
+	}
+
+	force := &CachePolicy{Ttl: 60 * time.Second, ForceTtl: true}
+	if ttl, _, ok := force.ResponseTTL(http.Header{}, http.Header{"Cache-Control": {"max-age=5"}}, now); !ok || ttl != 60*time.Second {
+		t.Errorf("force ttl got %v %v", ttl, ok)
+	}
+	if _, _, ok := force.ResponseTTL(http.Header{}, http.Header{"Set-Cookie": {"a=1"}}, now); ok {
+		t.Error("response with Set-Cookie should not be cached")
+	}
+}
+
+func TestCachePolicyKey(t *testing.T) {
+	policy := &CachePolicy{KeyHeaders: []string{"X-Lang"}}
+	r1 := httptest.NewRequest(http.MethodGet, "/a?x=1&y=2", nil)
+	r2 := httptest.NewRequest(http.MethodGet, "/a?y=2&x=1", nil)
+	if policy.Key("svc", r1, "", nil) != policy.Key("svc", r2, "", nil) {
+		t.Error("query order should not change key")
+	}
+	r2.Header.Set("X-Lang", "en")
+	if policy.Key("svc", r1, "", nil) == policy.Key("svc", r2, "", nil) {
+		t.Error("key header should change key")
+	}
+	r1.Header.Set("Accept-Encoding", "gzip")
+	if policy.Key("svc", r1, "", nil) == policy.Key("svc", r1, "", []string{"Accept-Encoding"}) {
+		t.Error("vary header should change key")
+	}
+	ignore := &CachePolicy{KeyIgnoreQuery: true}
+	if ignore.Key("svc", httptest.NewRequest(http.MethodGet, "/a?x=1", nil), "", nil) != ignore.Key("svc", httptest.NewRequest(http.MethodGet, "/a", nil), "", nil) {
+		t.Error("query should be ignored")
+	}
+}
+
+func TestNotModified(t *testing.T) {
+	header := http.Header{"Etag": {`"v1"`}, "Last-Modified": {"Mon, 02 Jan 2006 15:04:05 GMT"}}
+	if !NotModified(http.Header{"If-None-Match": {`W/"v1", "v0"`}}, header) {
+		t.Error("etag should match")
+	}
+	if NotModified(http.Header{"If-None-Match": {`"v2"`}}, header) {
+		t.Error("etag should not match")
+	}
+	if !NotModified(http.Header{"If-Modified-Since": {"Mon, 02 Jan 2006 15:04:05 GMT"}}, header) {
+		t.Error("last-modified should match")
+	}
+	if NotModified(http.Header{"If-Modified-Since": {"Mon, 02 Jan 2006 15:04:04 GMT"}}, header) {
+		t.Error("modified after since")
+	}
+}
+
+func TestMemoryCacheLRU(t *testing.T) {
+	now := time.Now()
+	newEntry := func(key, path string) *CacheEntry {
+		entry := &CacheEntry{Key: key, Service: "svc", Path: path, Header: http.Header{}, Body: []byte("body")}
+		entry.SetLifetime(now, time.Minute, 0)
+		return entry
+	}
+	cache := NewMemoryCache(2, 1<<20)
+	cache.Set(newEntry("a", "/a"))
+	cache.Set(newEntry("b", "/b"))
+	cache.Get("a")
+	cache.Set(newEntry("c", "/c"))
+	if entry, _ := cache.Get("b"); entry != nil {
+		t.Error("least recently used entry should be evicted")
+	}
+	if entry, _ := cache.Get("a"); entry == nil {
+		t.Error("recently used entry should be kept")
+	}
+
+	cache = NewMemoryCache(100, 10)
+	cache.Set(newEntry("a", "/a"))
+	cache.Set(newEntry("b", "/b"))
+	if cache.Len() != 1 {
+		t.Errorf("memory limit should evict, len=%d", cache.Len())
+	}
+
+	cache = NewMemoryCache(100, 1<<20)
+	cache.Set(newEntry("1", "/api/user/1"))
+	cache.Set(newEntry("2", "/api/user/2"))
+	cache.Set(newEntry("3", "/api/order/1"))
+	if count, _ := cache.Purge("svc", "/api/user"); count != 2 || cache.Len() != 1 {
+		t.Errorf("purge by prefix got %d, len=%d", count, cache.Len())
+	}
+	if count, _ := cache.Purge("svc", ""); count != 1 {
+		t.Errorf("purge all got %d", count)
+	}
+
+	expired := newEntry("x", "/x")
+	expired.SetLifetime(now.Add(-time.Hour), time.Minute, 0)
+	cache.Set(expired)
+	if entry, _ := cache.Get("x"); entry != nil {
+		t.Error("expired entry should not be returned")
+	}
+}
+
+func TestApplyCachePurges(t *testing.T) {
+	now := time.Now()
+	cache := NewMemoryCache(100, 1<<20)
+	for _, path := range []string{"/api/user/1", "/api/order/1", "/static/a"} {
+		entry := &CacheEntry{Key: path, Service: "svc", Path: path, Header: http.Header{}, Body: []byte("body")}
+		entry.SetLifetime(now, time.Minute, 0)
+		cache.Set(entry)
+	}
+	values := [][]byte{}
+	for seq, prefix := range []string{"/api/user", "/api/order"} {
+		data, _ := json.Marshal(&CachePurge{Seq: int64(seq + 1), Service: "svc", PathPrefix: prefix})
+		values = append(values, data)
+	}
+	//已处理过的通知跳过
+	if seq := applyCachePurges(cache, values, 1, 2); seq != 2 || cache.Len() != 2 {
+		t.Fatalf("unexpected seq %d len %d", seq, cache.Len())
+	}
+	//落后超过保留条数时通知不完整，清空全部缓存
+	if seq := applyCachePurges(cache, values, 2, 3+cachePurgeKeep); seq != 3+cachePurgeKeep || cache.Len() != 0 {
+		t.Fatalf("lagging instance should flush cache, seq %d len %d", seq, cache.Len())
+	}
+}
+
+func TestCacheIndexMember(t *testing.T) {
+	member := cacheIndexMember("response_cache_svc_abc", "/api/a b")
+	if key, path := parseCacheIndexMember(member); key != "response_cache_svc_abc" || path != "/api/a b" {
+		t.Fatalf("unexpected key %s path %s", key, path)
+	}
+}
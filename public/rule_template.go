@@ -0,0 +1,41 @@
+package public
+
+import (
+	"strings"
+)
+
+// VarLookup 按名称取模板变量，ok为false表示未知变量，原样保留
+type VarLookup func(name string) (value string, ok bool)
+
+// Interpolate 替换模板中的${name}
+func Interpolate(tpl string, lookup VarLookup) string {
+	if lookup == nil || !strings.Contains(tpl, "${") {
+		return tpl
+	}
+	var b strings.Builder
+	for {
+		start := strings.Index(tpl, "${")
+		if start < 0 {
+			break
+		}
+		end := strings.Index(tpl[start:], "}")
+		if end < 0 {
+			break
+		}
+		end += start
+		b.WriteString(tpl[:start])
+		if value, ok := lookup(tpl[start+2 : end]); ok {
+			b.WriteString(value)
+		} else {
+			b.WriteString(tpl[start : end+1])
+		}
+		tpl = tpl[end+1:]
+	}
+	b.WriteString(tpl)
+	return b.String()
+}
+
+// 规则为JSON数组时按结构化格式解析，否则按旧的空格、逗号间隔格式解析
+func isJsonRules(rules string) bool {
+	return strings.HasPrefix(strings.TrimSpace(rules), "[")
+}
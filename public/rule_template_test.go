@@ -0,0 +1,100 @@
+package public
+
+import (
+	"net/http"
+	"regexp"
+	"testing"
+)
+
+func testLookup(name string) (string, bool) {
+	vars := map[string]string{"app.id": "app_1", "client_ip": "10.0.0.1", "price": "$9"}
+	value, ok := vars[name]
+	return value, ok
+}
+
+func TestInterpolate(t *testing.T) {
+	cases := map[string]string{
+		"${app.id}":                "app_1",
+		"tenant-${app.id}-${x}":    "tenant-app_1-${x}",
+		"ip=${client_ip}, ${":      "ip=10.0.0.1, ${",
+		"no var":                   "no var",
+		"${app.id}${client_ip}end": "app_110.0.0.1end",
+	}
+	for tpl, want := range cases {
+		if got := Interpolate(tpl, testLookup); got != want {
+			t.Errorf("Interpolate(%s)=%s, want %s", tpl, got, want)
+		}
+	}
+}
+
+func applyHeaderTransfor(t *testing.T, header http.Header, rules string, lookup VarLookup) {
+	list, err := ParseHeaderRules(rules)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ApplyHeaderRules(header, list, lookup)
+}
+
+func TestApplyHeaderRules(t *testing.T) {
+	header := http.Header{}
+	header.Set("Server", "nginx")
+	header.Set("X-Old", "1")
+	applyHeaderTransfor(t, header, "add X-Gateway fgateway,edit X-Old 2,del Server nginx", nil)
+	if header.Get("X-Gateway") != "fgateway" || header.Get("X-Old") != "2" || header.Get("Server") != "" {
+		t.Fatalf("unexpected header %v", header)
+	}
+
+	header = http.Header{}
+	applyHeaderTransfor(t, header, `[{"op":"set","name":"X-Tenant","value":"${app.id}"},`+
+		`{"op":"append","name":"X-Via","value":"a, b"},{"op":"append","name":"X-Via","value":"c d"}]`, testLookup)
+	if header.Get("X-Tenant") != "app_1" || len(header.Values("X-Via")) != 2 || header.Values("X-Via")[0] != "a, b" {
+		t.Fatalf("unexpected header %v", header)
+	}
+}
+
+func TestParseHeaderRulesInvalid(t *testing.T) {
+	for _, rules := range []string{
+		"add X-Only",
+		`[{"op":"move","name":"X-A"}]`,
+		`[{"op":"set","name":"X A","value":"1"}]`,
+		`[{"op":"set","name":"X-A","value":"1\r\nX-B: 2"}]`,
+		`[{"op":"set"`,
+	} {
+		if err := ValidHeaderRules(rules); err == nil {
+			t.Errorf("expected error for %s", rules)
+		}
+	}
+	//运行时旧格式的无效规则逐条跳过，JSON格式仍整体报错
+	if list, err := ParseHeaderRules("add X-Only,move X-A 1,add X-B 2"); err != nil || len(list) != 1 || list[0].Name != "X-B" {
+		t.Fatalf("unexpected %v %v", list, err)
+	}
+	if _, err := ParseHeaderRules(`[{"op":"move","name":"X-A"}]`); err == nil {
+		t.Fatal("expected error for invalid json rule")
+	}
+}
+
+func TestNormalizeRules(t *testing.T) {
+	got, err := NormalizeHeaderRules("add X-A 1,del X-B x")
+	if err != nil || got != `[{"op":"set","name":"X-A","value":"1"},{"op":"del","name":"X-B","value":"x"}]` {
+		t.Fatalf("unexpected %s %v", got, err)
+	}
+	got, err = NormalizeUrlRewriteRules("^/abc/(.*) /$1,^/d /e")
+	if err != nil || got != `[{"match":"^/abc/(.*)","replace":"/$1"},{"match":"^/d","replace":"/e"}]` {
+		t.Fatalf("unexpected %s %v", got, err)
+	}
+	if got, _ := NormalizeUrlRewriteRules(got); got != `[{"match":"^/abc/(.*)","replace":"/$1"},{"match":"^/d","replace":"/e"}]` {
+		t.Fatalf("json rules should be kept, got %s", got)
+	}
+}
+
+func TestRewriteVarLookup(t *testing.T) {
+	rules, err := ParseUrlRewriteRules(`[{"match":"^/api/(\\w+)/(.*)","replace":"/${app.id}/$2/${1}/${price}"}]`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	re := regexp.MustCompile(rules[0].Match)
+	tpl := Interpolate(rules[0].Replace, RewriteVarLookup(testLookup))
+	if got := re.ReplaceAllString("/api/user/list", tpl); got != "/app_1/list/user/$9" {
+		t.Fatalf("unexpected %s", got)
+	}
+}
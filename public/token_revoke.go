@@ -0,0 +1,102 @@
+package public
+
+import (
+	"FGateWay/golang_common/lib"
+	"fmt"
+	"github.com/dgrijalva/jwt-go"
+	"github.com/garyburd/redigo/redis"
+	"github.com/pkg/errors"
+	"sync"
+	"time"
+)
+
+var (
+	tokenRevokeFailOpen     bool
+	tokenRevokeFailOpenOnce sync.Once
+)
+
+// TokenRevokeFailOpen 吊销状态查询失败时是否放行，默认拒绝，避免redis故障期间已吊销的token继续可用
+func TokenRevokeFailOpen() bool {
+	tokenRevokeFailOpenOnce.Do(func() {
+		tokenRevokeFailOpen = lib.GetBoolConf("proxy.jwt.revoke_fail_open")
+	})
+	return tokenRevokeFailOpen
+}
+
+func GetTokenRevokeJtiKey(jti string) string {
+	return fmt.Sprintf("%s_%s", RedisTokenRevokeJtiKey, jti)
+}
+
+func GetTokenRevokeAppKey(appID string) string {
+	return fmt.Sprintf("%s_%s", RedisTokenRevokeAppKey, appID)
+}
+
+// RevokeTokenJti 按jti吊销单个token，过期时间与token一致
+func RevokeTokenJti(jti string, expiresAt int64) error {
+	ttl := expiresAt - time.Now().Unix()
+	if ttl <= 0 {
+		return nil
+	}
+	_, err := RedisConfDo("SET", GetTokenRevokeJtiKey(jti), 1, "EX", ttl)
+	return err
+}
+
+// RevokeAppTokens 吊销租户在notBefore(含)之前签发的所有token
+// expire为0时永久生效，用于租户删除，同一app_id重新创建时由ClearAppTokenRevoke清除
+func RevokeAppTokens(appID string, notBefore int64, expire int64) error {
+	if expire <= 0 {
+		_, err := RedisConfDo("SET", GetTokenRevokeAppKey(appID), notBefore)
+		return err
+	}
+	_, err := RedisConfDo("SET", GetTokenRevokeAppKey(appID), notBefore, "EX", expire)
+	return err
+}
+
+// ClearAppTokenRevoke 删除租户的吊销记录，用于同一app_id重新创建租户
+func ClearAppTokenRevoke(appID string) error {
+	_, err := RedisConfDo("DEL", GetTokenRevokeAppKey(appID))
+	return err
+}
+
+// GetAppTokenNotBefore 获取租户token的吊销时间点，未设置时返回0
+func GetAppTokenNotBefore(appID string) (int64, error) {
+	notBefore, err := redis.Int64(RedisConfDo("GET", GetTokenRevokeAppKey(appID)))
+	if err == redis.ErrNil {
+		return 0, nil
+	}
+	return notBefore, err
+}
+
+// IsTokenRevoked 检查token是否被按jti或按租户吊销
+func IsTokenRevoked(claims *jwt.StandardClaims) (bool, error) {
+	c, err := lib.RedisConnFactory("default")
+	if err != nil {
+		return false, err
+	}
+	defer c.Close()
+	values, err := redis.Values(c.Do("MGET", GetTokenRevokeJtiKey(claims.Id), GetTokenRevokeAppKey(claims.Issuer)))
+	if err != nil {
+		return false, err
+	}
+	return tokenRevoked(claims, values)
+}
+
+// tokenRevoked 按MGET的jti与租户吊销时间点判断
+func tokenRevoked(claims *jwt.StandardClaims, values []interface{}) (bool, error) {
+	if len(values) != 2 {
+		return false, errors.Errorf("unexpected revoke values %v", values)
+	}
+	if claims.Id != "" && values[0] != nil {
+		return true, nil
+	}
+	if values[1] != nil {
+		notBefore, err := redis.Int64(values[1], nil)
+		if err != nil {
+			return false, err
+		}
+		if claims.IssuedAt <= notBefore {
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,53 @@
+package public
+
+import (
+	"FGateWay/golang_common/lib"
+	"github.com/dgrijalva/jwt-go"
+	"net"
+	"testing"
+)
+
+func TestTokenRevoked(t *testing.T) {
+	claims := &jwt.StandardClaims{Id: "jti_a", Issuer: "app_id_a", IssuedAt: 100}
+	cases := []struct {
+		name    string
+		claims  *jwt.StandardClaims
+		values  []interface{}
+		revoked bool
+	}{
+		{"not revoked", claims, []interface{}{nil, nil}, false},
+		{"jti revoked", claims, []interface{}{[]byte("1"), nil}, true},
+		{"jti empty", &jwt.StandardClaims{Issuer: "app_id_a", IssuedAt: 100}, []interface{}{[]byte("1"), nil}, false},
+		{"issued before app revoke", claims, []interface{}{nil, []byte("100")}, true},
+		{"issued after app revoke", claims, []interface{}{nil, []byte("99")}, false},
+	}
+	for _, tc := range cases {
+		revoked, err := tokenRevoked(tc.claims, tc.values)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		if revoked != tc.revoked {
+			t.Fatalf("%s: expect revoked %v, got %v", tc.name, tc.revoked, revoked)
+		}
+	}
+	if _, err := tokenRevoked(claims, []interface{}{nil, []byte("bad")}); err == nil {
+		t.Fatal("expect error for invalid app revoke value")
+	}
+}
+
+func TestIsTokenRevokedRedisUnavailable(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+	old := lib.ConfRedisMap
+	lib.ConfRedisMap = &lib.RedisMapConf{List: map[string]*lib.RedisConf{"default": {ProxyList: []string{addr}}}}
+	defer func() { lib.ConfRedisMap = old }()
+
+	//redis不可用时返回错误，由调用方决定是否放行
+	if _, err := IsTokenRevoked(&jwt.StandardClaims{Id: "jti_a", Issuer: "app_id_a"}); err == nil {
+		t.Fatal("expect error when redis unavailable")
+	}
+}
@@ -0,0 +1,90 @@
+package public
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const TracerName = "FGateWay"
+
+// span属性
+const (
+	TraceAttrService  = attribute.Key("gateway.service")
+	TraceAttrApp      = attribute.Key("gateway.app")
+	TraceAttrUpstream = attribute.Key("gateway.upstream")
+)
+
+// TraceConf 链路追踪配置，Exporter为空时只透传traceparent，不导出span
+type TraceConf struct {
+	Exporter    string  // otlp / stdout
+	Endpoint    string  // otlp http地址，如 127.0.0.1:4318
+	Insecure    bool    // otlp是否使用http
+	SampleRatio float64 // 采样率，仅对没有上游采样决策的请求生效
+	ServiceName string
+}
+
+// InitTracer 设置全局TracerProvider与W3C传播器，返回的函数用于退出时刷新span
+func InitTracer(conf *TraceConf) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	var exporter sdktrace.SpanExporter
+	var err error
+	switch conf.Exporter {
+	case "":
+		return func(context.Context) error { return nil }, nil
+	case "stdout":
+		exporter, err = stdouttrace.New(stdouttrace.WithPrettyPrint())
+	case "otlp":
+		opts := []otlptracehttp.Option{otlptracehttp.WithEndpoint(conf.Endpoint)}
+		if conf.Insecure {
+			opts = append(opts, otlptracehttp.WithInsecure())
+		}
+		exporter, err = otlptracehttp.New(context.Background(), opts...)
+	default:
+		return nil, errors.New("unsupported trace exporter " + conf.Exporter)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if conf.ServiceName == "" {
+		conf.ServiceName = TracerName
+	}
+	sampler, err := newTraceSampler(conf.SampleRatio)
+	if err != nil {
+		return nil, err
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithSampler(sdktrace.ParentBased(sampler)),
+		sdktrace.WithResource(resource.NewSchemaless(attribute.String("service.name", conf.ServiceName))),
+	)
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
+
+// newTraceSampler 采样率取值[0,1]，0为不采样，1为全部采样
+func newTraceSampler(ratio float64) (sdktrace.Sampler, error) {
+	switch {
+	case ratio < 0 || ratio > 1:
+		return nil, errors.New(fmt.Sprintf("trace sample_ratio %v out of range [0,1]", ratio))
+	case ratio == 0:
+		return sdktrace.NeverSample(), nil
+	case ratio == 1:
+		return sdktrace.AlwaysSample(), nil
+	}
+	return sdktrace.TraceIDRatioBased(ratio), nil
+}
+
+func Tracer() trace.Tracer {
+	return otel.Tracer(TracerName)
+}
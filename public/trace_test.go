@@ -0,0 +1,34 @@
+package public
+
+import (
+	"testing"
+)
+
+func TestNewTraceSampler(t *testing.T) {
+	cases := []struct {
+		ratio   float64
+		desc    string
+		invalid bool
+	}{
+		{0, "AlwaysOffSampler", false},
+		{1, "AlwaysOnSampler", false},
+		{0.5, "TraceIDRatioBased{0.5}", false},
+		{-0.1, "", true},
+		{1.5, "", true},
+	}
+	for _, tc := range cases {
+		sampler, err := newTraceSampler(tc.ratio)
+		if tc.invalid {
+			if err == nil {
+				t.Fatalf("ratio %v: expect error", tc.ratio)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("ratio %v: %v", tc.ratio, err)
+		}
+		if desc := sampler.Description(); desc != tc.desc {
+			t.Fatalf("ratio %v: expect %v, got %v", tc.ratio, tc.desc, desc)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package public
+
+import (
+	"FGateWay/golang_common/lib"
+	"fmt"
+	"github.com/garyburd/redigo/redis"
+	"time"
+)
+
+const (
+	// 代理进程上报节点探活结果的周期与过期时间
+	UpstreamHealthReportInterval = 5 * time.Second
+	UpstreamHealthExpire         = 30
+)
+
+func GetUpstreamHealthKey(serviceName string) string {
+	return fmt.Sprintf("%s_%s", RedisUpstreamHealthKey, serviceName)
+}
+
+// ReportUpstreamHealth 将代理进程内的节点探活结果写入redis，供后台读取
+// 删除与重写放在同一事务中，读取方不会看到被清空的中间状态
+func ReportUpstreamHealth(health map[string]map[string]bool) error {
+	if len(health) == 0 {
+		return nil
+	}
+	c, err := lib.RedisConnFactory("default")
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	c.Send("MULTI")
+	for serviceName, nodes := range health {
+		key := GetUpstreamHealthKey(serviceName)
+		args := redis.Args{}.Add(key)
+		for node, up := range nodes {
+			value := 0
+			if up {
+				value = 1
+			}
+			args = args.Add(node, value)
+		}
+		c.Send("DEL", key)
+		if len(args) > 1 {
+			c.Send("HSET", args...)
+		}
+		c.Send("EXPIRE", key, UpstreamHealthExpire)
+	}
+	_, err = c.Do("EXEC")
+	return err
+}
+
+// GetUpstreamHealth 读取服务节点的探活结果，没有存活的代理上报时返回空
+func GetUpstreamHealth(serviceName string) (map[string]bool, error) {
+	values, err := redis.IntMap(RedisConfDo("HGETALL", GetUpstreamHealthKey(serviceName)))
+	if err != nil {
+		return nil, err
+	}
+	health := map[string]bool{}
+	for node, up := range values {
+		health[node] = up == 1
+	}
+	return health, nil
+}
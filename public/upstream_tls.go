@@ -0,0 +1,85 @@
+package public
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"github.com/pkg/errors"
+	"io/ioutil"
+)
+
+// UpstreamTLSConf 上游https连接配置，证书均为网关本地文件路径
+type UpstreamTLSConf struct {
+	CaFile     string //校验上游证书的CA，为空时使用系统根证书
+	CertFile   string //双向认证的客户端证书
+	KeyFile    string //双向认证的客户端私钥
+	ServerName string //覆盖SNI及证书校验的域名
+	MinVersion string //最低TLS版本 1.0/1.1/1.2/1.3
+	Insecure   bool   //跳过证书校验，仅用于测试
+}
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// ParseTLSVersion 为空时返回0，使用go默认值
+func ParseTLSVersion(version string) (uint16, error) {
+	if version == "" {
+		return 0, nil
+	}
+	v, ok := tlsVersions[version]
+	if !ok {
+		return 0, errors.Errorf("unsupported tls version %s", version)
+	}
+	return v, nil
+}
+
+// IsEmpty 未做任何配置时沿用默认的TLS设置
+func (conf *UpstreamTLSConf) IsEmpty() bool {
+	return *conf == UpstreamTLSConf{}
+}
+
+// Validate 只校验配置本身，不读取证书文件；证书文件在代理所在机器加载，后台机器上未必存在
+func (conf *UpstreamTLSConf) Validate() error {
+	if _, err := ParseTLSVersion(conf.MinVersion); err != nil {
+		return err
+	}
+	if (conf.CertFile == "") != (conf.KeyFile == "") {
+		return errors.New("upstream client cert and key must be set together")
+	}
+	return nil
+}
+
+// NewUpstreamTLSConfig 加载CA与客户端证书，生成上游连接使用的tls.Config
+func NewUpstreamTLSConfig(conf *UpstreamTLSConf) (*tls.Config, error) {
+	if err := conf.Validate(); err != nil {
+		return nil, err
+	}
+	minVersion, _ := ParseTLSVersion(conf.MinVersion)
+	tlsConf := &tls.Config{
+		ServerName:         conf.ServerName,
+		MinVersion:         minVersion,
+		InsecureSkipVerify: conf.Insecure,
+	}
+	if conf.CaFile != "" {
+		caPem, err := ioutil.ReadFile(conf.CaFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "read upstream ca file")
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caPem) {
+			return nil, errors.Errorf("no certificate found in %s", conf.CaFile)
+		}
+		tlsConf.RootCAs = pool
+	}
+	if conf.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(conf.CertFile, conf.KeyFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "load upstream client cert")
+		}
+		tlsConf.Certificates = []tls.Certificate{cert}
+	}
+	return tlsConf, nil
+}
@@ -0,0 +1,86 @@
+package public
+
+import (
+	"crypto/tls"
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+)
+
+func TestNewUpstreamTLSConfig(t *testing.T) {
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(r.TLS.PeerCertificates) == 0 {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		w.Write([]byte(r.TLS.PeerCertificates[0].Subject.CommonName))
+	}))
+	ts.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+	ts.StartTLS()
+	defer ts.Close()
+
+	caFile := filepath.Join(t.TempDir(), "ca.crt")
+	caPem := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ts.Certificate().Raw})
+	if err := ioutil.WriteFile(caFile, caPem, 0600); err != nil {
+		t.Fatal(err)
+	}
+	tlsConf, err := NewUpstreamTLSConfig(&UpstreamTLSConf{
+		CaFile:     caFile,
+		CertFile:   "../conf/cert_file/client.crt",
+		KeyFile:    "../conf/cert_file/client.key",
+		ServerName: "example.com",
+		MinVersion: "1.2",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tlsConf.MinVersion != tls.VersionTLS12 {
+		t.Fatalf("min version %x", tlsConf.MinVersion)
+	}
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: tlsConf}}
+	resp, err := client.Get(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := ioutil.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK || string(body) != "example1.com" {
+		t.Fatalf("unexpected response %d %s", resp.StatusCode, body)
+	}
+
+	//SNI与证书不匹配时校验失败
+	tlsConf.ServerName = "other.com"
+	client = &http.Client{Transport: &http.Transport{TLSClientConfig: tlsConf}}
+	if _, err := client.Get(ts.URL); err == nil {
+		t.Fatal("expected verify error")
+	}
+}
+
+func TestNewUpstreamTLSConfigInvalid(t *testing.T) {
+	cases := []*UpstreamTLSConf{
+		{MinVersion: "1.4"},
+		{CaFile: "../conf/cert_file/not_exist.crt"},
+		{CaFile: "../conf/cert_file/client.key"},
+		{CertFile: "../conf/cert_file/client.crt"},
+	}
+	for _, conf := range cases {
+		if _, err := NewUpstreamTLSConfig(conf); err == nil {
+			t.Errorf("expected error for %+v", conf)
+		}
+	}
+	if !(&UpstreamTLSConf{}).IsEmpty() {
+		t.Fatal("empty conf")
+	}
+	//Validate不读取文件
+	if err := (&UpstreamTLSConf{CaFile: "../conf/cert_file/not_exist.crt"}).Validate(); err != nil {
+		t.Fatal(err)
+	}
+	for _, conf := range []*UpstreamTLSConf{cases[0], cases[3], {KeyFile: "../conf/cert_file/client.key"}} {
+		if err := conf.Validate(); err == nil {
+			t.Errorf("expected validate error for %+v", conf)
+		}
+	}
+}
@@ -0,0 +1,213 @@
+package public
+
+import (
+	"encoding/json"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	RewriteTargetPath  = "path"  //只重写path，默认
+	RewriteTargetQuery = "query" //只重写query string，不含?
+	RewriteTargetUri   = "uri"   //重写path?query整体
+)
+
+// UrlRewriteRule url重写规则，match为正则，replace支持$1分组与${var}变量；
+// conditions全部满足时才重写，last为true时命中后不再执行后续规则
+type UrlRewriteRule struct {
+	Match      string             `json:"match"`
+	Replace    string             `json:"replace"`
+	Target     string             `json:"target,omitempty"`
+	Conditions []RewriteCondition `json:"conditions,omitempty"`
+	Last       bool               `json:"last,omitempty"`
+}
+
+// RewriteCondition source为变量名(同${var}，如header.User-Agent、query.ver、method)，match为正则
+type RewriteCondition struct {
+	Source string `json:"source"`
+	Match  string `json:"match"`
+	Negate bool   `json:"negate,omitempty"`
+}
+
+// ParseUrlRewriteRules 解析url重写规则，兼容旧格式: ^/abc(.*) $1，多个逗号间隔。
+// 与旧版本一致，旧格式中无法解析的单条规则直接跳过；JSON格式任一规则无效时返回错误
+func ParseUrlRewriteRules(rules string) ([]UrlRewriteRule, error) {
+	return parseUrlRewriteRules(rules, false)
+}
+
+func parseUrlRewriteRules(rules string, strict bool) ([]UrlRewriteRule, error) {
+	list := []UrlRewriteRule{}
+	if strings.TrimSpace(rules) == "" {
+		return list, nil
+	}
+	if !isJsonRules(rules) {
+		for _, item := range strings.Split(rules, ",") {
+			items := strings.Split(item, " ")
+			if len(items) != 2 || items[0] == "" {
+				if strict {
+					return nil, errors.Errorf("invalid url rewrite rule %s", item)
+				}
+				continue
+			}
+			list = append(list, UrlRewriteRule{Match: items[0], Replace: items[1]})
+		}
+		return list, nil
+	}
+	if err := json.Unmarshal([]byte(rules), &list); err != nil {
+		return nil, errors.Wrap(err, "invalid url rewrite rules")
+	}
+	for _, rule := range list {
+		if rule.Match == "" {
+			return nil, errors.New("url rewrite match is empty")
+		}
+		if rule.Target != "" && rule.Target != RewriteTargetPath && rule.Target != RewriteTargetQuery && rule.Target != RewriteTargetUri {
+			return nil, errors.Errorf("unsupported url rewrite target %s", rule.Target)
+		}
+		for _, cond := range rule.Conditions {
+			if cond.Source == "" {
+				return nil, errors.Errorf("url rewrite condition source of %s is empty", rule.Match)
+			}
+		}
+	}
+	return list, nil
+}
+
+// RewriteRule 编译后的url重写规则，服务加载时生成，请求间共用
+type RewriteRule struct {
+	UrlRewriteRule
+	regexp     *regexp.Regexp
+	conditions []*regexp.Regexp
+}
+
+// CompileUrlRewriteRules 服务加载时解析并编译，正则无效的单条规则跳过，返回的错误列出被跳过的规则
+func CompileUrlRewriteRules(rules string) ([]*RewriteRule, error) {
+	return compileUrlRewriteRules(rules, false)
+}
+
+// ValidUrlRewriteRules 保存时校验，旧格式中的每条规则及全部正则都必须有效
+func ValidUrlRewriteRules(rules string) error {
+	_, err := compileUrlRewriteRules(rules, true)
+	return err
+}
+
+func compileUrlRewriteRules(rules string, strict bool) ([]*RewriteRule, error) {
+	list, err := parseUrlRewriteRules(rules, strict)
+	if err != nil {
+		return nil, err
+	}
+	compiled := []*RewriteRule{}
+	errs := []string{}
+	for _, item := range list {
+		rule, err := compileUrlRewriteRule(item)
+		if err != nil {
+			if strict {
+				return nil, err
+			}
+			errs = append(errs, err.Error())
+			continue
+		}
+		compiled = append(compiled, rule)
+	}
+	if len(errs) > 0 {
+		return compiled, errors.New(strings.Join(errs, "; "))
+	}
+	return compiled, nil
+}
+
+func compileUrlRewriteRule(item UrlRewriteRule) (*RewriteRule, error) {
+	rule := &RewriteRule{UrlRewriteRule: item}
+	var err error
+	if rule.regexp, err = regexp.Compile(item.Match); err != nil {
+		return nil, errors.Wrapf(err, "invalid url rewrite regexp %s", item.Match)
+	}
+	for _, cond := range item.Conditions {
+		condRegexp, err := regexp.Compile(cond.Match)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid url rewrite condition regexp %s", cond.Match)
+		}
+		rule.conditions = append(rule.conditions, condRegexp)
+	}
+	return rule, nil
+}
+
+// Apply 条件满足且正则命中时重写u，返回命中的分组
+func (r *RewriteRule) Apply(u *url.URL, lookup VarLookup) ([]string, bool) {
+	for i, cond := range r.Conditions {
+		value := ""
+		if lookup != nil {
+			value, _ = lookup(cond.Source)
+		}
+		if r.conditions[i].MatchString(value) == cond.Negate {
+			return nil, false
+		}
+	}
+	var subject string
+	switch r.Target {
+	case RewriteTargetQuery:
+		subject = u.RawQuery
+	case RewriteTargetUri:
+		subject = u.Path
+		if u.RawQuery != "" {
+			subject += "?" + u.RawQuery
+		}
+	default:
+		subject = u.Path
+	}
+	groups := r.regexp.FindStringSubmatch(subject)
+	if groups == nil {
+		return nil, false
+	}
+	result := r.regexp.ReplaceAllString(subject, Interpolate(r.Replace, RewriteVarLookup(lookup)))
+	switch r.Target {
+	case RewriteTargetQuery:
+		u.RawQuery = result
+	case RewriteTargetUri:
+		path, query := result, ""
+		if idx := strings.Index(result, "?"); idx >= 0 {
+			path, query = result[:idx], result[idx+1:]
+		}
+		u.Path, u.RawPath, u.RawQuery = path, "", query
+	default:
+		u.Path, u.RawPath = result, ""
+	}
+	return groups, true
+}
+
+// NormalizeUrlRewriteRules 旧格式转为JSON保存
+func NormalizeUrlRewriteRules(rules string) (string, error) {
+	if strings.TrimSpace(rules) == "" || isJsonRules(rules) {
+		return rules, nil
+	}
+	list, err := ParseUrlRewriteRules(rules)
+	if err != nil {
+		return "", err
+	}
+	data, err := json.Marshal(list)
+	return string(data), err
+}
+
+// RewriteVarLookup 重写模板中的变量值转义$，避免被当作正则分组；数字变量留给正则展开
+func RewriteVarLookup(lookup VarLookup) VarLookup {
+	return func(name string) (string, bool) {
+		if isGroupName(name) || lookup == nil {
+			return "", false
+		}
+		value, ok := lookup(name)
+		return strings.Replace(value, "$", "$$", -1), ok
+	}
+}
+
+func isGroupName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, r := range name {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
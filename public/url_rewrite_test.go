@@ -0,0 +1,110 @@
+package public
+
+import (
+	"net/url"
+	"regexp"
+	"testing"
+)
+
+func rewriteLookup(name string) (string, bool) {
+	vars := map[string]string{"method": "GET", "header.User-Agent": "Mobile Safari", "query.ver": "v2"}
+	value, ok := vars[name]
+	return value, ok
+}
+
+func applyRewrite(t *testing.T, rules, target string) string {
+	compiled, err := CompileUrlRewriteRules(rules)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, _ := url.Parse(target)
+	for _, rule := range compiled {
+		if _, ok := rule.Apply(u, rewriteLookup); ok && rule.Last {
+			break
+		}
+	}
+	return u.RequestURI()
+}
+
+func TestRewriteRuleApply(t *testing.T) {
+	cases := []struct {
+		rules  string
+		target string
+		want   string
+	}{
+		{"^/abc/(.*) /def/$1", "/abc/x?a=1", "/def/x?a=1"},
+		{`[{"match":"(^|&)uid=(\\d+)","replace":"${1}user_id=$2","target":"query"}]`, "/p?uid=12&b=2", "/p?user_id=12&b=2"},
+		{`[{"match":"^/old\\?id=(\\d+)$","replace":"/new/$1?from=old","target":"uri"}]`, "/old?id=7", "/new/7?from=old"},
+		{`[{"match":"^/(.*)","replace":"/m/$1","conditions":[{"source":"header.User-Agent","match":"Mobile"}]}]`, "/home", "/m/home"},
+		{`[{"match":"^/(.*)","replace":"/m/$1","conditions":[{"source":"header.User-Agent","match":"Mobile","negate":true}]}]`, "/home", "/home"},
+		{`[{"match":"^/(.*)","replace":"/${query.ver}/$1","last":true},{"match":"^/v2/(.*)","replace":"/never/$1"}]`, "/home", "/v2/home"},
+	}
+	for _, item := range cases {
+		if got := applyRewrite(t, item.rules, item.target); got != item.want {
+			t.Errorf("rewrite %s with %s = %s, want %s", item.target, item.rules, got, item.want)
+		}
+	}
+}
+
+func TestValidUrlRewriteRules(t *testing.T) {
+	for _, rules := range []string{
+		"^/abc(.* $1",
+		"^/abc/(.*) /def/$1,^/abc",
+		`[{"match":"^/a","replace":"/b","target":"fragment"}]`,
+		`[{"match":"^/a","replace":"/b","conditions":[{"source":"method","match":"GET("}]}]`,
+		`[{"match":"^/a","replace":"/b","conditions":[{"match":"GET"}]}]`,
+	} {
+		if err := ValidUrlRewriteRules(rules); err == nil {
+			t.Errorf("expected error for %s", rules)
+		}
+	}
+}
+
+// 服务加载时只跳过无效的单条规则，其余规则照常生效
+func TestCompileUrlRewriteRulesLenient(t *testing.T) {
+	compiled, err := CompileUrlRewriteRules("^/abc,^/abc/(.*) /def/$1,^/x(.* /y")
+	if err == nil {
+		t.Fatal("expect error for invalid regexp")
+	}
+	if len(compiled) != 1 || compiled[0].Match != "^/abc/(.*)" {
+		t.Fatalf("unexpected rules %v", compiled)
+	}
+	compiled, err = CompileUrlRewriteRules(`[{"match":"^/a(","replace":"/b"},` +
+		`{"match":"^/c","replace":"/d","conditions":[{"source":"method","match":"GET("}]},{"match":"^/e","replace":"/f"}]`)
+	if err == nil || len(compiled) != 1 || compiled[0].Match != "^/e" {
+		t.Fatalf("unexpected rules %v %v", compiled, err)
+	}
+	if _, err := CompileUrlRewriteRules(`[{"match":"^/a","replace":"/b","target":"fragment"}]`); err == nil {
+		t.Fatal("expect error for invalid json rules")
+	}
+}
+
+const benchRewriteRules = `[{"match":"^/test_http_service/abb/(.*)","replace":"/test_http_service/bba/$1"},` +
+	`{"match":"^/api/v1/(\\w+)/(\\d+)$","replace":"/api/v2/$1/$2"},{"match":"^/static/(.*)","replace":"/assets/$1"}]`
+
+// 每次请求都编译正则，即原来的实现
+func BenchmarkRewriteCompilePerRequest(b *testing.B) {
+	list, _ := ParseUrlRewriteRules(benchRewriteRules)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		path := "/api/v1/user/123"
+		for _, rule := range list {
+			re, err := regexp.Compile(rule.Match)
+			if err != nil {
+				continue
+			}
+			path = re.ReplaceAllString(path, rule.Replace)
+		}
+	}
+}
+
+func BenchmarkRewriteCompiled(b *testing.B) {
+	rules, _ := CompileUrlRewriteRules(benchRewriteRules)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		u := &url.URL{Path: "/api/v1/user/123"}
+		for _, rule := range rules {
+			rule.Apply(u, nil)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package public
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// IsWebsocketRequest 判断是否为websocket升级请求
+func IsWebsocketRequest(r *http.Request) bool {
+	return headerContainsToken(r.Header, "Connection", "upgrade") &&
+		strings.EqualFold(r.Header.Get("Upgrade"), "websocket")
+}
+
+func headerContainsToken(header http.Header, name, token string) bool {
+	for _, value := range header.Values(name) {
+		for _, item := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(item), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+var WebsocketConnHandler *WebsocketConn
+
+func init() {
+	WebsocketConnHandler = NewWebsocketConn()
+}
+
+// WebsocketConn 按服务统计当前websocket连接数
+type WebsocketConn struct {
+	ConnMap map[string]int
+	Locker  sync.Mutex
+}
+
+func NewWebsocketConn() *WebsocketConn {
+	return &WebsocketConn{
+		ConnMap: map[string]int{},
+		Locker:  sync.Mutex{},
+	}
+}
+
+// Acquire 占用一个连接名额，max<=0表示不限制
+func (w *WebsocketConn) Acquire(serviceName string, max int) bool {
+	w.Locker.Lock()
+	defer w.Locker.Unlock()
+	if max > 0 && w.ConnMap[serviceName] >= max {
+		return false
+	}
+	w.ConnMap[serviceName]++
+	MetricsWebsocketConnections.WithLabelValues(serviceName).Inc()
+	return true
+}
+
+func (w *WebsocketConn) Release(serviceName string) {
+	w.Locker.Lock()
+	defer w.Locker.Unlock()
+	if w.ConnMap[serviceName] <= 0 {
+		return
+	}
+	w.ConnMap[serviceName]--
+	if w.ConnMap[serviceName] == 0 {
+		delete(w.ConnMap, serviceName)
+	}
+	MetricsWebsocketConnections.WithLabelValues(serviceName).Dec()
+}
+
+func (w *WebsocketConn) Count(serviceName string) int {
+	w.Locker.Lock()
+	defer w.Locker.Unlock()
+	return w.ConnMap[serviceName]
+}
@@ -1,19 +1,33 @@
 package reverse_proxy
 
 import (
+	"FGateWay/dao"
 	"FGateWay/middleware"
+	"FGateWay/public"
 	"FGateWay/reverse_proxy/load_balance"
+	"context"
 	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel/attribute"
+	"io"
 	"net/http"
 	"net/http/httputil"
 	"net/url"
 	"strings"
+	"time"
 )
 
 func NewLoadBalanceReverseProxy(c *gin.Context, lb load_balance.LoadBalance, trans *http.Transport) *httputil.ReverseProxy {
+	//上游耗时：从发出请求到收到响应头
+	var upstreamStart time.Time
 
 	director := func(req *http.Request) {
-		nextAddr, err := lb.Get(req.URL.String())
+		//websocket按客户端ip取节点，ip_hash方式下重连仍落在同一节点
+		key := req.URL.String()
+		if c.GetBool("websocket") {
+			key = c.ClientIP()
+		}
+		nextAddr, err := lb.Get(key)
 		if err != nil || nextAddr == "" {
 			panic("get next addr fail")
 		}
@@ -22,6 +36,7 @@ func NewLoadBalanceReverseProxy(c *gin.Context, lb load_balance.LoadBalance, tra
 		if err != nil {
 			panic(err)
 		}
+		c.Set("upstream", target.Host)
 
 		targetQuery := target.RawQuery
 		req.URL.Scheme = target.Scheme
@@ -37,23 +52,115 @@ func NewLoadBalanceReverseProxy(c *gin.Context, lb load_balance.LoadBalance, tra
 		if _, ok := req.Header["User-Agent"]; !ok {
 			req.Header.Set("User-Agent", "user-agent")
 		}
+		//透传剩余超时时间，上游可据此提前放弃
+		public.SetRequestTimeoutHeader(req)
+		upstreamStart = time.Now()
 
 	}
 
 	modifyFunc := func(resp *http.Response) error {
-		if strings.Contains(resp.Header.Get("Connection"), "Upgrade") {
+		c.Set("upstream_latency", time.Since(upstreamStart))
+		modifyResponseHeader(c, resp.Header)
+		if resp.StatusCode == http.StatusSwitchingProtocols {
+			if backConn, ok := resp.Body.(io.ReadWriteCloser); ok && c.GetBool("websocket") {
+				idle, pingInterval := websocketTimeouts(c)
+				resp.Body = newWebsocketConn(backConn, idle, pingInterval)
+			}
 			return nil
 		}
+		//响应体大小限制：已知长度直接拒绝，未知长度边转发边计数，超限后中断连接
+		if limit := maxResponseBodySize(c); limit > 0 {
+			if resp.ContentLength > limit {
+				return errors.Wrapf(public.ErrBodyTooLarge, "response body size %v exceeds limit %v", resp.ContentLength, limit)
+			}
+			resp.Body = public.NewLimitedBody(resp.Body, limit)
+		}
+		if idle := streamIdleTimeout(c); idle > 0 {
+			resp.Body = newIdleTimeoutBody(resp.Body, idle)
+		}
 		return nil
 	}
 
 	errFunc := func(w http.ResponseWriter, r *http.Request, err error) {
+		if bodyInterface, ok := c.Get("body_limit"); ok && bodyInterface.(*public.LimitedBody).Exceeded() {
+			middleware.ResponseErrorStatus(c, http.StatusRequestEntityTooLarge, 6001, errors.New("request body exceeds limit"))
+			return
+		}
+		if r.Context().Err() == context.DeadlineExceeded {
+			middleware.ResponseErrorStatus(c, http.StatusGatewayTimeout, 6004, errors.New("upstream request timeout"))
+			return
+		}
+		if errors.Cause(err) == public.ErrBodyTooLarge {
+			middleware.ResponseErrorStatus(c, http.StatusBadGateway, 6003, err)
+			return
+		}
+		c.Set("upstream_error", true)
+		c.Set("upstream_latency", time.Since(upstreamStart))
 		middleware.ResponseError(c, 999, err)
 	}
 
-	return &httputil.ReverseProxy{Director: director, ModifyResponse: modifyFunc, ErrorHandler: errFunc}
+	return &httputil.ReverseProxy{
+		Director:       director,
+		Transport:      NewTraceTransport(trans, traceAttrs(c)...),
+		ModifyResponse: modifyFunc,
+		ErrorHandler:   errFunc,
+	}
+
+}
+
+// 上游响应header处理：网关已下发的HSTS、跨域header以服务配置为准，再按规则转换
+func modifyResponseHeader(c *gin.Context, header http.Header) {
+	if c.Writer.Header().Get(public.HeaderHsts) != "" {
+		header.Del(public.HeaderHsts)
+	}
+	serviceInterface, ok := c.Get("service")
+	if !ok {
+		return
+	}
+	httpRule := serviceInterface.(*dao.ServiceDetail).HTTPRule
+	if httpRule.OpenCors == 1 {
+		public.DelCorsHeaders(header)
+	}
+	public.ApplyHeaderRules(header, httpRule.GetResponseHeaderRules(), RuleVars(c))
+}
+
+func maxResponseBodySize(c *gin.Context) int64 {
+	serviceInterface, ok := c.Get("service")
+	if !ok {
+		return 0
+	}
+	return int64(serviceInterface.(*dao.ServiceDetail).HTTPRule.MaxResponseBodySize)
+}
 
+func streamIdleTimeout(c *gin.Context) time.Duration {
+	serviceInterface, ok := c.Get("service")
+	if !ok {
+		return 0
+	}
+	return time.Duration(serviceInterface.(*dao.ServiceDetail).LoadBalance.UpstreamStreamIdleTimeout) * time.Second
 }
+
+func websocketTimeouts(c *gin.Context) (time.Duration, time.Duration) {
+	serviceInterface, ok := c.Get("service")
+	if !ok {
+		return 0, 0
+	}
+	httpRule := serviceInterface.(*dao.ServiceDetail).HTTPRule
+	return time.Duration(httpRule.WebsocketIdleTimeout) * time.Second, time.Duration(httpRule.WebsocketPingInterval) * time.Second
+}
+
+// 从上下文中取出服务与租户，作为client span的属性
+func traceAttrs(c *gin.Context) []attribute.KeyValue {
+	attrs := []attribute.KeyValue{}
+	if serviceInterface, ok := c.Get("service"); ok {
+		attrs = append(attrs, public.TraceAttrService.String(serviceInterface.(*dao.ServiceDetail).Info.ServiceName))
+	}
+	if appInterface, ok := c.Get("app"); ok {
+		attrs = append(attrs, public.TraceAttrApp.String(appInterface.(*dao.App).AppID))
+	}
+	return attrs
+}
+
 func singleJoiningSlash(a, b string) string {
 	aslash := strings.HasSuffix(a, "/")
 	bslash := strings.HasPrefix(b, "/")
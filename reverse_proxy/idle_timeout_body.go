@@ -0,0 +1,43 @@
+package reverse_proxy
+
+import (
+	"errors"
+	"io"
+	"sync/atomic"
+	"time"
+)
+
+var errStreamIdleTimeout = errors.New("upstream stream idle timeout")
+
+// idleTimeoutBody 上游响应体在idle时间内没有数据时关闭body，中断转发
+type idleTimeoutBody struct {
+	io.ReadCloser
+	timer   *time.Timer
+	idle    time.Duration
+	expired int32
+}
+
+func newIdleTimeoutBody(body io.ReadCloser, idle time.Duration) *idleTimeoutBody {
+	b := &idleTimeoutBody{ReadCloser: body, idle: idle}
+	b.timer = time.AfterFunc(idle, func() {
+		atomic.StoreInt32(&b.expired, 1)
+		body.Close()
+	})
+	return b
+}
+
+func (b *idleTimeoutBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if atomic.LoadInt32(&b.expired) == 1 {
+		return n, errStreamIdleTimeout
+	}
+	if n > 0 {
+		b.timer.Reset(b.idle)
+	}
+	return n, err
+}
+
+func (b *idleTimeoutBody) Close() error {
+	b.timer.Stop()
+	return b.ReadCloser.Close()
+}
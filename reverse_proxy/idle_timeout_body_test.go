@@ -0,0 +1,27 @@
+package reverse_proxy
+
+import (
+	"io"
+	"io/ioutil"
+	"testing"
+	"time"
+)
+
+func TestIdleTimeoutBody(t *testing.T) {
+	pr, pw := io.Pipe()
+	go func() {
+		pw.Write([]byte("data"))
+		//之后不再写入，模拟上游挂起
+	}()
+	body := newIdleTimeoutBody(pr, 50*time.Millisecond)
+	defer body.Close()
+
+	start := time.Now()
+	data, err := ioutil.ReadAll(body)
+	if err != errStreamIdleTimeout || string(data) != "data" {
+		t.Fatalf("data = %s, err = %v", data, err)
+	}
+	if cost := time.Since(start); cost > time.Second {
+		t.Fatalf("idle timeout too slow: %v", cost)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"net"
 	"reflect"
 	"sort"
+	"sync"
 	"time"
 )
 
@@ -21,6 +22,7 @@ type LoadBalanceCheckConf struct {
 	confIpWeight map[string]string
 	activeList   []string
 	format       string
+	locker       sync.RWMutex
 }
 
 func (s *LoadBalanceCheckConf) Attach(o Observer) {
@@ -34,6 +36,8 @@ func (s *LoadBalanceCheckConf) NotifyAllObservers() {
 }
 
 func (s *LoadBalanceCheckConf) GetConf() []string {
+	s.locker.RLock()
+	defer s.locker.RUnlock()
 	confList := []string{}
 	for _, ip := range s.activeList {
 		weight, ok := s.confIpWeight[ip]
@@ -73,8 +77,11 @@ func (s *LoadBalanceCheckConf) WatchConf() {
 				}
 			}
 			sort.Strings(changedList)
+			s.locker.Lock()
 			sort.Strings(s.activeList)
-			if !reflect.DeepEqual(changedList, s.activeList) {
+			changed := !reflect.DeepEqual(changedList, s.activeList)
+			s.locker.Unlock()
+			if changed {
 				s.UpdateConf(changedList)
 			}
 			time.Sleep(time.Duration(DefaultCheckInterval) * time.Second)
@@ -85,12 +92,28 @@ func (s *LoadBalanceCheckConf) WatchConf() {
 // 更新配置时，通知监听者也更新
 func (s *LoadBalanceCheckConf) UpdateConf(conf []string) {
 	//fmt.Println("UpdateConf", conf)
+	s.locker.Lock()
 	s.activeList = conf
+	s.locker.Unlock()
 	for _, obs := range s.observers {
 		obs.Update()
 	}
 }
 
+// GetHealthState 返回每个节点的健康状态，true为存活
+func (s *LoadBalanceCheckConf) GetHealthState() map[string]bool {
+	s.locker.RLock()
+	defer s.locker.RUnlock()
+	state := map[string]bool{}
+	for ip := range s.confIpWeight {
+		state[ip] = false
+	}
+	for _, ip := range s.activeList {
+		state[ip] = true
+	}
+	return state
+}
+
 func NewLoadBalanceCheckConf(format string, conf map[string]string) (*LoadBalanceCheckConf, error) {
 	aList := []string{}
 	//默认初始化
@@ -0,0 +1,83 @@
+package reverse_proxy
+
+import (
+	"FGateWay/dao"
+	"FGateWay/public"
+	"github.com/dgrijalva/jwt-go"
+	"github.com/gin-gonic/gin"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// RuleVars header与url重写规则中可用的变量：
+// client_ip remote_addr host scheme method path query original_uri original_path request_id
+// service.name app.id app.name header.<名称> query.<名称> jwt.<claim> 以及url重写匹配的分组${1}...
+// 转发X-Forwarded-For时代理会自动追加客户端ip，无需配置
+func RuleVars(c *gin.Context) public.VarLookup {
+	return func(name string) (string, bool) {
+		switch name {
+		case "client_ip":
+			return c.ClientIP(), true
+		case "remote_addr":
+			return c.Request.RemoteAddr, true
+		case "host":
+			return c.Request.Host, true
+		case "scheme":
+			if c.Request.TLS != nil {
+				return "https", true
+			}
+			return "http", true
+		case "method":
+			return c.Request.Method, true
+		case "path":
+			return c.Request.URL.Path, true
+		case "query":
+			return c.Request.URL.RawQuery, true
+		case "original_uri":
+			return c.Request.RequestURI, true
+		case "original_path":
+			//strip_uri与url重写只修改URL，RequestURI保留客户端原始请求
+			if u, err := url.ParseRequestURI(c.Request.RequestURI); err == nil {
+				return u.Path, true
+			}
+			return c.Request.URL.Path, true
+		case "request_id":
+			return public.GetGinTraceContext(c).TraceId, true
+		case "service.name":
+			if serviceInterface, ok := c.Get("service"); ok {
+				return serviceInterface.(*dao.ServiceDetail).Info.ServiceName, true
+			}
+			return "", true
+		case "app.id", "app.name":
+			appInterface, ok := c.Get("app")
+			if !ok {
+				return "", true
+			}
+			if name == "app.id" {
+				return appInterface.(*dao.App).AppID, true
+			}
+			return appInterface.(*dao.App).Name, true
+		}
+		switch {
+		case strings.HasPrefix(name, "header."):
+			return c.Request.Header.Get(strings.TrimPrefix(name, "header.")), true
+		case strings.HasPrefix(name, "query."):
+			return c.Request.URL.Query().Get(strings.TrimPrefix(name, "query.")), true
+		case strings.HasPrefix(name, "jwt."):
+			claimsInterface, ok := c.Get("oidc_claims")
+			if !ok {
+				return "", true
+			}
+			return public.ClaimString(claimsInterface.(jwt.MapClaims), strings.TrimPrefix(name, "jwt.")), true
+		}
+		if index, err := strconv.Atoi(name); err == nil {
+			groups := c.GetStringSlice("rewrite_groups")
+			if index >= 0 && index < len(groups) {
+				return groups[index], true
+			}
+			return "", true
+		}
+		return "", false
+	}
+}
@@ -0,0 +1,218 @@
+package reverse_proxy
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	errWebsocketIdleTimeout = errors.New("websocket idle timeout")
+	errWebsocketPingTimeout = errors.New("websocket ping timeout")
+)
+
+// 服务端发往客户端的ping帧：FIN+opcode=0x9，无掩码，无负载
+var websocketPingFrame = []byte{0x89, 0x00}
+
+// websocketConn 包装升级后的上游连接，ReverseProxy从Read读取发往客户端的数据，向Write写入客户端数据。
+// 双向均无数据超过idle时关闭连接；客户端静默超过pingInterval时在帧边界插入ping，
+// 再过一个pingInterval仍无任何客户端数据(包括pong)则关闭连接
+type websocketConn struct {
+	backConn     io.ReadWriteCloser
+	idle         time.Duration
+	pingInterval time.Duration
+
+	lastActive int64 //双向最后活跃时间
+	lastClient int64 //客户端最后发送数据时间
+
+	chunks    chan []byte
+	pending   []byte
+	frameLock sync.Mutex //持有期间表示正在转发一个完整帧，ping只能在帧之间插入
+	done      chan struct{}
+	closeOnce sync.Once
+	err       atomic.Value
+}
+
+func newWebsocketConn(backConn io.ReadWriteCloser, idle, pingInterval time.Duration) *websocketConn {
+	now := time.Now().UnixNano()
+	w := &websocketConn{
+		backConn:     backConn,
+		idle:         idle,
+		pingInterval: pingInterval,
+		lastActive:   now,
+		lastClient:   now,
+		chunks:       make(chan []byte),
+		done:         make(chan struct{}),
+	}
+	go w.pump()
+	if idle > 0 || pingInterval > 0 {
+		go w.watch()
+	}
+	return w
+}
+
+func (w *websocketConn) Read(p []byte) (int, error) {
+	if len(w.pending) == 0 {
+		select {
+		case chunk := <-w.chunks:
+			w.pending = chunk
+		case <-w.done:
+			return 0, w.closeErr()
+		}
+	}
+	n := copy(p, w.pending)
+	w.pending = w.pending[n:]
+	return n, nil
+}
+
+func (w *websocketConn) Write(p []byte) (int, error) {
+	now := time.Now().UnixNano()
+	atomic.StoreInt64(&w.lastActive, now)
+	atomic.StoreInt64(&w.lastClient, now)
+	return w.backConn.Write(p)
+}
+
+func (w *websocketConn) Close() error {
+	w.closeWithError(io.EOF)
+	return nil
+}
+
+func (w *websocketConn) closeWithError(err error) {
+	w.closeOnce.Do(func() {
+		w.err.Store(err)
+		close(w.done)
+		w.backConn.Close()
+	})
+}
+
+func (w *websocketConn) closeErr() error {
+	if err, ok := w.err.Load().(error); ok {
+		return err
+	}
+	return io.EOF
+}
+
+func (w *websocketConn) send(chunk []byte) bool {
+	select {
+	case w.chunks <- chunk:
+		return true
+	case <-w.done:
+		return false
+	}
+}
+
+// 按帧读取上游数据
+func (w *websocketConn) pump() {
+	br := bufio.NewReader(w.backConn)
+	for {
+		//等待下一帧时不持有锁，允许插入ping
+		if _, err := br.Peek(1); err != nil {
+			w.closeWithError(err)
+			return
+		}
+		w.frameLock.Lock()
+		err := w.forwardFrame(br)
+		w.frameLock.Unlock()
+		if err != nil {
+			w.closeWithError(err)
+			return
+		}
+	}
+}
+
+func (w *websocketConn) forwardFrame(br *bufio.Reader) error {
+	header := make([]byte, 2, 14)
+	if _, err := io.ReadFull(br, header); err != nil {
+		return err
+	}
+	var length uint64
+	switch size := header[1] & 0x7f; size {
+	case 126:
+		ext := make([]byte, 2)
+		if _, err := io.ReadFull(br, ext); err != nil {
+			return err
+		}
+		header = append(header, ext...)
+		length = uint64(binary.BigEndian.Uint16(ext))
+	case 127:
+		ext := make([]byte, 8)
+		if _, err := io.ReadFull(br, ext); err != nil {
+			return err
+		}
+		header = append(header, ext...)
+		length = binary.BigEndian.Uint64(ext)
+	default:
+		length = uint64(size)
+	}
+	if header[1]&0x80 != 0 {
+		maskKey := make([]byte, 4)
+		if _, err := io.ReadFull(br, maskKey); err != nil {
+			return err
+		}
+		header = append(header, maskKey...)
+	}
+	atomic.StoreInt64(&w.lastActive, time.Now().UnixNano())
+	if !w.send(header) {
+		return io.EOF
+	}
+	for length > 0 {
+		size := uint64(32 * 1024)
+		if length < size {
+			size = length
+		}
+		chunk := make([]byte, size)
+		if _, err := io.ReadFull(br, chunk); err != nil {
+			return err
+		}
+		length -= size
+		atomic.StoreInt64(&w.lastActive, time.Now().UnixNano())
+		if !w.send(chunk) {
+			return io.EOF
+		}
+	}
+	return nil
+}
+
+// 检查空闲与ping超时
+func (w *websocketConn) watch() {
+	interval := w.idle
+	if interval == 0 || (w.pingInterval > 0 && w.pingInterval < interval) {
+		interval = w.pingInterval
+	}
+	if interval = interval / 4; interval < 10*time.Millisecond {
+		interval = 10 * time.Millisecond
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	var lastPing time.Time
+	for {
+		select {
+		case <-w.done:
+			return
+		case <-ticker.C:
+		}
+		now := time.Now()
+		if w.idle > 0 && now.Sub(time.Unix(0, atomic.LoadInt64(&w.lastActive))) >= w.idle {
+			w.closeWithError(errWebsocketIdleTimeout)
+			return
+		}
+		if w.pingInterval <= 0 {
+			continue
+		}
+		silence := now.Sub(time.Unix(0, atomic.LoadInt64(&w.lastClient)))
+		if silence >= 2*w.pingInterval {
+			w.closeWithError(errWebsocketPingTimeout)
+			return
+		}
+		if silence >= w.pingInterval && now.Sub(lastPing) >= w.pingInterval {
+			lastPing = now
+			w.frameLock.Lock()
+			w.send(websocketPingFrame)
+			w.frameLock.Unlock()
+		}
+	}
+}
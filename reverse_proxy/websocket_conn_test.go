@@ -0,0 +1,60 @@
+package reverse_proxy
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestWebsocketConnPing(t *testing.T) {
+	upstream, gateway := net.Pipe()
+	defer upstream.Close()
+	conn := newWebsocketConn(gateway, 0, 50*time.Millisecond)
+	defer conn.Close()
+
+	//上游发送一个文本帧
+	textFrame := []byte{0x81, 0x02, 'h', 'i'}
+	go upstream.Write(textFrame)
+	buf := make([]byte, 4)
+	if _, err := io.ReadFull(conn, buf); err != nil || !bytes.Equal(buf, textFrame) {
+		t.Fatalf("frame = %v, err = %v", buf, err)
+	}
+
+	//客户端静默后插入ping
+	buf = make([]byte, 2)
+	if _, err := io.ReadFull(conn, buf); err != nil || !bytes.Equal(buf, websocketPingFrame) {
+		t.Fatalf("ping = %v, err = %v", buf, err)
+	}
+
+	//一直没有pong，连接被关闭
+	done := make(chan error)
+	go func() {
+		_, err := io.Copy(io.Discard, conn)
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil && err != errWebsocketPingTimeout {
+			t.Fatalf("err = %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("conn not closed after ping timeout")
+	}
+}
+
+func TestWebsocketConnIdle(t *testing.T) {
+	upstream, gateway := net.Pipe()
+	defer upstream.Close()
+	conn := newWebsocketConn(gateway, 50*time.Millisecond, 0)
+	defer conn.Close()
+
+	start := time.Now()
+	if _, err := conn.Read(make([]byte, 1)); err != errWebsocketIdleTimeout {
+		t.Fatalf("err = %v", err)
+	}
+	if time.Since(start) > time.Second {
+		t.Fatal("idle timeout too slow")
+	}
+}